@@ -3,39 +3,119 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	"github.com/martinohmann/rfoutlet/pkg/gpio/pigpio"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/warthog618/gpiod"
 	"github.com/warthog618/gpiod/mockup"
 )
 
+// Supported values of the --gpio-backend flag.
+const (
+	gpioBackendGPIOD  = "gpiod"
+	gpioBackendPigpio = "pigpio"
+)
+
 type device struct {
-	*gpiod.Chip
+	gpio.Backend
 	*mockup.Mockup
 }
 
+// Close closes the backend and removes the gpio mockup, if any. The mockup is
+// removed even if closing the backend failed. The first error is returned.
 func (d *device) Close() error {
-	err := d.Chip.Close()
-	if err != nil {
-		return err
+	var err error
+
+	if d.Backend != nil {
+		err = d.Backend.Close()
 	}
 
 	if d.Mockup != nil {
 		log.Debug("removing gpio mockup")
-		return d.Mockup.Close()
+
+		if mockupErr := d.Mockup.Close(); err == nil {
+			err = mockupErr
+		}
+	}
+
+	return err
+}
+
+// transmitter creates a *gpio.Transmitter which sends on the pin at offset.
+// Failing to enable real-time scheduling is not fatal and only logged.
+func (d *device) transmitter(offset int, options ...gpio.TransmitterOption) (*gpio.Transmitter, error) {
+	pin, err := d.OutputPin(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	transmitter := gpio.NewPinTransmitter(pin, options...)
+
+	if err := transmitter.RealtimeError(); err != nil {
+		log.Warnf("failed to enable real-time scheduling for transmitter: %v", err)
+	}
+
+	return transmitter, nil
+}
+
+// watcher creates a gpio.Watcher for the pin at offset.
+func (d *device) watcher(offset int) (gpio.Watcher, error) {
+	return d.Watcher(offset)
+}
+
+// receiver creates a *gpio.Receiver which listens on the pin at offset.
+func (d *device) receiver(offset int, options ...gpio.ReceiverOption) (*gpio.Receiver, error) {
+	watcher, err := d.watcher(offset)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return gpio.NewWatcherReceiver(watcher, options...), nil
 }
 
 func openGPIODevice(cmd *cobra.Command) (*device, error) {
 	gpioChipName, _ := cmd.Flags().GetString("gpio-chip")
 	gpioMockup, _ := cmd.Flags().GetBool("gpio-mockup")
 
-	var (
-		dev *device = &device{}
-		err error
-	)
+	return openGPIODeviceChip(cmd, gpioChipName, gpioMockup, nil)
+}
+
+// openGPIODeviceChip opens the gpio chip with name gpioChipName. If medium is
+// not nil, the device uses the simulated rf medium instead of the chip.
+func openGPIODeviceChip(cmd *cobra.Command, gpioChipName string, gpioMockup bool, medium *gpio.Medium) (*device, error) {
+	gpioBackend, _ := cmd.Flags().GetString("gpio-backend")
+
+	dev := &device{}
+
+	if medium != nil {
+		log.WithField("chip", gpioChipName).Debug("using simulated rf medium instead of gpio device")
+
+		dev.Backend = medium.Backend()
+
+		return dev, nil
+	}
+
+	switch gpioBackend {
+	case gpioBackendPigpio:
+		address, _ := cmd.Flags().GetString("pigpio-address")
+
+		log.WithField("address", address).Info("using pigpio daemon as gpio backend")
+
+		client, err := pigpio.Dial(address)
+		if err != nil {
+			return nil, err
+		}
+
+		dev.Backend = client
+
+		return dev, nil
+	case gpioBackendGPIOD, "":
+	default:
+		return nil, fmt.Errorf("unsupported gpio backend %q", gpioBackend)
+	}
+
+	var err error
 
 	if gpioMockup {
 		log.Debug("creating gpio mockup")
@@ -45,10 +125,76 @@ func openGPIODevice(cmd *cobra.Command) (*device, error) {
 		}
 	}
 
-	dev.Chip, err = gpiod.NewChip(gpioChipName)
+	chip, err := gpiod.NewChip(gpioChipName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gpio device %s: %v", gpioChipName, err)
+	}
+
+	dev.Backend = gpio.NewChipBackend(chip)
+
+	return dev, nil
+}
+
+// deviceSet opens gpio devices by chip name on demand. This is used if radios
+// are attached to different gpio chips.
+type deviceSet struct {
+	cmd     *cobra.Command
+	devices map[string]*device
+	order   []string
+
+	// medium is optional. If set, all devices share the simulated rf medium,
+	// so that codes transmitted on one radio can be received on the others.
+	medium *gpio.Medium
+}
+
+func newDeviceSet(cmd *cobra.Command) *deviceSet {
+	return &deviceSet{
+		cmd:     cmd,
+		devices: make(map[string]*device),
+	}
+}
+
+// get returns the device for chip and opens it if necessary. If chip is
+// empty, the chip configured via the --gpio-chip flag is used. If requested
+// via the --gpio-mockup flag, the mockup is created together with the first
+// device. The pigpio backend does not distinguish chips, so all radios share a
+// single connection to the daemon.
+func (s *deviceSet) get(chip string) (*device, error) {
+	if chip == "" {
+		chip, _ = s.cmd.Flags().GetString("gpio-chip")
+	}
+
+	if backend, _ := s.cmd.Flags().GetString("gpio-backend"); backend == gpioBackendPigpio {
+		chip = ""
+	}
+
+	if dev, ok := s.devices[chip]; ok {
+		return dev, nil
+	}
+
+	gpioMockup, _ := s.cmd.Flags().GetBool("gpio-mockup")
+
+	dev, err := openGPIODeviceChip(s.cmd, chip, gpioMockup && len(s.order) == 0, s.medium)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open gpio device: %v", err)
+		return nil, err
 	}
 
+	s.devices[chip] = dev
+	s.order = append(s.order, chip)
+
 	return dev, nil
 }
+
+// Close closes all devices in reverse order, so that a gpio mockup is removed
+// last.
+func (s *deviceSet) Close() error {
+	var firstErr error
+
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if err := s.devices[s.order[i]].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
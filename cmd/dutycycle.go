@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+)
+
+// dutyCycleLimiters maps radio names to the duty-cycle limiters of their
+// transmitters.
+type dutyCycleLimiters map[string]*gpio.DutyCycleLimiter
+
+// dutyCycleVars is the structure of the duty-cycle stats of a single radio
+// served on the debug listen address.
+type dutyCycleVars struct {
+	Limit            float64 `json:"limit"`
+	WindowSeconds    float64 `json:"windowSeconds"`
+	UsedSeconds      float64 `json:"usedSeconds"`
+	ReservedSeconds  float64 `json:"reservedSeconds"`
+	RemainingSeconds float64 `json:"remainingSeconds"`
+}
+
+// ServeHTTP implements http.Handler. It serves the duty-cycle stats of all
+// limiters as JSON.
+func (l dutyCycleLimiters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(l.vars()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (l dutyCycleLimiters) vars() map[string]dutyCycleVars {
+	vars := make(map[string]dutyCycleVars, len(l))
+
+	for radio, limiter := range l {
+		stats := limiter.Stats()
+
+		vars[radio] = dutyCycleVars{
+			Limit:            stats.Limit,
+			WindowSeconds:    stats.Window.Seconds(),
+			UsedSeconds:      stats.Used.Seconds(),
+			ReservedSeconds:  stats.Reserved.Seconds(),
+			RemainingSeconds: stats.Remaining.Seconds(),
+		}
+	}
+
+	return vars
+}
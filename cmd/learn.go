@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/martinohmann/rfoutlet/internal/config"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// captureGap is the duration without received codes after which a capture is
+// considered complete. A single button press on a remote usually sends out
+// the same code multiple times in a row.
+const captureGap = 500 * time.Millisecond
+
+func NewLearnCommand() *cobra.Command {
+	options := &LearnOptions{
+		ConfigFilename: "/etc/rfoutlet/config.yml",
+		Pin:            config.DefaultReceivePin,
+		Captures:       3,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "learn <outlet-id>",
+		Short: "Learn the codes of a remote controlled outlet and add it to the config",
+		Long:  "The learn command interactively sniffs the on and off codes of an outlet's remote control, derives protocol, pulse length and bit length and appends a ready outlet config to an outlet group in the config file.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.Run(cmd, args)
+		},
+	}
+
+	options.AddFlags(cmd)
+
+	return cmd
+}
+
+type LearnOptions struct {
+	ConfigFilename string
+	GroupID        string
+	DisplayName    string
+	Pin            uint
+	Captures       int
+	Timeout        time.Duration
+}
+
+func (o *LearnOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.ConfigFilename, "config", o.ConfigFilename, "path to the config file the outlet should be added to")
+	cmd.Flags().StringVar(&o.GroupID, "group", o.GroupID, "ID of the outlet group the outlet should be added to. The group is created if it does not exist")
+	cmd.Flags().StringVar(&o.DisplayName, "display-name", o.DisplayName, "display name of the outlet")
+	cmd.Flags().UintVar(&o.Pin, "pin", o.Pin, "gpio pin to sniff on")
+	cmd.Flags().IntVar(&o.Captures, "captures", o.Captures, "number of consistent captures required for each button")
+	cmd.Flags().DurationVar(&o.Timeout, "timeout", o.Timeout, "abort if learning did not finish within timeout. Zero means no timeout")
+}
+
+func (o *LearnOptions) Validate() error {
+	if o.GroupID == "" {
+		return errors.New("--group must not be empty")
+	}
+
+	if o.Captures < 1 {
+		return errors.New("--captures must be greater than 0")
+	}
+
+	return nil
+}
+
+func (o *LearnOptions) Run(cmd *cobra.Command, args []string) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	outletID := args[0]
+	out := cmd.OutOrStdout()
+
+	device, err := openGPIODevice(cmd)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	receiver, err := device.receiver(int(o.Pin))
+	if err != nil {
+		return fmt.Errorf("failed to create gpio receiver: %v", err)
+	}
+	defer receiver.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if o.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+
+	go handleSignals(cancel)
+
+	fmt.Fprintf(out, "Press the ON button of the remote control for outlet %q\n", outletID)
+
+	on, err := o.learnCode(ctx, receiver.Receive(), out)
+	if err != nil {
+		return fmt.Errorf("failed to learn on code: %v", err)
+	}
+
+	fmt.Fprintf(out, "Press the OFF button of the remote control for outlet %q\n", outletID)
+
+	off, err := o.learnCode(ctx, receiver.Receive(), out)
+	if err != nil {
+		return fmt.Errorf("failed to learn off code: %v", err)
+	}
+
+	if on.code == off.code {
+		return fmt.Errorf("on and off code are identical (%d), did you press the same button twice?", on.code)
+	}
+
+	if on.protocol != off.protocol || on.bitLength != off.bitLength {
+		return fmt.Errorf("on and off code use different protocols or bit lengths (%d/%d vs. %d/%d)", on.protocol, on.bitLength, off.protocol, off.bitLength)
+	}
+
+	// The transmitter always sends codes with gpio.BitLength bits, so codes
+	// of other lengths could never switch the outlet.
+	if on.bitLength != gpio.BitLength {
+		return fmt.Errorf("learned codes have a bit length of %d, but only %d bit codes can be transmitted", on.bitLength, gpio.BitLength)
+	}
+
+	oc := config.OutletConfig{
+		ID:          outletID,
+		DisplayName: o.DisplayName,
+		CodeOn:      on.code,
+		CodeOff:     off.code,
+		Protocol:    on.protocol,
+		PulseLength: averagePulseLength(append(on.pulseLengths, off.pulseLengths...)),
+	}
+
+	log.WithFields(log.Fields{
+		"codeOn":      oc.CodeOn,
+		"codeOff":     oc.CodeOff,
+		"protocol":    oc.Protocol,
+		"pulseLength": oc.PulseLength,
+		"bitLength":   on.bitLength,
+	}).Info("learned outlet codes")
+
+	err = config.AppendOutlet(o.ConfigFilename, o.GroupID, oc)
+	if err != nil {
+		return fmt.Errorf("failed to add outlet to config: %v", err)
+	}
+
+	fmt.Fprintf(out, "Added outlet %q to group %q in %s\n", outletID, o.GroupID, o.ConfigFilename)
+
+	return nil
+}
+
+// learnCode waits until o.Captures consecutive captures yielded the same code.
+// A capture consists of all identical results that are received before a pause
+// of captureGap.
+func (o *LearnOptions) learnCode(ctx context.Context, results <-chan gpio.ReceiveResult, w io.Writer) (*capture, error) {
+	var (
+		current  *capture
+		captures []*capture
+		gapCh    <-chan time.Time
+	)
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return nil, errors.New("receiver was closed unexpectedly")
+			}
+
+			if current == nil {
+				current = newCapture(result)
+			} else if current.matches(result) {
+				current.pulseLengths = append(current.pulseLengths, result.PulseLength)
+			} else {
+				// Ignore results that do not belong to the current capture,
+				// e.g. random noise or a different remote.
+				continue
+			}
+
+			gapCh = time.After(captureGap)
+		case <-gapCh:
+			gapCh = nil
+
+			if len(captures) > 0 && !captures[0].matchesCapture(current) {
+				fmt.Fprintf(w, "Captured code %d which differs from previous captures of code %d, starting over\n", current.code, captures[0].code)
+				captures = captures[:0]
+			}
+
+			captures = append(captures, current)
+			current = nil
+
+			fmt.Fprintf(w, "Captured code %d (%d/%d)\n", captures[0].code, len(captures), o.Captures)
+
+			if len(captures) == o.Captures {
+				return mergeCaptures(captures), nil
+			}
+
+			fmt.Fprintln(w, "Press the button again")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// capture contains the code, protocol and bit length received after a button
+// press on a remote control together with the observed pulse lengths.
+type capture struct {
+	code         uint64
+	protocol     int
+	bitLength    uint
+	pulseLengths []int64
+}
+
+func newCapture(result gpio.ReceiveResult) *capture {
+	return &capture{
+		code:         result.Code,
+		protocol:     result.Protocol,
+		bitLength:    result.BitLength,
+		pulseLengths: []int64{result.PulseLength},
+	}
+}
+
+func (c *capture) matches(result gpio.ReceiveResult) bool {
+	return c.code == result.Code && c.protocol == result.Protocol && c.bitLength == result.BitLength
+}
+
+func (c *capture) matchesCapture(other *capture) bool {
+	return c.code == other.code && c.protocol == other.protocol && c.bitLength == other.bitLength
+}
+
+func mergeCaptures(captures []*capture) *capture {
+	merged := *captures[0]
+	merged.pulseLengths = nil
+
+	for _, c := range captures {
+		merged.pulseLengths = append(merged.pulseLengths, c.pulseLengths...)
+	}
+
+	return &merged
+}
+
+func averagePulseLength(pulseLengths []int64) uint {
+	if len(pulseLengths) == 0 {
+		return 0
+	}
+
+	var sum int64
+
+	for _, pl := range pulseLengths {
+		sum += pl
+	}
+
+	return uint((sum + int64(len(pulseLengths))/2) / int64(len(pulseLengths)))
+}
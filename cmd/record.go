@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/martinohmann/rfoutlet/internal/config"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func NewRecordCommand() *cobra.Command {
+	options := &RecordOptions{
+		Pin:      config.DefaultReceivePin,
+		Duration: 5 * time.Second,
+		MaxGap:   200 * time.Millisecond,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "record <file>",
+		Short: "Record raw signals of remote controls",
+		Long:  "The record command captures the raw pulse timings received on a gpio pin and writes them to a file. This is useful for remote controls that use encodings not supported by any of the known protocols. Recordings can be sent out again using the replay command or by referencing them in the outlet config.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.Run(cmd, args)
+		},
+	}
+
+	options.AddFlags(cmd)
+
+	return cmd
+}
+
+type RecordOptions struct {
+	Pin      uint
+	Duration time.Duration
+	MaxGap   time.Duration
+}
+
+func (o *RecordOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().UintVar(&o.Pin, "pin", o.Pin, "gpio pin to record on")
+	cmd.Flags().DurationVar(&o.Duration, "duration", o.Duration, "maximum duration of the recording")
+	cmd.Flags().DurationVar(&o.MaxGap, "max-gap", o.MaxGap, "stop recording if no signal change was observed for this duration after the recording started. Zero disables this")
+}
+
+func (o *RecordOptions) Run(cmd *cobra.Command, args []string) error {
+	device, err := openGPIODevice(cmd)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	watcher, err := device.watcher(int(o.Pin))
+	if err != nil {
+		return fmt.Errorf("failed to create gpio watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.Duration)
+	defer cancel()
+
+	go handleSignals(cancel)
+
+	log.Infof("recording for at most %s, press the button on the remote control now", o.Duration)
+
+	rec := gpio.Record(watcher, o.MaxGap, ctx.Done())
+
+	if len(rec.Pulses) == 0 {
+		return fmt.Errorf("no signal recorded")
+	}
+
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = gpio.WriteRecording(f, rec)
+	if err != nil {
+		return fmt.Errorf("failed to write recording: %v", err)
+	}
+
+	log.WithField("pulses", len(rec.Pulses)).Infof("recording written to %s", args[0])
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/martinohmann/rfoutlet/internal/config"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func NewReplayCommand() *cobra.Command {
+	options := &ReplayOptions{
+		Pin:   config.DefaultTransmitPin,
+		Count: 1,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "replay <file>",
+		Short: "Replay raw signals recorded with the record command",
+		Long:  "The replay command sends out the raw pulse timings of a recording created with the record command verbatim.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return options.Run(cmd, args)
+		},
+	}
+
+	options.AddFlags(cmd)
+
+	return cmd
+}
+
+type ReplayOptions struct {
+	Pin   uint
+	Count int
+}
+
+func (o *ReplayOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().UintVar(&o.Pin, "pin", o.Pin, "gpio pin to transmit on")
+	cmd.Flags().IntVar(&o.Count, "count", o.Count, "number of times the recording should be sent out in a row")
+}
+
+func (o *ReplayOptions) Run(cmd *cobra.Command, args []string) error {
+	rec, err := gpio.LoadRecording(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to load recording: %v", err)
+	}
+
+	device, err := openGPIODevice(cmd)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	transmitter, err := device.transmitter(int(o.Pin))
+	if err != nil {
+		return fmt.Errorf("failed to create gpio transmitter: %v", err)
+	}
+	defer transmitter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go handleSignals(cancel)
+
+	log.WithField("pulses", len(rec.Pulses)).Infof("replaying %s", args[0])
+
+	for i := 0; i < o.Count; i++ {
+		if err := <-transmitter.TransmitRecording(ctx, rec, gpio.TransmitOptions{}); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("failed to replay recording: %v", err)
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,16 +17,28 @@ import (
 	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/config"
 	"github.com/martinohmann/rfoutlet/internal/controller"
+	"github.com/martinohmann/rfoutlet/internal/coverstop"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/martinohmann/rfoutlet/internal/startup"
 	"github.com/martinohmann/rfoutlet/internal/statedrift"
+	"github.com/martinohmann/rfoutlet/internal/statepoll"
+	"github.com/martinohmann/rfoutlet/internal/staterefresh"
 	"github.com/martinohmann/rfoutlet/internal/timeswitch"
+	"github.com/martinohmann/rfoutlet/internal/trigger"
+	"github.com/martinohmann/rfoutlet/internal/webhook"
 	"github.com/martinohmann/rfoutlet/internal/websocket"
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
-const webDir = "../web/build"
+const (
+	webDir = "../web/build"
+
+	// defaultRadio is the name under which the radio configured in the gpio
+	// section shows up in logs and metrics.
+	defaultRadio = "default"
+)
 
 func NewServeCommand() *cobra.Command {
 	options := &ServeOptions{
@@ -35,7 +48,7 @@ func NewServeCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "serve",
 		Short: "Serve the frontend for controlling outlets",
-		Long:  "The serve command starts a server which serves the frontend and connects clients through websockets for controlling outlets via web interface.",
+		Long:  "The serve command starts a server which serves the frontend and connects clients through websockets for controlling outlets via web interface.\n\nConfig values are loaded from the config file, the YAML fragments in the config dir and RFOUTLET_* environment variables (e.g. RFOUTLET_GPIO_TRANSMIT_PIN). Later sources take precedence over earlier ones, command line flags take precedence over all of them.",
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			return options.Run(cmd)
 		},
@@ -49,20 +62,38 @@ func NewServeCommand() *cobra.Command {
 type ServeOptions struct {
 	config.Config
 	ConfigFilename string
+	ConfigDir      string
+
+	GPIOSimulator          bool
+	GPIOSimulatorNoiseRate float64
+	GPIOSimulatorDropRate  float64
 }
 
 func (o *ServeOptions) AddFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVar(&o.ConfigFilename, "config", o.ConfigFilename, "path to the outlet config file")
+	cmd.Flags().StringVar(&o.ConfigFilename, "config", o.ConfigFilename, "path to the outlet config file. If empty, no config file is loaded")
+	cmd.Flags().StringVar(&o.ConfigDir, "config-dir", o.ConfigDir, "path to a directory containing YAML config fragments which are merged in lexical order on top of the config file (e.g. /etc/rfoutlet/conf.d)")
 	cmd.Flags().StringVar(&o.StateFile, "state-file", o.StateFile, "path to the file where outlet state and schedule should be stored")
 	cmd.Flags().StringVar(&o.ListenAddress, "listen-address", o.ListenAddress, "address to serve the web app on")
+	cmd.Flags().StringVar(&o.DebugListenAddress, "debug-listen-address", o.DebugListenAddress, "address to serve debug information like the duty-cycle stats on, e.g. localhost:3334. If empty, debug information is not served")
 	cmd.Flags().BoolVar(&o.DetectStateDrift, "detect-state-drift", o.DetectStateDrift, "detect state drift (e.g. if an outlet was switched via the phyical remote instead of rfoutlet)")
+	cmd.Flags().StringVar((*string)(&o.DriftPolicy), "drift-policy", string(o.DriftPolicy), `how state drift of outlets with an enabled schedule is handled. One of "enforce" (re-transmit the scheduled state), "override" (keep the detected state until the next schedule transition) or "ignore" (default "enforce")`)
+	cmd.Flags().StringVar((*string)(&o.StartupAction), "startup-action", string(o.StartupAction), `state that is transmitted to outlets on startup. One of "restore" (the state from the state file), "on", "off", "schedule" (the state demanded by the schedule) or "none" (default "none")`)
+	cmd.Flags().DurationVar((*time.Duration)(&o.StatePollInterval), "state-poll-interval", time.Duration(o.StatePollInterval), "interval in which the state of Wi-Fi plugs is read back from the device. If 0, an interval of 30s is used")
 	cmd.Flags().UintVar(&o.GPIO.TransmitPin, "transmit-pin", o.GPIO.TransmitPin, "gpio pin to transmit rf codes on")
 	cmd.Flags().UintVar(&o.GPIO.ReceivePin, "receive-pin", o.GPIO.ReceivePin, "gpio pin to receive rf codes on (this is used by the state drift detector)")
 	cmd.Flags().IntVar(&o.GPIO.TransmissionCount, "transmission-count", o.GPIO.TransmissionCount, "number of times a code should be transmitted in a row. The higher the value, the more likely it is that an outlet actually received the code")
+	cmd.Flags().BoolVar(&o.GPIO.LockOSThread, "lock-os-thread", o.GPIO.LockOSThread, "lock the goroutine transmitting rf codes to its own OS thread")
+	cmd.Flags().IntVar(&o.GPIO.RealtimePriority, "realtime-priority", o.GPIO.RealtimePriority, "real-time scheduling priority (1-99) for the thread transmitting rf codes. Requires root privileges, 0 disables real-time scheduling")
+	cmd.Flags().Float64Var(&o.GPIO.DutyCycle, "duty-cycle", o.GPIO.DutyCycle, "maximum fraction (e.g. 0.1 for 10%) of the duty-cycle window each transmitter may be on air. 0 disables duty-cycle limiting")
+	cmd.Flags().DurationVar((*time.Duration)(&o.GPIO.DutyCycleWindow), "duty-cycle-window", time.Duration(o.GPIO.DutyCycleWindow), "rolling window for duty-cycle limiting. If 0, a window of 1h is used")
+	cmd.Flags().StringVar((*string)(&o.GPIO.DutyCycleMode), "duty-cycle-mode", string(o.GPIO.DutyCycleMode), `whether transmissions exceeding the duty-cycle budget are delayed or rejected. One of "delay" or "reject" (default "delay")`)
+	cmd.Flags().BoolVar(&o.GPIOSimulator, "gpio-simulator", o.GPIOSimulator, "use a simulated in-process rf medium instead of a gpio device. Transmitted codes are received by the state drift detector, this is useful for demos and testing without hardware")
+	cmd.Flags().Float64Var(&o.GPIOSimulatorNoiseRate, "gpio-simulator-noise-rate", o.GPIOSimulatorNoiseRate, "probability between 0 and 1 that noise is injected after an edge on the simulated rf medium")
+	cmd.Flags().Float64Var(&o.GPIOSimulatorDropRate, "gpio-simulator-drop-rate", o.GPIOSimulatorDropRate, "probability between 0 and 1 that an edge is dropped on the simulated rf medium")
 }
 
 func (o *ServeOptions) Run(cmd *cobra.Command) error {
-	cfg, err := config.LoadWithDefaults(o.ConfigFilename)
+	cfg, err := config.LoadLayered(o.ConfigFilename, o.ConfigDir)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %v", err)
 	}
@@ -74,25 +105,103 @@ func (o *ServeOptions) Run(cmd *cobra.Command) error {
 
 	log.Debugf("merged config values: %#v", cfg)
 
+	err = cfg.GPIO.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid gpio config: %v", err)
+	}
+
+	groups, err := cfg.BuildOutletGroups()
+	if err != nil {
+		return fmt.Errorf("failed to build outlet groups: %v", err)
+	}
+
 	registry := outlet.NewRegistry()
 
-	err = registry.RegisterGroups(cfg.BuildOutletGroups()...)
+	err = registry.RegisterGroups(groups...)
 	if err != nil {
 		return fmt.Errorf("failed to register outlet groups: %v", err)
 	}
 
-	device, err := openGPIODevice(cmd)
+	radios, err := cfg.BuildRadios()
+	if err != nil {
+		return fmt.Errorf("failed to build radios: %v", err)
+	}
+
+	webhooks, err := cfg.BuildWebhooks()
+	if err != nil {
+		return fmt.Errorf("failed to build webhooks: %v", err)
+	}
+
+	triggers, err := cfg.BuildTriggers()
+	if err != nil {
+		return fmt.Errorf("failed to build triggers: %v", err)
+	}
+
+	devices := newDeviceSet(cmd)
+	defer devices.Close()
+
+	if o.GPIOSimulator {
+		log.WithFields(log.Fields{
+			"noiseRate": o.GPIOSimulatorNoiseRate,
+			"dropRate":  o.GPIOSimulatorDropRate,
+		}).Info("using simulated rf medium instead of gpio device")
+
+		devices.medium = gpio.NewMedium(
+			gpio.MediumNoiseRate(o.GPIOSimulatorNoiseRate),
+			gpio.MediumDropRate(o.GPIOSimulatorDropRate),
+		)
+	}
+
+	device, err := devices.get("")
 	if err != nil {
 		return err
 	}
-	defer device.Close()
 
-	transmitter, err := gpio.NewTransmitter(device.Chip, int(cfg.GPIO.TransmitPin), gpio.TransmissionCount(cfg.GPIO.TransmissionCount))
+	limiters := make(dutyCycleLimiters)
+
+	// Codes sent out by rfoutlet itself are recorded so that the state drift
+	// detector and the trigger handler can ignore their echoes.
+	var echoLog *gpio.EchoLog
+	if cfg.DetectStateDrift || len(triggers) > 0 {
+		echoLog = gpio.NewEchoLog(cfg.GPIO.EchoWindow.Duration(), cfg.GPIO.AllProtocols())
+	}
+
+	options, err := transmitterOptions(cfg.GPIO, defaultRadio, cfg.GPIO.TransmissionCount, limiters, echoLog)
+	if err != nil {
+		return err
+	}
+
+	transmitter, err := device.transmitter(int(cfg.GPIO.TransmitPin), options...)
 	if err != nil {
 		return fmt.Errorf("failed to create gpio transmitter: %v", err)
 	}
 	defer transmitter.Close()
 
+	rfSwitch := outlet.NewSwitch(transmitter)
+	rfSwitch.Protocols = cfg.GPIO.AllProtocols()
+
+	for _, radio := range radios {
+		device, err := devices.get(radio.Chip)
+		if err != nil {
+			return fmt.Errorf("radio %q: %v", radio.Name, err)
+		}
+
+		options, err := transmitterOptions(cfg.GPIO, radio.Name, radio.TransmissionCount, limiters, echoLog)
+		if err != nil {
+			return err
+		}
+
+		transmitter, err := device.transmitter(int(radio.TransmitPin), options...)
+		if err != nil {
+			return fmt.Errorf("radio %q: failed to create gpio transmitter: %v", radio.Name, err)
+		}
+		defer transmitter.Close()
+
+		rfSwitch.Transmitters[radio.Name] = transmitter
+	}
+
+	switcher := outlet.NewDispatchSwitch(rfSwitch)
+
 	if cfg.StateFile != "" {
 		log := log.WithField("stateFile", cfg.StateFile)
 
@@ -121,39 +230,201 @@ func (o *ServeOptions) Run(cmd *cobra.Command) error {
 	stopCh := ctx.Done()
 	commandQueue := make(chan command.Command)
 
+	// Wi-Fi plugs are switched in the background so that unreachable plugs
+	// do not block the controller. Failures are fed back as commands.
+	switchFailed := func(o *outlet.Outlet, state, previous outlet.State, _ error) {
+		select {
+		case commandQueue <- command.SwitchFailedCommand{Outlet: o, State: state, PreviousState: previous}:
+		case <-stopCh:
+		}
+	}
+
+	switcher.Switchers[outlet.DriverTasmota] = outlet.NewAsyncSwitch(outlet.NewTasmotaSwitch(), switchFailed)
+	switcher.Switchers[outlet.DriverShelly] = outlet.NewAsyncSwitch(outlet.NewShellySwitch(), switchFailed)
+
+	var dispatcher *webhook.Dispatcher
+	if len(webhooks) > 0 || len(triggers) > 0 {
+		dispatcher = webhook.NewDispatcher(webhooks)
+
+		go dispatcher.Run(stopCh)
+	}
+
+	// The state drift detector and the trigger handler each consume all
+	// codes received on any radio.
+	var consumers int
 	if cfg.DetectStateDrift {
-		receiver, err := gpio.NewReceiver(device.Chip, int(cfg.GPIO.ReceivePin))
+		consumers++
+	}
+
+	if len(triggers) > 0 {
+		consumers++
+	}
+
+	if consumers > 0 {
+		receiver, err := newMultiReceiver(cfg.GPIO, device, devices, radios)
 		if err != nil {
-			return fmt.Errorf("failed to create gpio receiver: %v", err)
+			return err
 		}
-		defer receiver.Close()
 
-		detector := statedrift.NewDetector(registry, receiver, commandQueue)
+		fanOut := gpio.NewFanOut(receiver, consumers)
+		defer fanOut.Close()
+
+		var next int
+
+		if cfg.DetectStateDrift {
+			detector := statedrift.NewDetector(registry, fanOut.Receiver(next), commandQueue)
+			detector.EchoLog = echoLog
+			next++
 
-		go detector.Run(stopCh)
+			go detector.Run(stopCh)
+		}
+
+		if len(triggers) > 0 {
+			handler := trigger.NewHandler(triggers, fanOut.Receiver(next), commandQueue, dispatcher)
+			handler.EchoLog = echoLog
+
+			go handler.Run(stopCh)
+		}
 	}
 
 	hub := websocket.NewHub()
 
 	controller := controller.Controller{
 		Registry:     registry,
-		Switcher:     outlet.NewSwitch(transmitter),
+		Switcher:     switcher,
 		Broadcaster:  hub,
 		CommandQueue: commandQueue,
 	}
 
+	if len(webhooks) > 0 {
+		controller.Notifier = dispatcher
+	}
+
 	timeSwitch := timeswitch.New(registry, commandQueue)
 
+	if cfg.StatePollInterval > 0 {
+		poller := statepoll.New(registry, switcher, commandQueue, cfg.StatePollInterval.Duration())
+
+		go poller.Run(stopCh)
+	}
+
+	if hasCovers(registry.GetOutlets()) {
+		stopper := coverstop.New(registry, commandQueue)
+
+		go stopper.Run(stopCh)
+	}
+
+	if needsRefresher(registry.GetOutlets()) {
+		refresher := staterefresh.New(registry, rfSwitch, commandQueue)
+
+		go refresher.Run(stopCh)
+	}
+
 	go handleSignals(cancel)
 	go controller.Run(stopCh)
 	go timeSwitch.Run(stopCh)
 	go hub.Run(stopCh)
+	go startup.New(registry, commandQueue).Run(stopCh)
+
+	if cfg.DebugListenAddress != "" {
+		debugListener, err := net.Listen("tcp", cfg.DebugListenAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on debug address: %v", err)
+		}
+
+		go func() {
+			err := serve(stopCh, setupDebugRouter(limiters), debugListener)
+			if err != nil {
+				log.Errorf("failed to shut down debug server: %v", err)
+			}
+		}()
+	}
 
 	router := setupRouter(hub, commandQueue)
 
 	return listenAndServe(stopCh, router, cfg.ListenAddress)
 }
 
+// hasCovers returns true if any of outlets is a cover.
+func hasCovers(outlets []*outlet.Outlet) bool {
+	for _, o := range outlets {
+		if o.Cover != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// needsRefresher returns true if any of outlets has a refresh interval.
+func needsRefresher(outlets []*outlet.Outlet) bool {
+	for _, o := range outlets {
+		if o.RefreshInterval > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newMultiReceiver creates a receiver which receives codes from the receiver
+// configured in the gpio section and the receivers of all radios.
+func newMultiReceiver(gpioConfig config.GPIOConfig, device *device, devices *deviceSet, radios []config.RadioConfig) (*gpio.MultiReceiver, error) {
+	receiver, err := device.receiver(int(gpioConfig.ReceivePin), gpioConfig.ReceiverOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gpio receiver: %v", err)
+	}
+
+	receivers := []gpio.CodeReceiver{receiver}
+
+	for _, radio := range radios {
+		if radio.ReceivePin == nil {
+			continue
+		}
+
+		device, err := devices.get(radio.Chip)
+		if err != nil {
+			return nil, fmt.Errorf("radio %q: %v", radio.Name, err)
+		}
+
+		receiver, err := device.receiver(int(*radio.ReceivePin), gpioConfig.ReceiverOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("radio %q: failed to create gpio receiver: %v", radio.Name, err)
+		}
+
+		receivers = append(receivers, receiver)
+	}
+
+	return gpio.NewMultiReceiver(receivers...), nil
+}
+
+// transmitterOptions returns the options for the transmitter of radio. If
+// duty-cycle limiting is enabled, the radio's limiter is added to limiters.
+// If echoLog is non-nil, the transmitter records sent codes in it.
+func transmitterOptions(gpioConfig config.GPIOConfig, radio string, transmissionCount int, limiters dutyCycleLimiters, echoLog *gpio.EchoLog) ([]gpio.TransmitterOption, error) {
+	limiter, err := gpioConfig.DutyCycleLimiter()
+	if err != nil {
+		return nil, fmt.Errorf("invalid duty-cycle config: %v", err)
+	}
+
+	options := append(
+		gpioConfig.TransmitterOptions(),
+		gpio.TransmissionCount(transmissionCount),
+		gpio.TransmitterTimingStats(transmissionLogger(radio, limiter)),
+	)
+
+	if limiter != nil {
+		limiters[radio] = limiter
+		options = append(options, gpio.TransmitterDutyCycle(limiter))
+	}
+
+	if echoLog != nil {
+		options = append(options, gpio.TransmitterEchoLog(echoLog))
+	}
+
+	return options, nil
+}
+
 func setupRouter(hub *websocket.Hub, commandQueue chan<- command.Command) http.Handler {
 	r := gin.New()
 	r.Use(gin.Recovery(), gin.Logger(), cors.Default())
@@ -165,16 +436,34 @@ func setupRouter(hub *websocket.Hub, commandQueue chan<- command.Command) http.H
 	return r
 }
 
+// setupDebugRouter creates the handler for the debug listen address. It only
+// serves the duty-cycle stats of the transmitters.
+func setupDebugRouter(limiters dutyCycleLimiters) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/duty-cycle", limiters)
+
+	return mux
+}
+
 func listenAndServe(stopCh <-chan struct{}, handler http.Handler, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return serve(stopCh, handler, listener)
+}
+
+// serve serves handler on listener until stopCh is closed.
+func serve(stopCh <-chan struct{}, handler http.Handler, listener net.Listener) error {
 	srv := &http.Server{
-		Addr:    addr,
 		Handler: handler,
 	}
 
 	go func() {
-		log.Infof("listening on %s", addr)
+		log.Infof("listening on %s", listener.Addr())
 
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Listen: %s\n", err)
 		}
 	}()
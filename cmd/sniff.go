@@ -2,23 +2,44 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/martinohmann/rfoutlet/internal/config"
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+const (
+	outputText = "text"
+	outputJSON = "json"
+
+	histogramWidth = 40
+)
+
 func NewSniffCommand() *cobra.Command {
 	options := &SniffOptions{
-		Pin: config.DefaultReceivePin,
+		Pin:              config.DefaultReceivePin,
+		Output:           outputText,
+		ReceiveTolerance: gpio.DefaultReceiveTolerance,
+		SeparationLimit:  gpio.DefaultSeparationLimit,
+		MaxChanges:       gpio.DefaultMaxChanges,
+		RepeatCount:      gpio.DefaultRepeatCount,
 	}
 
 	cmd := &cobra.Command{
 		Use:   "sniff",
 		Short: "Sniff codes sent out to remote controlled outlets",
-		Long:  "The sniff command can be used to sniff codes sent out to remote controlled outlets.",
+		Long:  "The sniff command can be used to sniff codes sent out to remote controlled outlets. When the command exits, a summary of all received codes is printed.",
 		RunE: func(cmd *cobra.Command, _ []string) error {
 			return options.Run(cmd)
 		},
@@ -30,21 +51,81 @@ func NewSniffCommand() *cobra.Command {
 }
 
 type SniffOptions struct {
-	Pin uint
+	Pin         uint
+	Output      string
+	DedupWindow time.Duration
+	Protocols   []int
+	BitLengths  []uint
+
+	ReceiveTolerance int64
+	SeparationLimit  int64
+	MaxChanges       uint
+	RepeatCount      uint
+	MinPulseWidth    int64
+
+	Unmatched bool
+	Analyze   bool
 }
 
 func (o *SniffOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().UintVar(&o.Pin, "pin", o.Pin, "gpio pin to sniff on")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", o.Output, "output format. Either text or json. The json format prints one json object per line")
+	cmd.Flags().DurationVar(&o.DedupWindow, "dedup-window", o.DedupWindow, "repeated frames of the same code are reported only once together with a repeat count if they are received within this window. Zero disables de-duplication")
+	cmd.Flags().IntSliceVar(&o.Protocols, "protocol", o.Protocols, "only report codes of these protocols")
+	cmd.Flags().UintSliceVar(&o.BitLengths, "bit-length", o.BitLengths, "only report codes with these bit lengths")
+	cmd.Flags().Int64Var(&o.ReceiveTolerance, "receive-tolerance", o.ReceiveTolerance, "tolerance in percent for received pulse lengths to still match a protocol")
+	cmd.Flags().Int64Var(&o.SeparationLimit, "separation-limit", o.SeparationLimit, "minimum length of the sync gap between two frames in microseconds")
+	cmd.Flags().UintVar(&o.MaxChanges, "max-changes", o.MaxChanges, "maximum number of level changes buffered for a single frame. A frame of n bits requires 2*n+2 changes")
+	cmd.Flags().Int64Var(&o.MinPulseWidth, "min-pulse-width", o.MinPulseWidth, "minimum width of received pulses in microseconds. Shorter pulses are discarded as noise. Zero disables the glitch filter")
+	cmd.Flags().BoolVar(&o.Unmatched, "unmatched", o.Unmatched, "report the raw timings of frames that do not match any known protocol")
+	cmd.Flags().BoolVar(&o.Analyze, "analyze", o.Analyze, "collect frames that do not match any known protocol and propose a protocol for them on exit which can be added to the gpio.protocols config")
+	cmd.Flags().UintVar(&o.RepeatCount, "repeat-count", o.RepeatCount, "number of sync gaps of the same length that have to be observed before a frame is decoded")
+}
+
+func (o *SniffOptions) Validate() error {
+	if o.Output != outputText && o.Output != outputJSON {
+		return fmt.Errorf("invalid output format %q, must be %s or %s", o.Output, outputText, outputJSON)
+	}
+
+	if o.ReceiveTolerance <= 0 || o.ReceiveTolerance > 100 {
+		return errors.New("--receive-tolerance must be between 1 and 100")
+	}
+
+	if o.SeparationLimit <= 0 {
+		return errors.New("--separation-limit must be greater than 0")
+	}
+
+	if o.MaxChanges < 7 || o.MaxChanges > gpio.MaxChangesLimit {
+		return fmt.Errorf("--max-changes must be between 7 and %d", gpio.MaxChangesLimit)
+	}
+
+	if o.RepeatCount < 1 {
+		return errors.New("--repeat-count must be greater than 0")
+	}
+
+	return nil
 }
 
 func (o *SniffOptions) Run(cmd *cobra.Command) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
 	device, err := openGPIODevice(cmd)
 	if err != nil {
 		return err
 	}
 	defer device.Close()
 
-	receiver, err := gpio.NewReceiver(device.Chip, int(o.Pin))
+	receiver, err := device.receiver(int(o.Pin),
+		gpio.ReceiverDedupWindow(o.DedupWindow),
+		gpio.ReceiverMinPulseWidth(o.MinPulseWidth),
+		gpio.ReceiverTolerance(o.ReceiveTolerance),
+		gpio.ReceiverSeparationLimit(o.SeparationLimit),
+		gpio.ReceiverMaxChanges(o.MaxChanges),
+		gpio.ReceiverRepeatCount(o.RepeatCount),
+		gpio.ReceiverUnmatchedFrames(o.Unmatched || o.Analyze),
+	)
 	if err != nil {
 		return fmt.Errorf("failed to create gpio receiver: %v", err)
 	}
@@ -55,16 +136,274 @@ func (o *SniffOptions) Run(cmd *cobra.Command) error {
 
 	go handleSignals(cancel)
 
+	s := newSniffer(cmd.OutOrStdout(), o.Output)
+
+	unmatched := receiver.Unmatched()
+
 	for {
 		select {
-		case res := <-receiver.Receive():
-			log.WithFields(log.Fields{
-				"pulseLength": res.PulseLength,
-				"protocol":    res.Protocol,
-				"bitlength":   res.BitLength,
-			}).Infof("received code %d", res.Code)
+		case res, ok := <-receiver.Receive():
+			if !ok {
+				return fmt.Errorf("receiver was closed unexpectedly")
+			}
+
+			if !o.matches(res) {
+				continue
+			}
+
+			s.record(res)
+			s.print(newSniffedCode(res))
+		case frame, ok := <-unmatched:
+			if !ok {
+				unmatched = nil
+				continue
+			}
+
+			if o.Analyze {
+				s.frames = append(s.frames, frame)
+			}
+
+			if o.Unmatched {
+				s.printUnmatched(frame)
+			}
 		case <-ctx.Done():
-			return nil
+			if o.Analyze {
+				if err := s.printAnalysis(); err != nil {
+					return err
+				}
+			}
+
+			return s.printSummary()
+		}
+	}
+}
+
+// matches returns true if res passes the protocol and bit length filters.
+func (o *SniffOptions) matches(res gpio.ReceiveResult) bool {
+	if len(o.Protocols) > 0 && !containsInt(o.Protocols, res.Protocol) {
+		return false
+	}
+
+	if len(o.BitLengths) > 0 && !containsUint(o.BitLengths, res.BitLength) {
+		return false
+	}
+
+	return true
+}
+
+// sniffedCode is a received code together with the number of times it was
+// received in a row.
+type sniffedCode struct {
+	Type        string    `json:"type"`
+	Code        uint64    `json:"code"`
+	Protocol    int       `json:"protocol"`
+	BitLength   uint      `json:"bitLength"`
+	PulseLength int64     `json:"pulseLength"`
+	Repeats     int       `json:"repeats"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+func newSniffedCode(res gpio.ReceiveResult) *sniffedCode {
+	return &sniffedCode{
+		Type:        "code",
+		Code:        res.Code,
+		Protocol:    res.Protocol,
+		BitLength:   res.BitLength,
+		PulseLength: res.PulseLength,
+		Repeats:     res.RepeatCount,
+		FirstSeen:   res.FirstSeen,
+		LastSeen:    res.LastSeen,
+	}
+}
+
+// codeKey identifies a code of a given protocol and bit length.
+type codeKey struct {
+	code      uint64
+	protocol  int
+	bitLength uint
+}
+
+// codeStats holds the statistics of a code for the summary.
+type codeStats struct {
+	Code           uint64 `json:"code"`
+	Protocol       int    `json:"protocol"`
+	BitLength      uint   `json:"bitLength"`
+	Count          int    `json:"count"`
+	AvgPulseLength int64  `json:"avgPulseLength"`
+
+	pulseLengthSum int64
+}
+
+// sniffer prints received codes and collects statistics about them.
+type sniffer struct {
+	w      io.Writer
+	output string
+	stats  map[codeKey]*codeStats
+	frames []gpio.Frame
+}
+
+func newSniffer(w io.Writer, output string) *sniffer {
+	return &sniffer{
+		w:      w,
+		output: output,
+		stats:  make(map[codeKey]*codeStats),
+	}
+}
+
+// record records res for the summary.
+func (s *sniffer) record(res gpio.ReceiveResult) {
+	key := codeKey{res.Code, res.Protocol, res.BitLength}
+
+	stats, ok := s.stats[key]
+	if !ok {
+		stats = &codeStats{
+			Code:      res.Code,
+			Protocol:  res.Protocol,
+			BitLength: res.BitLength,
 		}
+		s.stats[key] = stats
 	}
+
+	stats.Count += res.RepeatCount
+	stats.pulseLengthSum += res.PulseLength * int64(res.RepeatCount)
+	stats.AvgPulseLength = stats.pulseLengthSum / int64(stats.Count)
+}
+
+func (s *sniffer) print(c *sniffedCode) {
+	if s.output == outputJSON {
+		if err := json.NewEncoder(s.w).Encode(c); err != nil {
+			log.Errorf("failed to encode code: %v", err)
+		}
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"pulseLength": c.PulseLength,
+		"protocol":    c.Protocol,
+		"bitlength":   c.BitLength,
+		"repeats":     c.Repeats,
+	}).Infof("received code %d", c.Code)
+}
+
+func (s *sniffer) printUnmatched(frame gpio.Frame) {
+	if s.output == outputJSON {
+		err := json.NewEncoder(s.w).Encode(struct {
+			Type string `json:"type"`
+			gpio.Frame
+		}{"unmatched", frame})
+		if err != nil {
+			log.Errorf("failed to encode frame: %v", err)
+		}
+		return
+	}
+
+	log.WithField("changes", len(frame.Timings)).
+		Infof("received unmatched frame with timings %v", frame.Timings)
+}
+
+// printAnalysis tries to infer a protocol from the collected unmatched frames
+// and prints it in a format that can be pasted into the config file.
+func (s *sniffer) printAnalysis() error {
+	analysis, err := gpio.Analyze(s.frames)
+	if err != nil {
+		log.Warnf("failed to infer protocol from %d unmatched frames: %v", len(s.frames), err)
+		return nil
+	}
+
+	if s.output == outputJSON {
+		return json.NewEncoder(s.w).Encode(struct {
+			Type string `json:"type"`
+			*gpio.Analysis
+		}{"analysis", analysis})
+	}
+
+	buf, err := yaml.Marshal(map[string]interface{}{
+		"gpio": map[string]interface{}{
+			"protocols": []gpio.Protocol{analysis.Protocol},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.w, "Analyzed %d unmatched frames with %d bits and a pulse length of %d.\n", analysis.Frames, analysis.BitLength, analysis.PulseLength)
+	fmt.Fprintf(s.w, "Decoded codes: %s\n\n", joinCodes(analysis.Codes))
+	fmt.Fprintf(s.w, "Proposed protocol config (custom protocols are numbered starting at %d):\n\n%s\n", len(gpio.DefaultProtocols)+1, buf)
+
+	return nil
+}
+
+// printSummary prints a histogram of all received codes together with their
+// average pulse length.
+func (s *sniffer) printSummary() error {
+	stats := make([]*codeStats, 0, len(s.stats))
+
+	for _, st := range s.stats {
+		stats = append(stats, st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+
+		return stats[i].Code < stats[j].Code
+	})
+
+	if s.output == outputJSON {
+		return json.NewEncoder(s.w).Encode(struct {
+			Type  string       `json:"type"`
+			Codes []*codeStats `json:"codes"`
+		}{"summary", stats})
+	}
+
+	if len(stats) == 0 {
+		_, err := fmt.Fprintln(s.w, "No codes received.")
+		return err
+	}
+
+	maxCount := stats[0].Count
+
+	tw := tabwriter.NewWriter(s.w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "CODE\tPROTOCOL\tBITLENGTH\tAVG PULSE LENGTH\tCOUNT\t")
+
+	for _, st := range stats {
+		bar := strings.Repeat("#", (st.Count*histogramWidth+maxCount-1)/maxCount)
+
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\n", st.Code, st.Protocol, st.BitLength, st.AvgPulseLength, st.Count, bar)
+	}
+
+	return tw.Flush()
+}
+
+func joinCodes(codes []uint64) string {
+	s := make([]string, len(codes))
+
+	for i, code := range codes {
+		s[i] = strconv.FormatUint(code, 10)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsUint(values []uint, value uint) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/martinohmann/rfoutlet/internal/config"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// maxJitterRatio is the ratio of max jitter to pulse length above which the
+// timing-test command warns that outlets might ignore codes.
+const maxJitterRatio = 0.25
+
+func NewTimingTestCommand() *cobra.Command {
+	options := &TimingTestOptions{
+		PulseLength: config.DefaultPulseLength,
+		Pin:         config.DefaultTransmitPin,
+		Protocol:    config.DefaultProtocol,
+		Code:        5510451,
+		Count:       gpio.DefaultTransmissionCount,
+		Iterations:  10,
+		Delay:       100 * time.Millisecond,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "timing-test",
+		Short: "Measure the timing jitter of code transmissions",
+		Long:  "The timing-test command repeatedly transmits a code and reports how much the actual pulse durations deviate from the intended ones. This helps to diagnose why outlets sometimes ignore codes, e.g. on a busy system.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return options.Run(cmd)
+		},
+	}
+
+	options.AddFlags(cmd)
+
+	return cmd
+}
+
+type TimingTestOptions struct {
+	PulseLength      uint
+	Pin              uint
+	Protocol         int
+	Code             uint64
+	Count            int
+	Iterations       int
+	Delay            time.Duration
+	LockOSThread     bool
+	RealtimePriority int
+}
+
+func (o *TimingTestOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().UintVar(&o.PulseLength, "pulse-length", o.PulseLength, "pulse length")
+	cmd.Flags().UintVar(&o.Pin, "pin", o.Pin, "gpio pin to transmit on")
+	cmd.Flags().IntVar(&o.Protocol, "protocol", o.Protocol, "protocol to use for the transmission")
+	cmd.Flags().Uint64Var(&o.Code, "code", o.Code, "code to transmit")
+	cmd.Flags().IntVar(&o.Count, "count", o.Count, "number of times the code is transmitted in a row per iteration")
+	cmd.Flags().IntVar(&o.Iterations, "iterations", o.Iterations, "number of transmissions to measure")
+	cmd.Flags().DurationVar(&o.Delay, "delay", o.Delay, "delay between transmissions")
+	cmd.Flags().BoolVar(&o.LockOSThread, "lock-os-thread", o.LockOSThread, "lock the goroutine transmitting rf codes to its own OS thread")
+	cmd.Flags().IntVar(&o.RealtimePriority, "realtime-priority", o.RealtimePriority, "real-time scheduling priority (1-99) for the thread transmitting rf codes. Requires root privileges, 0 disables real-time scheduling")
+}
+
+func (o *TimingTestOptions) Validate() error {
+	if o.Protocol < 1 || o.Protocol > len(gpio.DefaultProtocols) {
+		return fmt.Errorf("protocol %d does not exist", o.Protocol)
+	}
+
+	if o.Iterations <= 0 {
+		return errors.New("--iterations must be greater than 0")
+	}
+
+	return nil
+}
+
+func (o *TimingTestOptions) Run(cmd *cobra.Command) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	proto := gpio.DefaultProtocols[o.Protocol-1]
+
+	device, err := openGPIODevice(cmd)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	// The handler is called before the transmission is marked as done, so
+	// stats is safe to read once the transmit channel was closed.
+	var stats []gpio.TimingStats
+
+	transmitter, err := device.transmitter(
+		int(o.Pin),
+		gpio.TransmissionCount(o.Count),
+		gpio.TransmitterLockOSThread(o.LockOSThread),
+		gpio.TransmitterRealtimePriority(o.RealtimePriority),
+		gpio.TransmitterTimingStats(func(s gpio.TimingStats) { stats = append(stats, s) }),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create gpio transmitter: %v", err)
+	}
+	defer transmitter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go handleSignals(cancel)
+
+	log.WithFields(log.Fields{
+		"code":        o.Code,
+		"pulseLength": o.PulseLength,
+		"protocol":    o.Protocol,
+		"iterations":  o.Iterations,
+	}).Info("starting timing test")
+
+	for i := 0; i < o.Iterations; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(o.Delay):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		if err := <-transmitter.Transmit(ctx, o.Code, proto, o.PulseLength, gpio.TransmitOptions{}); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("failed to transmit code %d: %v", o.Code, err)
+		}
+	}
+
+	return printTimingStats(cmd.OutOrStdout(), stats, time.Duration(o.PulseLength)*time.Microsecond)
+}
+
+// printTimingStats prints a table of stats followed by a summary to w. A
+// warning is printed if the max jitter is large compared to pulseLength.
+func printTimingStats(w io.Writer, stats []gpio.TimingStats, pulseLength time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "#\tPULSES\tMEAN JITTER\tMAX JITTER\tSTDDEV\t")
+
+	var (
+		pulses  int
+		sumMean time.Duration
+		max     time.Duration
+	)
+
+	for i, s := range stats {
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t\n", i+1, s.Pulses, s.MeanJitter, s.MaxJitter, s.StdDev)
+
+		pulses += s.Pulses
+		sumMean += s.MeanJitter * time.Duration(s.Pulses)
+
+		if s.MaxJitter > max {
+			max = s.MaxJitter
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if pulses == 0 {
+		return nil
+	}
+
+	ratio := float64(max) / float64(pulseLength)
+
+	fmt.Fprintf(w, "\nMean jitter %s, max jitter %s (%.0f%% of the pulse length) across %d pulses.\n", sumMean/time.Duration(pulses), max, ratio*100, pulses)
+
+	if ratio > maxJitterRatio {
+		fmt.Fprintln(w, "The max jitter is high, outlets might ignore codes. Consider using --lock-os-thread or --realtime-priority.")
+	}
+
+	return nil
+}
+
+// transmissionLogger returns a handler for the timing stats of transmissions
+// on radio. If limiter is non-nil, the remaining duty-cycle budget is logged
+// as well.
+func transmissionLogger(radio string, limiter *gpio.DutyCycleLimiter) func(gpio.TimingStats) {
+	return func(stats gpio.TimingStats) {
+		fields := log.Fields{
+			"radio":      radio,
+			"key":        stats.Key,
+			"pulses":     stats.Pulses,
+			"meanJitter": stats.MeanJitter,
+			"maxJitter":  stats.MaxJitter,
+			"stdDev":     stats.StdDev,
+		}
+
+		if limiter != nil {
+			fields["dutyCycleRemaining"] = limiter.Stats().Remaining
+		}
+
+		log.WithFields(fields).Debug("transmission done")
+	}
+}
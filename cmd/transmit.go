@@ -52,6 +52,9 @@ func (o *TransmitOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().IntVar(&o.Count, "count", o.Count, "number of times a code should be transmitted in a row. The higher the value, the more likely it is that an outlet actually received the code")
 	cmd.Flags().DurationVar(&o.Delay, "delay", o.Delay, "delay between code transmissions")
 	cmd.Flags().BoolVar(&o.Infinite, "infinite", o.Infinite, "restart the transmission of codes after the last one was sent")
+	cmd.Flags().Float64Var(&o.GPIO.DutyCycle, "duty-cycle", o.GPIO.DutyCycle, "maximum fraction (e.g. 0.1 for 10%) of the duty-cycle window the transmitter may be on air. 0 disables duty-cycle limiting")
+	cmd.Flags().DurationVar((*time.Duration)(&o.GPIO.DutyCycleWindow), "duty-cycle-window", time.Duration(o.GPIO.DutyCycleWindow), "rolling window for duty-cycle limiting. If 0, a window of 1h is used")
+	cmd.Flags().StringVar((*string)(&o.GPIO.DutyCycleMode), "duty-cycle-mode", string(o.GPIO.DutyCycleMode), `whether transmissions exceeding the duty-cycle budget are delayed or rejected. One of "delay" or "reject" (default "delay")`)
 }
 
 func (o *TransmitOptions) Run(cmd *cobra.Command, args []string) error {
@@ -77,7 +80,12 @@ func (o *TransmitOptions) Run(cmd *cobra.Command, args []string) error {
 	}
 	defer device.Close()
 
-	transmitter, err := gpio.NewTransmitter(device.Chip, int(o.Pin), gpio.TransmissionCount(o.Count))
+	limiter, err := o.GPIO.DutyCycleLimiter()
+	if err != nil {
+		return fmt.Errorf("invalid duty-cycle config: %v", err)
+	}
+
+	transmitter, err := device.transmitter(int(o.Pin), gpio.TransmissionCount(o.Count), gpio.TransmitterDutyCycle(limiter))
 	if err != nil {
 		return fmt.Errorf("failed to create gpio transmitter: %v", err)
 	}
@@ -99,14 +107,20 @@ Loop:
 	for _, code := range codes {
 		log.Infof("transmitting code %d", code)
 
-		select {
-		case <-transmitter.Transmit(code, proto, o.PulseLength):
-			select {
-			case <-time.After(o.Delay):
-			case <-ctx.Done():
+		if err := <-transmitter.Transmit(ctx, code, proto, o.PulseLength, gpio.TransmitOptions{}); err != nil {
+			if ctx.Err() != nil {
 				return nil
 			}
 
+			return fmt.Errorf("failed to transmit code %d: %v", code, err)
+		}
+
+		if limiter != nil {
+			log.WithField("remaining", limiter.Stats().Remaining).Info("duty-cycle budget")
+		}
+
+		select {
+		case <-time.After(o.Delay):
 		case <-ctx.Done():
 			return nil
 		}
@@ -22,6 +22,58 @@ type Command interface {
 	Execute(context Context) (broadcast bool, err error)
 }
 
+// Source describes where a command originated from.
+type Source string
+
+// Known command sources.
+const (
+	// SourceUser is the source of commands sent by connected clients.
+	SourceUser Source = "user"
+	// SourceSchedule is the source of commands sent by the time switch.
+	SourceSchedule Source = "schedule"
+	// SourceStateDrift is the source of commands sent by the state drift
+	// detector.
+	SourceStateDrift Source = "statedrift"
+	// SourceStatePoll is the source of commands sent by the state poller.
+	SourceStatePoll Source = "statepoll"
+	// SourceRefresh is the source of commands sent by the state refresher.
+	SourceRefresh Source = "refresh"
+	// SourceStartup is the source of commands sent on startup.
+	SourceStartup Source = "startup"
+	// SourceTrigger is the source of commands sent by the trigger handler.
+	SourceTrigger Source = "trigger"
+	// SourceCoverStop is the source of commands sent by the cover stopper.
+	SourceCoverStop Source = "coverstop"
+	// SourceSwitchFailure is the source of commands sent if the device of an
+	// outlet failed to switch.
+	SourceSwitchFailure Source = "switchfailure"
+)
+
+// SourceOf returns the source of cmd. Commands that do not carry a source
+// are assumed to be sent by a user.
+func SourceOf(cmd Command) Source {
+	switch c := cmd.(type) {
+	case StateCorrectionCommand:
+		if c.Source != "" {
+			return c.Source
+		}
+	case CoverPositionCommand:
+		if c.Source != "" {
+			return c.Source
+		}
+	case CoverStopCommand:
+		return SourceCoverStop
+	case RefreshCommand:
+		return SourceRefresh
+	case SwitchFailedCommand:
+		return SourceSwitchFailure
+	case TriggerCommand:
+		return SourceTrigger
+	}
+
+	return SourceUser
+}
+
 // Sender can send messages.
 type Sender interface {
 	// Send sends out a message.
@@ -51,6 +103,8 @@ type Type string
 
 // Supported command types.
 const (
+	ActionType   Type = "action"
+	CoverType    Type = "cover"
 	GroupType    Type = "group"
 	IntervalType Type = "interval"
 	OutletType   Type = "outlet"
@@ -78,3 +132,14 @@ const (
 	UpdateIntervalAction IntervalAction = "update"
 	DeleteIntervalAction IntervalAction = "delete"
 )
+
+// CoverAction is the type of an action that can be performed on a cover.
+type CoverAction string
+
+// Supported cover command actions.
+const (
+	OpenCoverAction     CoverAction = "open"
+	CloseCoverAction    CoverAction = "close"
+	StopCoverAction     CoverAction = "stop"
+	PositionCoverAction CoverAction = "position"
+)
@@ -3,6 +3,8 @@ package command
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/internal/outlet"
 	"github.com/martinohmann/rfoutlet/internal/schedule"
@@ -52,6 +54,14 @@ func (c OutletCommand) Execute(context Context) (bool, error) {
 		return false, fmt.Errorf("outlet %q does not exist", c.OutletID)
 	}
 
+	if outlet.Cover != nil {
+		return false, fmt.Errorf("outlet %q is a cover and can only be controlled via cover commands", c.OutletID)
+	}
+
+	if outlet.Stateless {
+		return false, fmt.Errorf("outlet %q is stateless and can only be controlled via actions", c.OutletID)
+	}
+
 	if outlet.Schedule.Enabled() {
 		return false, nil
 	}
@@ -91,7 +101,7 @@ func (c GroupCommand) Execute(context Context) (bool, error) {
 	var modified bool
 
 	for _, outlet := range group.Outlets {
-		if outlet.Schedule.Enabled() {
+		if outlet.Stateless || outlet.Schedule.Enabled() {
 			continue
 		}
 
@@ -111,6 +121,239 @@ func (c GroupCommand) Execute(context Context) (bool, error) {
 	return modified, nil
 }
 
+// ActionCommand performs a named action on an outlet, e.g. stopping blinds.
+type ActionCommand struct {
+	// OutletID is the ID of the outlet that the action should be performed
+	// on.
+	OutletID string `json:"outletID"`
+	// Action is the name of the action.
+	Action string `json:"action"`
+}
+
+// Execute implements Command.
+//
+// It performs the action on the outlet. Actions that change the outlet state
+// are not performed while the outlet's schedule is enabled.
+func (c ActionCommand) Execute(context Context) (bool, error) {
+	o, ok := context.GetOutlet(c.OutletID)
+	if !ok {
+		return false, fmt.Errorf("outlet %q does not exist", c.OutletID)
+	}
+
+	action, ok := o.GetAction(c.Action)
+	if !ok {
+		return false, fmt.Errorf("outlet %q does not have action %q", c.OutletID, c.Action)
+	}
+
+	if action.State != nil && o.Schedule.Enabled() {
+		return false, nil
+	}
+
+	switcher, ok := context.Switcher.(outlet.ActionSwitcher)
+	if !ok {
+		return false, fmt.Errorf("switcher does not support actions")
+	}
+
+	previousState := o.GetState()
+
+	err := switcher.SwitchAction(o, action)
+	if err != nil {
+		return false, err
+	}
+
+	return o.GetState() != previousState, nil
+}
+
+// CoverCommand opens, closes or stops a cover or moves it to a position.
+type CoverCommand struct {
+	// OutletID is the ID of the cover.
+	OutletID string `json:"outletID"`
+	// Action defines the action type that should be performed on the cover.
+	Action CoverAction `json:"action"`
+	// Position is the target position in percent for PositionCoverAction.
+	Position int `json:"position"`
+}
+
+// Execute implements Command.
+//
+// It starts moving the cover or stops it. Covers are not moved while their
+// schedule is enabled, stopping them is always possible.
+func (c CoverCommand) Execute(context Context) (bool, error) {
+	o, ok := context.GetOutlet(c.OutletID)
+	if !ok {
+		return false, fmt.Errorf("outlet %q does not exist", c.OutletID)
+	}
+
+	if o.Cover == nil {
+		return false, fmt.Errorf("outlet %q is not a cover", c.OutletID)
+	}
+
+	now := time.Now()
+
+	if c.Action == StopCoverAction {
+		return stopCover(context, o, now)
+	}
+
+	if o.Schedule.Enabled() {
+		return false, nil
+	}
+
+	target, err := getTargetPosition(c.Action, c.Position)
+	if err != nil {
+		return false, err
+	}
+
+	return moveCover(context, o, target, now)
+}
+
+// CoverPositionCommand is sent out whenever a cover should be moved to a
+// position, e.g. because its schedule demands it.
+type CoverPositionCommand struct {
+	// Outlet is the cover that should be moved.
+	Outlet *outlet.Outlet
+	// Position is the position in percent the cover should be moved to.
+	Position int
+	// Source is the component that requested the move.
+	Source Source
+}
+
+// Execute implements Command.
+//
+// It moves the cover to the desired position.
+func (c CoverPositionCommand) Execute(context Context) (bool, error) {
+	return moveCover(context, c.Outlet, c.Position, time.Now())
+}
+
+// CoverStopCommand is sent out whenever a moving cover reached its target
+// position.
+type CoverStopCommand struct {
+	// Outlet is the cover that reached its target.
+	Outlet *outlet.Outlet
+	// Arrival is the time at which the cover was expected to reach its
+	// target.
+	Arrival time.Time
+}
+
+// Execute implements Command.
+//
+// It stops the cover at its target position. Covers moving to an end position
+// stop by themselves, so the stop code is only sent for intermediate
+// positions. If the cover was moved again after the command was submitted,
+// it is ignored.
+func (c CoverStopCommand) Execute(context Context) (bool, error) {
+	cover := c.Outlet.Cover
+
+	arrival, ok := cover.Arrival()
+	if !ok || !arrival.Equal(c.Arrival) {
+		return false, nil
+	}
+
+	target, _ := cover.Target()
+
+	if target != outlet.PositionOpen && target != outlet.PositionClosed {
+		switcher, err := coverSwitcher(context)
+		if err != nil {
+			return false, err
+		}
+
+		err = switcher.SwitchCover(c.Outlet, outlet.MotionStopped)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	cover.Arrive()
+
+	return true, nil
+}
+
+// moveCover starts moving the cover of o from its estimated position at now
+// to target. Codes are only sent if the cover has to change its direction.
+func moveCover(context Context, o *outlet.Outlet, target int, now time.Time) (bool, error) {
+	switcher, err := coverSwitcher(context)
+	if err != nil {
+		return false, err
+	}
+
+	cover := o.Cover
+	current := cover.Motion()
+	motion := cover.MotionTo(target, now)
+
+	if motion == outlet.MotionStopped {
+		// The motor of a cover stops by itself once it reached an end
+		// position, so the code is sent even if the cover is believed to be
+		// there already. This corrects the estimated position if the cover
+		// was moved by other means.
+		switch target {
+		case outlet.PositionOpen:
+			motion = outlet.MotionUp
+		case outlet.PositionClosed:
+			motion = outlet.MotionDown
+		default:
+			if current == outlet.MotionStopped {
+				return false, nil
+			}
+		}
+	}
+
+	if motion != current {
+		err = switcher.SwitchCover(o, motion)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	cover.Move(target, now)
+
+	return true, nil
+}
+
+// stopCover stops the cover of o at its estimated position at now. The stop
+// code is always sent as the cover may have been moved by other means.
+func stopCover(context Context, o *outlet.Outlet, now time.Time) (bool, error) {
+	switcher, err := coverSwitcher(context)
+	if err != nil {
+		return false, err
+	}
+
+	err = switcher.SwitchCover(o, outlet.MotionStopped)
+	if err != nil {
+		return false, err
+	}
+
+	moving := o.Cover.Motion() != outlet.MotionStopped
+
+	o.Cover.Stop(now)
+
+	return moving, nil
+}
+
+func coverSwitcher(context Context) (outlet.CoverSwitcher, error) {
+	switcher, ok := context.Switcher.(outlet.CoverSwitcher)
+	if !ok {
+		return nil, fmt.Errorf("switcher does not support covers")
+	}
+
+	return switcher, nil
+}
+
+func getTargetPosition(action CoverAction, position int) (int, error) {
+	switch action {
+	case OpenCoverAction:
+		return outlet.PositionOpen, nil
+	case CloseCoverAction:
+		return outlet.PositionClosed, nil
+	case PositionCoverAction:
+		if position < outlet.PositionClosed || position > outlet.PositionOpen {
+			return 0, fmt.Errorf("invalid cover position %d, expected value between %d and %d", position, outlet.PositionClosed, outlet.PositionOpen)
+		}
+
+		return position, nil
+	default:
+		return 0, fmt.Errorf("invalid cover action %q", action)
+	}
+}
+
 // IntervalCommand changes the intervals of an outlet based on the action.
 type IntervalCommand struct {
 	// OutletID is the ID of the outlet where the intervals of the schedule
@@ -140,14 +383,18 @@ func (c IntervalCommand) Execute(context Context) (bool, error) {
 	return true, nil
 }
 
-func (c IntervalCommand) handle(outlet *outlet.Outlet) error {
+func (c IntervalCommand) handle(o *outlet.Outlet) error {
+	if p := c.Interval.Position; p != nil && (*p < outlet.PositionClosed || *p > outlet.PositionOpen) {
+		return fmt.Errorf("invalid interval position %d, expected value between %d and %d", *p, outlet.PositionClosed, outlet.PositionOpen)
+	}
+
 	switch c.Action {
 	case CreateIntervalAction:
-		return outlet.Schedule.AddInterval(c.Interval)
+		return o.Schedule.AddInterval(c.Interval)
 	case UpdateIntervalAction:
-		return outlet.Schedule.UpdateInterval(c.Interval)
+		return o.Schedule.UpdateInterval(c.Interval)
 	case DeleteIntervalAction:
-		return outlet.Schedule.DeleteInterval(c.Interval)
+		return o.Schedule.DeleteInterval(c.Interval)
 	default:
 		return fmt.Errorf("invalid interval action %q", c.Action)
 	}
@@ -177,15 +424,23 @@ type StateCorrectionCommand struct {
 	Outlet *outlet.Outlet
 	// DesiredState is the state that the outlet should be in.
 	DesiredState outlet.State
+	// Source is the component that detected the need for a state correction.
+	Source Source
+	// Force causes DesiredState to be transmitted even if the outlet is
+	// believed to be in that state already, e.g. to undo a state change
+	// caused by a physical remote control.
+	Force bool
 }
 
 // Execute implements Command.
 //
 // It switch an outlet to the detected state.
 func (c StateCorrectionCommand) Execute(context Context) (bool, error) {
+	previousState := c.Outlet.GetState()
+
 	// If the outlet was already switched to the desired state after we
 	// submitted the command, we can bail out early.
-	if c.Outlet.GetState() == c.DesiredState {
+	if !c.Force && previousState == c.DesiredState {
 		return false, nil
 	}
 
@@ -194,5 +449,99 @@ func (c StateCorrectionCommand) Execute(context Context) (bool, error) {
 		return false, err
 	}
 
+	// Forced transmissions of the current state do not need to be
+	// broadcasted.
+	return previousState != c.DesiredState, nil
+}
+
+// RefreshCommand is sent out whenever the current state of an outlet should
+// be re-transmitted.
+type RefreshCommand struct {
+	// Outlet is the outlet whose state should be re-transmitted.
+	Outlet *outlet.Outlet
+}
+
+// Execute implements Command.
+//
+// It re-transmits the state the outlet is in at the time the command is
+// executed, so that state changes queued before the refresh are not undone.
+// Refreshes never change the outlet state and are not broadcasted.
+func (c RefreshCommand) Execute(context Context) (bool, error) {
+	return false, context.Switch(c.Outlet, c.Outlet.GetState())
+}
+
+// SwitchFailedCommand is sent out whenever the device of an outlet, e.g. a
+// Wi-Fi plug, failed to switch in the background.
+type SwitchFailedCommand struct {
+	// Outlet is the outlet that failed to switch.
+	Outlet *outlet.Outlet
+	// State is the state the outlet should have been switched into.
+	State outlet.State
+	// PreviousState is the state of the outlet before the failed switch.
+	PreviousState outlet.State
+}
+
+// Execute implements Command.
+//
+// It reverts the outlet to its previous state, unless it was switched again
+// in the meantime.
+func (c SwitchFailedCommand) Execute(context Context) (bool, error) {
+	if c.Outlet.GetState() != c.State || c.State == c.PreviousState {
+		return false, nil
+	}
+
+	c.Outlet.SetState(c.PreviousState)
+
 	return true, nil
 }
+
+// TriggerCommand is sent out whenever a received rf code matched a trigger.
+// Exactly one of OutletID, GroupID or Scene should be set.
+type TriggerCommand struct {
+	// Trigger is the name of the trigger that fired.
+	Trigger string
+	// OutletID is the ID of the outlet that Action should be performed on.
+	OutletID string
+	// GroupID is the ID of the outlet group that Action should be performed
+	// on.
+	GroupID string
+	// Action defines the action type that should be performed on the outlet
+	// or outlet group.
+	Action OutletAction
+	// Scene maps outlet IDs to the actions that should be performed on
+	// them.
+	Scene map[string]OutletAction
+}
+
+// Execute implements Command.
+//
+// It switches an outlet, an outlet group or all outlets of a scene. Outlets
+// with enabled schedule are not switched.
+func (c TriggerCommand) Execute(context Context) (bool, error) {
+	switch {
+	case c.OutletID != "":
+		return OutletCommand{OutletID: c.OutletID, Action: c.Action}.Execute(context)
+	case c.GroupID != "":
+		return GroupCommand{GroupID: c.GroupID, Action: c.Action}.Execute(context)
+	}
+
+	ids := make([]string, 0, len(c.Scene))
+	for id := range c.Scene {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	var modified bool
+
+	for _, id := range ids {
+		broadcast, err := OutletCommand{OutletID: id, Action: c.Scene[id]}.Execute(context)
+		if err != nil {
+			return modified, err
+		}
+
+		modified = modified || broadcast
+	}
+
+	return modified, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/internal/outlet"
 	"github.com/martinohmann/rfoutlet/internal/schedule"
@@ -37,6 +38,27 @@ func TestStatusCommand(t *testing.T) {
 	assert.Equal(t, `[{"id":"foo","displayName":"","outlets":null}]`, s.buf.String())
 }
 
+func TestStatusCommand_Actions(t *testing.T) {
+	ctx, r, _ := NewTestContext()
+
+	s := &fakeSender{}
+
+	r.RegisterGroups(&outlet.Group{
+		ID: "foo",
+		Outlets: []*outlet.Outlet{
+			{ID: "bar", Stateless: true, Actions: []outlet.Action{{Name: "stop", DisplayName: "Stop", Code: 123}}},
+		},
+	})
+
+	cmd := StatusCommand{}
+	cmd.SetSender(s)
+
+	_, err := cmd.Execute(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, `[{"id":"foo","displayName":"","outlets":[{"id":"bar","displayName":"","schedule":null,"state":0,"actions":[{"name":"stop","displayName":"Stop"}],"stateless":true}]}]`, s.buf.String())
+}
+
 func TestOutletCommand(t *testing.T) {
 	ctx, r, _ := NewTestContext()
 
@@ -53,6 +75,189 @@ func TestOutletCommand(t *testing.T) {
 	assert.Equal(t, outlet.StateOn, o.GetState())
 }
 
+func TestOutletCommand_Stateless(t *testing.T) {
+	ctx, r, _ := NewTestContext()
+
+	r.RegisterOutlets(&outlet.Outlet{ID: "foo", Stateless: true})
+
+	_, err := OutletCommand{"foo", "on"}.Execute(ctx)
+	require.EqualError(t, err, `outlet "foo" is stateless and can only be controlled via actions`)
+}
+
+func TestActionCommand(t *testing.T) {
+	ctx, r, s := NewTestContext()
+
+	on := outlet.StateOn
+
+	o := &outlet.Outlet{
+		ID: "foo",
+		Actions: []outlet.Action{
+			{Name: "stop", Code: 1},
+			{Name: "brighter", Code: 2, State: &on},
+		},
+	}
+
+	r.RegisterOutlets(o)
+
+	broadcast, err := ActionCommand{OutletID: "foo", Action: "stop"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.False(t, broadcast)
+	assert.Equal(t, outlet.StateOff, o.GetState())
+
+	broadcast, err = ActionCommand{OutletID: "foo", Action: "brighter"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.StateOn, o.GetState())
+
+	_, err = ActionCommand{OutletID: "foo", Action: "dimmer"}.Execute(ctx)
+	require.EqualError(t, err, `outlet "foo" does not have action "dimmer"`)
+
+	_, err = ActionCommand{OutletID: "bar", Action: "stop"}.Execute(ctx)
+	require.EqualError(t, err, `outlet "bar" does not exist`)
+
+	s.Err = errors.New("whoops")
+
+	_, err = ActionCommand{OutletID: "foo", Action: "stop"}.Execute(ctx)
+	require.EqualError(t, err, "whoops")
+}
+
+// coverSwitch records the motions sent to covers.
+type coverSwitch struct {
+	outlet.FakeSwitch
+	motions []outlet.Motion
+}
+
+func (s *coverSwitch) SwitchCover(o *outlet.Outlet, motion outlet.Motion) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	s.motions = append(s.motions, motion)
+
+	return nil
+}
+
+func TestCoverCommand(t *testing.T) {
+	r := outlet.NewRegistry()
+	s := &coverSwitch{}
+	ctx := Context{Registry: r, Switcher: s}
+
+	o := &outlet.Outlet{
+		ID:    "foo",
+		Cover: &outlet.Cover{TravelTimeUp: time.Hour, TravelTimeDown: time.Hour},
+	}
+
+	r.RegisterOutlets(o, &outlet.Outlet{ID: "bar"})
+
+	o.Cover.SetPosition(50)
+
+	broadcast, err := CoverCommand{OutletID: "foo", Action: "position", Position: 40}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.MotionDown, o.Cover.Motion())
+
+	// Changing the target in the same direction does not send a code.
+	broadcast, err = CoverCommand{OutletID: "foo", Action: "close"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+
+	target, _ := o.Cover.Target()
+	assert.Equal(t, outlet.PositionClosed, target)
+
+	broadcast, err = CoverCommand{OutletID: "foo", Action: "open"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.MotionUp, o.Cover.Motion())
+
+	broadcast, err = CoverCommand{OutletID: "foo", Action: "stop"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.MotionStopped, o.Cover.Motion())
+	assert.Equal(t, 50, o.Cover.Position(time.Now()))
+
+	// Covers believed to be at an end position receive the code anyway.
+	o.Cover.SetPosition(outlet.PositionClosed)
+
+	_, err = CoverCommand{OutletID: "foo", Action: "close"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, outlet.MotionStopped, o.Cover.Motion())
+
+	// Covers already at an intermediate target are left alone.
+	o.Cover.SetPosition(40)
+
+	broadcast, err = CoverCommand{OutletID: "foo", Action: "position", Position: 40}.Execute(ctx)
+	require.NoError(t, err)
+	assert.False(t, broadcast)
+
+	assert.Equal(t, []outlet.Motion{outlet.MotionDown, outlet.MotionUp, outlet.MotionStopped, outlet.MotionDown}, s.motions)
+
+	_, err = CoverCommand{OutletID: "foo", Action: "position", Position: 101}.Execute(ctx)
+	require.EqualError(t, err, "invalid cover position 101, expected value between 0 and 100")
+
+	_, err = CoverCommand{OutletID: "foo", Action: "tilt"}.Execute(ctx)
+	require.EqualError(t, err, `invalid cover action "tilt"`)
+
+	_, err = CoverCommand{OutletID: "bar", Action: "open"}.Execute(ctx)
+	require.EqualError(t, err, `outlet "bar" is not a cover`)
+
+	_, err = OutletCommand{OutletID: "foo", Action: "on"}.Execute(ctx)
+	require.EqualError(t, err, `outlet "foo" is a cover and can only be controlled via cover commands`)
+
+	// Covers are not moved while their schedule is enabled.
+	o.Schedule = schedule.NewWithIntervals([]schedule.Interval{{Enabled: true}})
+
+	broadcast, err = CoverCommand{OutletID: "foo", Action: "open"}.Execute(ctx)
+	require.NoError(t, err)
+	assert.False(t, broadcast)
+	assert.Equal(t, outlet.MotionStopped, o.Cover.Motion())
+
+	s.Err = errors.New("whoops")
+
+	_, err = CoverCommand{OutletID: "foo", Action: "stop"}.Execute(ctx)
+	require.EqualError(t, err, "whoops")
+}
+
+func TestCoverStopCommand(t *testing.T) {
+	r := outlet.NewRegistry()
+	s := &coverSwitch{}
+	ctx := Context{Registry: r, Switcher: s}
+
+	o := &outlet.Outlet{
+		ID:    "foo",
+		Cover: &outlet.Cover{TravelTimeUp: time.Second, TravelTimeDown: time.Second},
+	}
+
+	broadcast, err := CoverPositionCommand{Outlet: o, Position: 40, Source: SourceSchedule}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+
+	arrival, ok := o.Cover.Arrival()
+	require.True(t, ok)
+
+	// Stale commands are ignored.
+	broadcast, err = CoverStopCommand{Outlet: o, Arrival: arrival.Add(time.Second)}.Execute(ctx)
+	require.NoError(t, err)
+	assert.False(t, broadcast)
+
+	broadcast, err = CoverStopCommand{Outlet: o, Arrival: arrival}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.MotionStopped, o.Cover.Motion())
+	assert.Equal(t, 40, o.Cover.Position(time.Now()))
+
+	// Covers stop by themselves at end positions.
+	_, err = CoverPositionCommand{Outlet: o, Position: outlet.PositionOpen}.Execute(ctx)
+	require.NoError(t, err)
+
+	arrival, _ = o.Cover.Arrival()
+
+	_, err = CoverStopCommand{Outlet: o, Arrival: arrival}.Execute(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, outlet.PositionOpen, o.Cover.Position(time.Now()))
+
+	assert.Equal(t, []outlet.Motion{outlet.MotionUp, outlet.MotionStopped, outlet.MotionUp}, s.motions)
+}
+
 func TestGroupCommand(t *testing.T) {
 	ctx, r, _ := NewTestContext()
 
@@ -124,6 +329,7 @@ func TestStateCorrectionCommand(t *testing.T) {
 		name              string
 		outletState       outlet.State
 		desiredState      outlet.State
+		force             bool
 		switchErr         error
 		expectedState     outlet.State
 		expectedBroadcast bool
@@ -151,6 +357,22 @@ func TestStateCorrectionCommand(t *testing.T) {
 			expectedBroadcast: false,
 			expectedErr:       errors.New("whoops"),
 		},
+		{
+			name:          "outlet is already in desired state, forced",
+			outletState:   outlet.StateOn,
+			desiredState:  outlet.StateOn,
+			force:         true,
+			expectedState: outlet.StateOn,
+		},
+		{
+			name:          "outlet is already in desired state, forced, switch error",
+			outletState:   outlet.StateOn,
+			desiredState:  outlet.StateOn,
+			force:         true,
+			switchErr:     errors.New("whoops"),
+			expectedState: outlet.StateOn,
+			expectedErr:   errors.New("whoops"),
+		},
 	}
 
 	for _, test := range tests {
@@ -159,6 +381,7 @@ func TestStateCorrectionCommand(t *testing.T) {
 			cmd := StateCorrectionCommand{
 				Outlet:       o,
 				DesiredState: test.desiredState,
+				Force:        test.force,
 			}
 
 			ctx, _, s := NewTestContext()
@@ -172,3 +395,60 @@ func TestStateCorrectionCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestSwitchFailedCommand(t *testing.T) {
+	o := &outlet.Outlet{State: outlet.StateOn}
+
+	ctx, _, _ := NewTestContext()
+
+	cmd := SwitchFailedCommand{Outlet: o, State: outlet.StateOn, PreviousState: outlet.StateOff}
+
+	broadcast, err := cmd.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.StateOff, o.GetState())
+	assert.Equal(t, SourceSwitchFailure, SourceOf(cmd))
+
+	// The outlet was switched again in the meantime.
+	o.SetState(outlet.StateOn)
+	cmd = SwitchFailedCommand{Outlet: o, State: outlet.StateOff, PreviousState: outlet.StateOn}
+
+	broadcast, err = cmd.Execute(ctx)
+	require.NoError(t, err)
+	assert.False(t, broadcast)
+	assert.Equal(t, outlet.StateOn, o.GetState())
+}
+
+func TestTriggerCommand(t *testing.T) {
+	ctx, r, _ := NewTestContext()
+
+	o1 := &outlet.Outlet{ID: "foo"}
+	o2 := &outlet.Outlet{ID: "bar", State: outlet.StateOn}
+	o3 := &outlet.Outlet{ID: "baz"}
+
+	r.RegisterGroups(&outlet.Group{
+		ID:      "qux",
+		Outlets: []*outlet.Outlet{o1, o2, o3},
+	})
+
+	broadcast, err := TriggerCommand{OutletID: "foo", Action: ToggleOutletAction}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.StateOn, o1.GetState())
+
+	broadcast, err = TriggerCommand{GroupID: "qux", Action: OffOutletAction}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.StateOff, o1.GetState())
+	assert.Equal(t, outlet.StateOff, o2.GetState())
+
+	broadcast, err = TriggerCommand{Scene: map[string]OutletAction{"foo": OnOutletAction, "baz": OnOutletAction}}.Execute(ctx)
+	require.NoError(t, err)
+	assert.True(t, broadcast)
+	assert.Equal(t, outlet.StateOn, o1.GetState())
+	assert.Equal(t, outlet.StateOff, o2.GetState())
+	assert.Equal(t, outlet.StateOn, o3.GetState())
+
+	_, err = TriggerCommand{Scene: map[string]OutletAction{"nonexistent": OnOutletAction}}.Execute(ctx)
+	assert.Error(t, err)
+}
@@ -20,6 +20,10 @@ func Unpack(envelope Envelope) (cmd Command, err error) {
 		cmd = &OutletCommand{}
 	case GroupType:
 		cmd = &GroupCommand{}
+	case ActionType:
+		cmd = &ActionCommand{}
+	case CoverType:
+		cmd = &CoverCommand{}
 	case IntervalType:
 		cmd = &IntervalCommand{}
 	case StatusType:
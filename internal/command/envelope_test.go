@@ -18,6 +18,8 @@ func TestUnpack(t *testing.T) {
 		{"status command", Envelope{Type: StatusType}, &StatusCommand{}, nil},
 		{"outlet command", Envelope{Type: OutletType, Data: rawMessage(`{"outletID":"foo","action":"toggle"}`)}, &OutletCommand{OutletID: "foo", Action: "toggle"}, nil},
 		{"group command", Envelope{Type: GroupType, Data: rawMessage(`{"groupID":"foo","action":"on"}`)}, &GroupCommand{GroupID: "foo", Action: "on"}, nil},
+		{"action command", Envelope{Type: ActionType, Data: rawMessage(`{"outletID":"foo","action":"stop"}`)}, &ActionCommand{OutletID: "foo", Action: "stop"}, nil},
+		{"cover command", Envelope{Type: CoverType, Data: rawMessage(`{"outletID":"foo","action":"position","position":40}`)}, &CoverCommand{OutletID: "foo", Action: "position", Position: 40}, nil},
 		{"interval command", Envelope{Type: IntervalType, Data: rawMessage(`{"outletID":"foo","action":"create"}`)}, &IntervalCommand{OutletID: "foo", Action: "create"}, nil},
 		// Error cases.
 		{"unknown command", Envelope{Type: Type("unknown")}, nil, errors.New(`unknown command type "unknown"`)},
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const outletGroupsKey = "outletGroups"
+
+// AppendOutlet appends the outlet config oc to the outlet group with groupID in
+// the config file. The group is created if it does not exist yet. Existing
+// content of the file, including comments, is preserved. If the file does not
+// exist, it is created. Returns an error if an outlet with the same ID is
+// already present in the file.
+func AppendOutlet(file, groupID string, oc OutletConfig) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	out, err := appendOutlet(buf, groupID, oc)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, out, 0644)
+}
+
+func appendOutlet(buf []byte, groupID string, oc OutletConfig) ([]byte, error) {
+	var doc yaml.Node
+
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode}},
+		}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected config root to be a mapping, got %s", nodeKind(root))
+	}
+
+	groups := mappingValue(root, outletGroupsKey)
+	if groups == nil {
+		groups = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, scalarNode(outletGroupsKey), groups)
+	}
+
+	if groups.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("expected %s to be a list, got %s", outletGroupsKey, nodeKind(groups))
+	}
+
+	var group *yaml.Node
+
+	for _, g := range groups.Content {
+		if g.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if id := mappingValue(g, "id"); id != nil && id.Value == groupID {
+			group = g
+		}
+
+		if outletExists(g, oc.ID) {
+			return nil, fmt.Errorf("outlet %q already exists", oc.ID)
+		}
+	}
+
+	if group == nil {
+		group = &yaml.Node{
+			Kind:    yaml.MappingNode,
+			Content: []*yaml.Node{scalarNode("id"), scalarNode(groupID)},
+		}
+		groups.Content = append(groups.Content, group)
+	}
+
+	outlets := mappingValue(group, "outlets")
+	if outlets == nil {
+		outlets = &yaml.Node{Kind: yaml.SequenceNode}
+		group.Content = append(group.Content, scalarNode("outlets"), outlets)
+	}
+
+	if outlets.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("expected outlets of group %q to be a list, got %s", groupID, nodeKind(outlets))
+	}
+
+	outletNode, err := structNode(oc)
+	if err != nil {
+		return nil, err
+	}
+
+	outlets.Content = append(outlets.Content, outletNode)
+
+	var out bytes.Buffer
+
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func outletExists(group *yaml.Node, outletID string) bool {
+	outlets := mappingValue(group, "outlets")
+	if outlets == nil || outlets.Kind != yaml.SequenceNode {
+		return false
+	}
+
+	for _, o := range outlets.Content {
+		if o.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if id := mappingValue(o, "id"); id != nil && id.Value == outletID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// structNode converts v into a mapping node using the json field names in the
+// order of the struct fields. Fields with zero values are omitted.
+func structNode(v interface{}) (*yaml.Node, error) {
+	rv := reflect.ValueOf(v)
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	for i := 0; i < rv.NumField(); i++ {
+		name := jsonFieldName(rv.Type().Field(i))
+		fv := rv.Field(i)
+
+		if name == "" || fv.IsZero() {
+			continue
+		}
+
+		buf, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		var valueDoc yaml.Node
+
+		if err := yaml.Unmarshal(buf, &valueDoc); err != nil {
+			return nil, err
+		}
+
+		value := valueDoc.Content[0]
+		resetStyle(value)
+
+		node.Content = append(node.Content, scalarNode(name), value)
+	}
+
+	return node, nil
+}
+
+// resetStyle resets the flow and quoting styles that nodes parsed from json
+// have, so that they are rendered using the default block style.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.ScalarNode:
+		return "scalar"
+	default:
+		return "unknown"
+	}
+}
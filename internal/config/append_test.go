@@ -0,0 +1,135 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendOutlet(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		groupID     string
+		outlet      OutletConfig
+		expected    string
+		expectedErr string
+	}{
+		{
+			name:    "empty file",
+			groupID: "foo",
+			outlet:  OutletConfig{ID: "bar", CodeOn: 1, CodeOff: 2},
+			expected: `outletGroups:
+  - id: foo
+    outlets:
+      - id: bar
+        codeOn: 1
+        codeOff: 2
+`,
+		},
+		{
+			name: "existing group, comments are preserved",
+			input: `# The address to listen on.
+listenAddress: :3333
+outletGroups:
+  - id: foo
+    # The outlets of the group.
+    outlets:
+      - id: bar
+        codeOn: 1
+        codeOff: 2
+`,
+			groupID: "foo",
+			outlet:  OutletConfig{ID: "baz", DisplayName: "123", CodeOn: 3, CodeOff: 4, Protocol: 1, PulseLength: 189},
+			expected: `# The address to listen on.
+listenAddress: :3333
+outletGroups:
+  - id: foo
+    # The outlets of the group.
+    outlets:
+      - id: bar
+        codeOn: 1
+        codeOff: 2
+      - id: baz
+        displayName: "123"
+        codeOn: 3
+        codeOff: 4
+        protocol: 1
+        pulseLength: 189
+`,
+		},
+		{
+			name: "new group",
+			input: `outletGroups:
+  - id: foo
+    outlets:
+      - id: bar
+`,
+			groupID: "qux",
+			outlet:  OutletConfig{ID: "baz", CodeOn: 3},
+			expected: `outletGroups:
+  - id: foo
+    outlets:
+      - id: bar
+  - id: qux
+    outlets:
+      - id: baz
+        codeOn: 3
+`,
+		},
+		{
+			name: "duplicate outlet ID",
+			input: `outletGroups:
+  - id: foo
+    outlets:
+      - id: bar
+`,
+			groupID:     "qux",
+			outlet:      OutletConfig{ID: "bar"},
+			expectedErr: `outlet "bar" already exists`,
+		},
+		{
+			name:        "invalid outletGroups",
+			input:       `outletGroups: foo`,
+			groupID:     "qux",
+			outlet:      OutletConfig{ID: "bar"},
+			expectedErr: `expected outletGroups to be a list, got scalar`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, err := appendOutlet([]byte(test.input), test.groupID, test.outlet)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, string(out))
+			}
+		})
+	}
+}
+
+func TestAppendOutlet_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rfoutlet-config-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.yml")
+
+	require.NoError(t, AppendOutlet(file, "foo", OutletConfig{ID: "bar", CodeOn: 1, CodeOff: 2}))
+	require.NoError(t, AppendOutlet(file, "foo", OutletConfig{ID: "baz", CodeOn: 3, CodeOff: 4}))
+
+	c, err := Load(file)
+	require.NoError(t, err)
+	require.Len(t, c.OutletGroups, 1)
+	assert.Equal(t, []OutletConfig{
+		{ID: "bar", CodeOn: 1, CodeOff: 2},
+		{ID: "baz", CodeOn: 3, CodeOff: 4},
+	}, c.OutletGroups[0].Outlets)
+}
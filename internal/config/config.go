@@ -1,16 +1,27 @@
 // Package config provides the config file schema and utilities to load the
 // config into concrete outlet and outlet group types.
+//
+// Config values can be loaded from multiple sources. See LoadLayered for the
+// order of precedence.
 package config
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/imdario/mergo"
+	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
 	"github.com/martinohmann/rfoutlet/internal/schedule"
+	"github.com/martinohmann/rfoutlet/internal/trigger"
+	"github.com/martinohmann/rfoutlet/internal/webhook"
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
 )
 
@@ -29,18 +40,37 @@ const (
 
 	// DefaultPulseLength defines the default pulse length.
 	DefaultPulseLength uint = 189
+
+	// DefaultDutyCycleWindow defines the default rolling window for
+	// duty-cycle limiting.
+	DefaultDutyCycleWindow = Duration(time.Hour)
+
+	// DefaultStatePollInterval defines the default interval in which the
+	// state of outlets that support it is read back from the device.
+	DefaultStatePollInterval = Duration(30 * time.Second)
+
+	// DefaultDedupWindow defines the default window in which identical
+	// received frames are merged into a single result.
+	DefaultDedupWindow = Duration(300 * time.Millisecond)
 )
 
 // DefaultConfig contains the default values which are chosen if a file is
 // omitted in the config file.
 var DefaultConfig = Config{
-	ListenAddress: DefaultListenAddress,
+	ListenAddress:     DefaultListenAddress,
+	StatePollInterval: DefaultStatePollInterval,
 	GPIO: GPIOConfig{
 		ReceivePin:         DefaultReceivePin,
 		TransmitPin:        DefaultTransmitPin,
 		DefaultPulseLength: DefaultPulseLength,
 		DefaultProtocol:    DefaultProtocol,
 		TransmissionCount:  gpio.DefaultTransmissionCount,
+		ReceiveTolerance:   gpio.DefaultReceiveTolerance,
+		SeparationLimit:    gpio.DefaultSeparationLimit,
+		MaxChanges:         gpio.DefaultMaxChanges,
+		RepeatCount:        gpio.DefaultRepeatCount,
+		EchoWindow:         Duration(gpio.DefaultEchoWindow),
+		DedupWindow:        DefaultDedupWindow,
 	},
 }
 
@@ -50,16 +80,237 @@ type Config struct {
 	StateFile        string              `json:"stateFile"`
 	DetectStateDrift bool                `json:"detectStateDrift"`
 	GPIO             GPIOConfig          `json:"gpio"`
+	Radios           []RadioConfig       `json:"radios"`
 	OutletGroups     []OutletGroupConfig `json:"outletGroups"`
+	// StatePollInterval is the interval in which the state of outlets whose
+	// driver supports it (e.g. Wi-Fi plugs) is read back from the device.
+	StatePollInterval Duration `json:"statePollInterval"`
+	// Webhooks are notified about outlet state changes.
+	Webhooks []WebhookConfig `json:"webhooks"`
+	// Triggers map received rf codes to actions.
+	Triggers []TriggerConfig `json:"triggers"`
+	// DriftPolicy defines how state drift of outlets with an enabled
+	// schedule is handled. It can be overridden per outlet. Defaults to
+	// enforce.
+	DriftPolicy outlet.DriftPolicy `json:"driftPolicy"`
+	// StartupAction defines which state is transmitted to outlets when
+	// rfoutlet starts. It can be overridden per outlet. Defaults to none.
+	StartupAction outlet.StartupAction `json:"startupAction"`
+	// DebugListenAddress is the address to serve debug information on. If
+	// empty, debug information is not served.
+	DebugListenAddress string `json:"debugListenAddress"`
+}
+
+// TriggerConfig is the structure of the config for a single trigger. Exactly
+// one of Outlet, Group, Scene or Webhook must be set.
+type TriggerConfig struct {
+	Name string `json:"name"`
+	Code uint64 `json:"code"`
+	// Protocol defaults to the default protocol of the gpio section.
+	Protocol int `json:"protocol"`
+	// Debounce is the duration in which repeated receptions of the code are
+	// ignored. Defaults to 1s.
+	Debounce Duration `json:"debounce"`
+	// Outlet and Group are the IDs of the outlet or outlet group that Action
+	// is performed on.
+	Outlet string `json:"outlet"`
+	Group  string `json:"group"`
+	// Action is one of "on", "off" or "toggle" (default).
+	Action command.OutletAction `json:"action"`
+	// Scene maps outlet IDs to the actions that are performed on them.
+	Scene map[string]command.OutletAction `json:"scene"`
+	// Webhook is notified if the trigger fires. Its outlet and group filters
+	// are ignored.
+	Webhook *WebhookConfig `json:"webhook"`
+}
+
+// WebhookConfig is the structure of the config for a single webhook.
+type WebhookConfig struct {
+	URL string `json:"url"`
+	// Outlets and Groups optionally restrict the webhook to events of the
+	// listed outlets and outlet groups.
+	Outlets []string `json:"outlets"`
+	Groups  []string `json:"groups"`
+	// Secret is used to sign the request bodies with HMAC-SHA256 if set.
+	Secret string      `json:"secret"`
+	Retry  RetryConfig `json:"retry"`
+}
+
+// RetryConfig is the structure of the retry policy config of a webhook. Zero
+// values are replaced by the defaults of the webhook package.
+type RetryConfig struct {
+	MaxAttempts    int      `json:"maxAttempts"`
+	InitialBackoff Duration `json:"initialBackoff"`
+	MaxBackoff     Duration `json:"maxBackoff"`
+}
+
+// RadioConfig is the structure of the config for an additional radio module,
+// e.g. a transmitter and receiver for a different frequency. The transmitter
+// and receiver configured in the gpio section are used for outlets that do
+// not specify a radio.
+type RadioConfig struct {
+	Name string `json:"name"`
+	// Chip is the name of the gpio chip the radio is attached to. If empty,
+	// the default chip is used.
+	Chip        string `json:"chip"`
+	TransmitPin uint   `json:"transmitPin"`
+	// ReceivePin is optional. If nil, the radio does not have a receiver.
+	ReceivePin        *uint `json:"receivePin"`
+	TransmissionCount int   `json:"transmissionCount"`
 }
 
 // GPIOConfig is the structure of the gpio config section.
 type GPIOConfig struct {
-	ReceivePin         uint `json:"receivePin"`
-	TransmitPin        uint `json:"transmitPin"`
-	DefaultPulseLength uint `json:"defaultPulseLength"`
-	DefaultProtocol    int  `json:"defaultProtocol"`
-	TransmissionCount  int  `json:"transmissionCount"`
+	ReceivePin         uint  `json:"receivePin"`
+	TransmitPin        uint  `json:"transmitPin"`
+	DefaultPulseLength uint  `json:"defaultPulseLength"`
+	DefaultProtocol    int   `json:"defaultProtocol"`
+	TransmissionCount  int   `json:"transmissionCount"`
+	ReceiveTolerance   int64 `json:"receiveTolerance"`
+	SeparationLimit    int64 `json:"separationLimit"`
+	MaxChanges         uint  `json:"maxChanges"`
+	RepeatCount        uint  `json:"repeatCount"`
+	// MinPulseWidth is the minimum width of received pulses in
+	// microseconds. Shorter pulses are discarded as noise.
+	MinPulseWidth int64 `json:"minPulseWidth"`
+	// DedupWindow is the window in which identical received frames are
+	// merged into a single result.
+	DedupWindow Duration `json:"dedupWindow"`
+	// LockOSThread locks the goroutine sending out codes to its own OS
+	// thread.
+	LockOSThread bool `json:"lockOSThread"`
+	// RealtimePriority is the real-time scheduling priority (1-99) that is
+	// requested for the thread sending out codes. Zero disables real-time
+	// scheduling.
+	RealtimePriority int `json:"realtimePriority"`
+	// DutyCycle is the maximum fraction (e.g. 0.1 for 10%) of
+	// DutyCycleWindow each transmitter may be on air. Zero disables
+	// duty-cycle limiting.
+	DutyCycle       float64  `json:"dutyCycle"`
+	DutyCycleWindow Duration `json:"dutyCycleWindow"`
+	// DutyCycleMode defines whether transmissions exceeding the duty-cycle
+	// budget are delayed or rejected. Defaults to delay.
+	DutyCycleMode gpio.DutyCycleMode `json:"dutyCycleMode"`
+	// EchoWindow is the duration after the end of a transmission in which
+	// the state drift detector ignores the transmitted code, since it is
+	// most likely an echo picked up by the receiver.
+	EchoWindow Duration `json:"echoWindow"`
+	// Protocols contains custom protocols which are appended to
+	// gpio.DefaultProtocols. Custom protocols are numbered consecutively
+	// after the default protocols.
+	Protocols []gpio.Protocol `json:"protocols"`
+}
+
+// AllProtocols returns the default protocols followed by the custom protocols
+// defined in c.
+func (c GPIOConfig) AllProtocols() []gpio.Protocol {
+	protocols := make([]gpio.Protocol, 0, len(gpio.DefaultProtocols)+len(c.Protocols))
+	protocols = append(protocols, gpio.DefaultProtocols...)
+
+	return append(protocols, c.Protocols...)
+}
+
+// Validate returns an error if c contains invalid receiver settings or
+// custom protocols.
+func (c GPIOConfig) Validate() error {
+	if c.MaxChanges > gpio.MaxChangesLimit {
+		return fmt.Errorf("maxChanges must not exceed %d, longer frames cannot be decoded", gpio.MaxChangesLimit)
+	}
+
+	for i, p := range c.Protocols {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid protocol %d: %v", len(gpio.DefaultProtocols)+i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// TransmitterOptions returns the options for configuring a *gpio.Transmitter
+// according to c. Zero values are omitted so that the transmitter's defaults
+// apply.
+func (c GPIOConfig) TransmitterOptions() []gpio.TransmitterOption {
+	var options []gpio.TransmitterOption
+
+	if c.TransmissionCount > 0 {
+		options = append(options, gpio.TransmissionCount(c.TransmissionCount))
+	}
+
+	if c.LockOSThread {
+		options = append(options, gpio.TransmitterLockOSThread(true))
+	}
+
+	if c.RealtimePriority > 0 {
+		options = append(options, gpio.TransmitterRealtimePriority(c.RealtimePriority))
+	}
+
+	return options
+}
+
+// DutyCycleLimiter creates a new *gpio.DutyCycleLimiter according to c.
+// Returns nil if duty-cycle limiting is disabled or an error if the
+// duty-cycle config is invalid.
+func (c GPIOConfig) DutyCycleLimiter() (*gpio.DutyCycleLimiter, error) {
+	if c.DutyCycle == 0 {
+		return nil, nil
+	}
+
+	if c.DutyCycle < 0 || c.DutyCycle > 1 {
+		return nil, fmt.Errorf("duty cycle must be between 0 and 1, got %v", c.DutyCycle)
+	}
+
+	window := c.DutyCycleWindow
+	if window == 0 {
+		window = DefaultDutyCycleWindow
+	}
+
+	mode := c.DutyCycleMode
+	if mode == "" {
+		mode = gpio.DutyCycleDelay
+	}
+
+	if err := mode.Validate(); err != nil {
+		return nil, err
+	}
+
+	return gpio.NewDutyCycleLimiter(c.DutyCycle, window.Duration(), mode), nil
+}
+
+// ReceiverOptions returns the options for configuring a *gpio.Receiver
+// according to c. Zero values are omitted so that the receiver's defaults
+// apply.
+func (c GPIOConfig) ReceiverOptions() []gpio.ReceiverOption {
+	var options []gpio.ReceiverOption
+
+	if c.ReceiveTolerance > 0 {
+		options = append(options, gpio.ReceiverTolerance(c.ReceiveTolerance))
+	}
+
+	if c.SeparationLimit > 0 {
+		options = append(options, gpio.ReceiverSeparationLimit(c.SeparationLimit))
+	}
+
+	if c.MaxChanges > 0 {
+		options = append(options, gpio.ReceiverMaxChanges(c.MaxChanges))
+	}
+
+	if c.RepeatCount > 0 {
+		options = append(options, gpio.ReceiverRepeatCount(c.RepeatCount))
+	}
+
+	if c.MinPulseWidth > 0 {
+		options = append(options, gpio.ReceiverMinPulseWidth(c.MinPulseWidth))
+	}
+
+	if c.DedupWindow > 0 {
+		options = append(options, gpio.ReceiverDedupWindow(c.DedupWindow.Duration()))
+	}
+
+	if len(c.Protocols) > 0 {
+		options = append(options, gpio.ReceiverProtocols(c.AllProtocols()))
+	}
+
+	return options
 }
 
 // OutletGroupConfig is the structure of the config for a single outlet group.
@@ -77,12 +328,291 @@ type OutletConfig struct {
 	CodeOff     uint64 `json:"codeOff"`
 	Protocol    int    `json:"protocol"`
 	PulseLength uint   `json:"pulseLength"`
+	// AlsoOnCodes and AlsoOffCodes are additional codes, e.g. of "all on"
+	// and "all off" buttons of a remote control, which are used to detect
+	// state drift. They are never transmitted.
+	AlsoOnCodes  []uint64 `json:"alsoOnCodes"`
+	AlsoOffCodes []uint64 `json:"alsoOffCodes"`
+	// RecordingOn and RecordingOff are paths to raw recordings created with
+	// `rfoutlet record`. If set, they are replayed verbatim instead of
+	// sending CodeOn and CodeOff.
+	RecordingOn  string `json:"recordingOn"`
+	RecordingOff string `json:"recordingOff"`
+	// Radio is the name of the radio that is used to switch the outlet. If
+	// empty, the transmitter configured in the gpio section is used.
+	Radio string `json:"radio"`
+	// TransmissionCount overrides the transmission count of the outlet's
+	// radio. If zero, the radio's transmission count is used.
+	TransmissionCount int `json:"transmissionCount"`
+	// RepeatGap is the pause between two repetitions of a code. If zero,
+	// repetitions are sent back-to-back.
+	RepeatGap Duration `json:"repeatGap"`
+	// Driver defines how the outlet is switched. One of "rf" (default),
+	// "tasmota" or "shelly".
+	Driver outlet.Driver `json:"driver"`
+	// Address is the address of the HTTP API of Wi-Fi plugs, e.g.
+	// http://192.168.1.10. Credentials can be passed as userinfo. Required
+	// for the tasmota and shelly drivers.
+	Address string `json:"address"`
+	// Channel selects the relay of Wi-Fi plugs with multiple relays.
+	Channel int `json:"channel"`
+	// DriftPolicy overrides the global drift policy for this outlet. One of
+	// "enforce", "override" or "ignore".
+	DriftPolicy outlet.DriftPolicy `json:"driftPolicy"`
+	// RefreshInterval is the interval in which the current state of the
+	// outlet is re-transmitted. Zero disables refreshing. Only supported by
+	// the rf driver.
+	RefreshInterval Duration `json:"refreshInterval"`
+	// StartupAction overrides the global startup action for this outlet.
+	// One of "restore", "on", "off", "schedule" or "none".
+	StartupAction outlet.StartupAction `json:"startupAction"`
+	// Actions are named codes in addition to CodeOn and CodeOff, e.g. the
+	// stop button of blinds. Only supported by the rf driver.
+	Actions []ActionConfig `json:"actions"`
+	// Stateless outlets do not track an on/off state and can only be
+	// controlled via actions.
+	Stateless bool `json:"stateless"`
+	// Type is the device type, either "outlet" (default) or "cover" for
+	// motorised blinds and shutters.
+	Type string `json:"type"`
+	// CodeUp, CodeDown and CodeStop are the codes that open, close and stop
+	// covers.
+	CodeUp   uint64 `json:"codeUp"`
+	CodeDown uint64 `json:"codeDown"`
+	CodeStop uint64 `json:"codeStop"`
+	// TravelTimeUp is the time a cover takes to fully open from the closed
+	// position. Required for covers.
+	TravelTimeUp Duration `json:"travelTimeUp"`
+	// TravelTimeDown is the time a cover takes to fully close from the open
+	// position. Defaults to TravelTimeUp.
+	TravelTimeDown Duration `json:"travelTimeDown"`
+}
+
+// ActionConfig is the structure of the config for a single named action of
+// an outlet.
+type ActionConfig struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Code        uint64 `json:"code"`
+	// Protocol and PulseLength default to the ones of the outlet.
+	Protocol    int  `json:"protocol"`
+	PulseLength uint `json:"pulseLength"`
+	// State is the state the outlet is in after the action was performed,
+	// either "on" or "off". If empty, the action does not change the
+	// outlet state.
+	State string `json:"state"`
+}
+
+// BuildRadios returns the radios from c with defaults applied. Returns an
+// error if a radio has no name or if a name is used more than once.
+func (c Config) BuildRadios() ([]RadioConfig, error) {
+	radios := make([]RadioConfig, len(c.Radios))
+	seen := make(map[string]bool)
+
+	for i, rc := range c.Radios {
+		if rc.Name == "" {
+			return nil, fmt.Errorf("radio #%d: name must not be empty", i)
+		}
+
+		if seen[rc.Name] {
+			return nil, fmt.Errorf("duplicate radio name %q", rc.Name)
+		}
+
+		seen[rc.Name] = true
+
+		if rc.TransmissionCount == 0 {
+			rc.TransmissionCount = c.GPIO.TransmissionCount
+		}
+
+		radios[i] = rc
+	}
+
+	return radios, nil
+}
+
+// BuildWebhooks returns the webhooks from c with defaults applied. Returns an
+// error if a webhook has an invalid URL.
+func (c Config) BuildWebhooks() ([]webhook.Webhook, error) {
+	webhooks := make([]webhook.Webhook, len(c.Webhooks))
+
+	for i, wc := range c.Webhooks {
+		w, err := wc.build()
+		if err != nil {
+			return nil, fmt.Errorf("webhook #%d: %v", i, err)
+		}
+
+		webhooks[i] = w
+	}
+
+	return webhooks, nil
+}
+
+// build builds a webhook from wc with defaults applied.
+func (wc WebhookConfig) build() (webhook.Webhook, error) {
+	u, err := url.Parse(wc.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return webhook.Webhook{}, fmt.Errorf("invalid url %q", wc.URL)
+	}
+
+	retry := webhook.RetryPolicy{
+		MaxAttempts:    wc.Retry.MaxAttempts,
+		InitialBackoff: wc.Retry.InitialBackoff.Duration(),
+		MaxBackoff:     wc.Retry.MaxBackoff.Duration(),
+	}
+
+	if retry.MaxAttempts <= 0 {
+		retry.MaxAttempts = webhook.DefaultMaxAttempts
+	}
+
+	if retry.InitialBackoff <= 0 {
+		retry.InitialBackoff = webhook.DefaultInitialBackoff
+	}
+
+	if retry.MaxBackoff <= 0 {
+		retry.MaxBackoff = webhook.DefaultMaxBackoff
+	}
+
+	return webhook.Webhook{
+		URL:     wc.URL,
+		Outlets: wc.Outlets,
+		Groups:  wc.Groups,
+		Secret:  wc.Secret,
+		Retry:   retry,
+	}, nil
 }
 
-// BuildOutletGroups builds outlet groups from c.
-func (c Config) BuildOutletGroups() []*outlet.Group {
+// BuildTriggers returns the triggers from c with defaults applied. Returns an
+// error if a trigger does not have exactly one action, references outlets or
+// groups that do not exist or has an invalid action.
+func (c Config) BuildTriggers() ([]trigger.Trigger, error) {
+	triggers := make([]trigger.Trigger, len(c.Triggers))
+
+	outlets := make(map[string]bool)
+	groups := make(map[string]bool)
+
+	for _, gc := range c.OutletGroups {
+		groups[gc.ID] = true
+
+		for _, oc := range gc.Outlets {
+			outlets[oc.ID] = true
+		}
+	}
+
+	for i, tc := range c.Triggers {
+		t := trigger.Trigger{
+			Name:     tc.Name,
+			Code:     tc.Code,
+			Protocol: tc.Protocol,
+			Debounce: tc.Debounce.Duration(),
+			OutletID: tc.Outlet,
+			GroupID:  tc.Group,
+			Action:   tc.Action,
+			Scene:    tc.Scene,
+		}
+
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("#%d", i)
+		}
+
+		if t.Protocol == 0 {
+			t.Protocol = c.GPIO.DefaultProtocol
+		}
+
+		if t.Debounce == 0 {
+			t.Debounce = trigger.DefaultDebounce
+		}
+
+		if t.Action == "" {
+			t.Action = command.ToggleOutletAction
+		}
+
+		if err := validateTrigger(tc, outlets, groups); err != nil {
+			return nil, fmt.Errorf("trigger %q: %v", t.Name, err)
+		}
+
+		if tc.Webhook != nil {
+			w, err := tc.Webhook.build()
+			if err != nil {
+				return nil, fmt.Errorf("trigger %q: webhook: %v", t.Name, err)
+			}
+
+			t.Webhook = &w
+		}
+
+		triggers[i] = t
+	}
+
+	return triggers, nil
+}
+
+func validateTrigger(tc TriggerConfig, outlets, groups map[string]bool) error {
+	var actions int
+
+	for _, set := range []bool{tc.Outlet != "", tc.Group != "", len(tc.Scene) > 0, tc.Webhook != nil} {
+		if set {
+			actions++
+		}
+	}
+
+	if actions != 1 {
+		return fmt.Errorf("exactly one of outlet, group, scene or webhook must be set")
+	}
+
+	if tc.Outlet != "" && !outlets[tc.Outlet] {
+		return fmt.Errorf("outlet %q does not exist", tc.Outlet)
+	}
+
+	if tc.Group != "" && !groups[tc.Group] {
+		return fmt.Errorf("group %q does not exist", tc.Group)
+	}
+
+	if tc.Action != "" {
+		if err := validateOutletAction(tc.Action); err != nil {
+			return err
+		}
+	}
+
+	for id, action := range tc.Scene {
+		if !outlets[id] {
+			return fmt.Errorf("scene: outlet %q does not exist", id)
+		}
+
+		if err := validateOutletAction(action); err != nil {
+			return fmt.Errorf("scene: outlet %q: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+func validateOutletAction(action command.OutletAction) error {
+	switch action {
+	case command.OnOutletAction, command.OffOutletAction, command.ToggleOutletAction:
+		return nil
+	default:
+		return fmt.Errorf("invalid action %q, expected one of %q, %q or %q", action, command.OnOutletAction, command.OffOutletAction, command.ToggleOutletAction)
+	}
+}
+
+// BuildOutletGroups builds outlet groups from c. Returns an error if raw
+// recordings referenced by outlets cannot be loaded or if outlets reference
+// radios that do not exist.
+func (c Config) BuildOutletGroups() ([]*outlet.Group, error) {
+	if err := c.DriftPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := c.StartupAction.Validate(); err != nil {
+		return nil, err
+	}
+
 	groups := make([]*outlet.Group, len(c.OutletGroups))
 
+	radios := make(map[string]bool)
+	for _, rc := range c.Radios {
+		radios[rc.Name] = true
+	}
+
 	for i, gc := range c.OutletGroups {
 		outlets := make([]*outlet.Outlet, len(gc.Outlets))
 
@@ -94,8 +624,56 @@ func (c Config) BuildOutletGroups() []*outlet.Group {
 				CodeOff:     oc.CodeOff,
 				Protocol:    oc.Protocol,
 				PulseLength: oc.PulseLength,
+				Radio:       oc.Radio,
 				Schedule:    schedule.New(),
 				State:       outlet.StateOff,
+
+				TransmissionCount: oc.TransmissionCount,
+				RepeatGap:         oc.RepeatGap.Duration(),
+
+				AlsoOnCodes:  oc.AlsoOnCodes,
+				AlsoOffCodes: oc.AlsoOffCodes,
+
+				Driver:  oc.Driver,
+				Address: oc.Address,
+				Channel: oc.Channel,
+
+				DriftPolicy:     oc.DriftPolicy,
+				RefreshInterval: oc.RefreshInterval.Duration(),
+				StartupAction:   oc.StartupAction,
+				Stateless:       oc.Stateless,
+			}
+
+			if err := o.Driver.Validate(); err != nil {
+				return nil, fmt.Errorf("outlet %q: %v", o.ID, err)
+			}
+
+			if (o.Driver == outlet.DriverTasmota || o.Driver == outlet.DriverShelly) && o.Address == "" {
+				return nil, fmt.Errorf("outlet %q: address is required for driver %q", o.ID, o.Driver)
+			}
+
+			if o.Driver != "" && o.Driver != outlet.DriverRF && o.RefreshInterval > 0 {
+				return nil, fmt.Errorf("outlet %q: refreshInterval is not supported by driver %q", o.ID, o.Driver)
+			}
+
+			if err := o.DriftPolicy.Validate(); err != nil {
+				return nil, fmt.Errorf("outlet %q: %v", o.ID, err)
+			}
+
+			if o.DriftPolicy == "" {
+				o.DriftPolicy = c.DriftPolicy
+			}
+
+			if err := o.StartupAction.Validate(); err != nil {
+				return nil, fmt.Errorf("outlet %q: %v", o.ID, err)
+			}
+
+			if o.StartupAction == "" {
+				o.StartupAction = c.StartupAction
+			}
+
+			if o.Radio != "" && !radios[o.Radio] {
+				return nil, fmt.Errorf("outlet %q: radio %q does not exist", o.ID, o.Radio)
 			}
 
 			if o.DisplayName == "" {
@@ -110,6 +688,18 @@ func (c Config) BuildOutletGroups() []*outlet.Group {
 				o.Protocol = c.GPIO.DefaultProtocol
 			}
 
+			if err := buildCover(o, oc); err != nil {
+				return nil, fmt.Errorf("outlet %q: %v", o.ID, err)
+			}
+
+			if err := buildActions(o, oc); err != nil {
+				return nil, fmt.Errorf("outlet %q: %v", o.ID, err)
+			}
+
+			if err := loadRecordings(o, oc); err != nil {
+				return nil, fmt.Errorf("outlet %q: %v", o.ID, err)
+			}
+
 			outlets[j] = o
 		}
 
@@ -126,7 +716,227 @@ func (c Config) BuildOutletGroups() []*outlet.Group {
 		groups[i] = g
 	}
 
-	return groups
+	return groups, nil
+}
+
+// buildCover builds the cover of o from oc if oc is of type cover. Returns an
+// error if the type or the cover config is invalid.
+func buildCover(o *outlet.Outlet, oc OutletConfig) error {
+	switch oc.Type {
+	case "", "outlet":
+		return nil
+	case "cover":
+	default:
+		return fmt.Errorf("invalid type %q, expected %q or %q", oc.Type, "outlet", "cover")
+	}
+
+	if o.Driver != "" && o.Driver != outlet.DriverRF {
+		return fmt.Errorf("covers are not supported by driver %q", o.Driver)
+	}
+
+	if oc.RecordingOn != "" || oc.RecordingOff != "" {
+		return fmt.Errorf("recordings are not supported for covers")
+	}
+
+	if oc.TravelTimeUp <= 0 {
+		return fmt.Errorf("travelTimeUp must be greater than zero for covers")
+	}
+
+	if oc.TravelTimeDown < 0 {
+		return fmt.Errorf("travelTimeDown must not be negative")
+	}
+
+	o.Cover = &outlet.Cover{
+		CodeUp:         oc.CodeUp,
+		CodeDown:       oc.CodeDown,
+		CodeStop:       oc.CodeStop,
+		TravelTimeUp:   oc.TravelTimeUp.Duration(),
+		TravelTimeDown: oc.TravelTimeDown.Duration(),
+	}
+
+	if o.Cover.TravelTimeDown == 0 {
+		o.Cover.TravelTimeDown = o.Cover.TravelTimeUp
+	}
+
+	o.Stateless = true
+
+	return nil
+}
+
+// buildActions builds the actions of o from oc. Returns an error if an action
+// is invalid.
+func buildActions(o *outlet.Outlet, oc OutletConfig) error {
+	if len(oc.Actions) > 0 && o.Driver != "" && o.Driver != outlet.DriverRF {
+		return fmt.Errorf("actions are not supported by driver %q", o.Driver)
+	}
+
+	seen := make(map[string]bool)
+
+	for i, ac := range oc.Actions {
+		if ac.Name == "" {
+			return fmt.Errorf("action #%d: name must not be empty", i)
+		}
+
+		if seen[ac.Name] {
+			return fmt.Errorf("duplicate action name %q", ac.Name)
+		}
+
+		seen[ac.Name] = true
+
+		action := outlet.Action{
+			Name:        ac.Name,
+			DisplayName: ac.DisplayName,
+			Code:        ac.Code,
+			Protocol:    ac.Protocol,
+			PulseLength: ac.PulseLength,
+		}
+
+		if action.DisplayName == "" {
+			action.DisplayName = action.Name
+		}
+
+		if action.Protocol == 0 {
+			action.Protocol = o.Protocol
+		}
+
+		if action.PulseLength == 0 {
+			action.PulseLength = o.PulseLength
+		}
+
+		switch ac.State {
+		case "":
+		case "on", "off":
+			if o.Stateless {
+				return fmt.Errorf("action %q: state must not be set for stateless outlets", ac.Name)
+			}
+
+			state := outlet.StateOff
+			if ac.State == "on" {
+				state = outlet.StateOn
+			}
+
+			action.State = &state
+		default:
+			return fmt.Errorf("action %q: invalid state %q, expected %q or %q", ac.Name, ac.State, "on", "off")
+		}
+
+		o.Actions = append(o.Actions, action)
+	}
+
+	return nil
+}
+
+func loadRecordings(o *outlet.Outlet, oc OutletConfig) (err error) {
+	if oc.RecordingOn != "" {
+		o.RecordingOn, err = gpio.LoadRecording(oc.RecordingOn)
+		if err != nil {
+			return fmt.Errorf("failed to load on recording: %v", err)
+		}
+	}
+
+	if oc.RecordingOff != "" {
+		o.RecordingOff, err = gpio.LoadRecording(oc.RecordingOff)
+		if err != nil {
+			return fmt.Errorf("failed to load off recording: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// LoadLayered loads the config from multiple sources and merges them. The
+// sources are applied in the following order, where later sources take
+// precedence over earlier ones:
+//
+//  1. DefaultConfig
+//  2. the config file, if file is not empty
+//  3. the YAML fragments in dir (see LoadDir), if dir is not empty
+//  4. RFOUTLET_* environment variables (see LoadEnv)
+//
+// Each source only overrides the values it explicitly sets, so sources can
+// also reset values of earlier ones to false, 0 or "". Lists of the config
+// file and the YAML fragments are appended to each other, while a list set
+// via an environment variable replaces the list of all earlier sources.
+//
+// Values passed via command line flags are expected to be merged on top of
+// the result by the caller.
+func LoadLayered(file, dir string) (*Config, error) {
+	config := DefaultConfig
+
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		err = mergeYAML(&config, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dir != "" {
+		err := mergeDir(&config, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := LoadEnv(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// LoadDir loads all files with .yml or .yaml extension from dir in lexical
+// order and merges them into a single config. Values of later files override
+// those of earlier ones, while lists (e.g. outletGroups) are appended. This
+// allows to split the config into multiple fragments, e.g. one file per room.
+func LoadDir(dir string) (*Config, error) {
+	config := &Config{}
+
+	err := mergeDir(config, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func mergeDir(config *Config, dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	files := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to load config fragment %s: %v", file, err)
+		}
+
+		err = mergeYAML(config, data)
+		if err != nil {
+			return fmt.Errorf("failed to load config fragment %s: %v", file, err)
+		}
+	}
+
+	return nil
 }
 
 // LoadWithDefaults loads config from file and merges in the default config for
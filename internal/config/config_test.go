@@ -2,11 +2,17 @@ package config
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
 	"github.com/martinohmann/rfoutlet/internal/schedule"
+	"github.com/martinohmann/rfoutlet/internal/trigger"
+	"github.com/martinohmann/rfoutlet/internal/webhook"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -32,6 +38,117 @@ func TestLoadWithDefaults(t *testing.T) {
 	require.Len(t, c.OutletGroups, 2)
 }
 
+func TestLoadDir(t *testing.T) {
+	c, err := LoadDir("testdata/conf.d")
+
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:4321", c.ListenAddress)
+	require.Len(t, c.OutletGroups, 2)
+	assert.Equal(t, "living-room", c.OutletGroups[0].ID)
+	assert.Equal(t, "kitchen", c.OutletGroups[1].ID)
+}
+
+func TestLoadDirNonexistent(t *testing.T) {
+	_, err := LoadDir("testdata/idonotexist")
+	assert.Error(t, err)
+}
+
+func TestLoadLayered(t *testing.T) {
+	c, err := LoadLayered("testdata/partial.yaml", "testdata/conf.d")
+
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.0.0:4321", c.ListenAddress)
+	assert.Equal(t, uint(42), c.GPIO.ReceivePin)
+	assert.Equal(t, DefaultConfig.GPIO.TransmitPin, c.GPIO.TransmitPin)
+	require.Len(t, c.OutletGroups, 4)
+	assert.Equal(t, "foo", c.OutletGroups[0].ID)
+	assert.Equal(t, "kitchen", c.OutletGroups[3].ID)
+}
+
+func TestLoadLayeredResetValues(t *testing.T) {
+	os.Setenv("RFOUTLET_GPIO_TRANSMISSION_COUNT", "0")
+	defer os.Unsetenv("RFOUTLET_GPIO_TRANSMISSION_COUNT")
+
+	c, err := LoadLayered("testdata/reset.yaml", "testdata/conf.d-reset")
+
+	require.NoError(t, err)
+	assert.Empty(t, c.StateFile)
+	assert.False(t, c.DetectStateDrift)
+	assert.Equal(t, Duration(0), c.GPIO.EchoWindow)
+	assert.Equal(t, 0, c.GPIO.TransmissionCount)
+	assert.Equal(t, uint(42), c.GPIO.ReceivePin)
+	assert.Equal(t, DefaultConfig.GPIO.TransmitPin, c.GPIO.TransmitPin)
+	require.Len(t, c.OutletGroups, 2)
+	assert.Equal(t, "foo", c.OutletGroups[0].ID)
+	assert.Equal(t, "living-room", c.OutletGroups[1].ID)
+}
+
+func TestLoadLayeredListsFromFragmentsAndEnv(t *testing.T) {
+	c, err := LoadLayered("testdata/partial.yaml", "testdata/conf.d")
+	require.NoError(t, err)
+
+	// Lists of the config file and the fragments are appended.
+	require.Len(t, c.OutletGroups, 4)
+
+	os.Setenv("RFOUTLET_OUTLET_GROUPS", `[{"id":"env","outlets":[{"id":"qux","codeOn":1,"codeOff":2}]}]`)
+	defer os.Unsetenv("RFOUTLET_OUTLET_GROUPS")
+
+	c, err = LoadLayered("testdata/partial.yaml", "testdata/conf.d")
+	require.NoError(t, err)
+
+	// Lists set via environment variables replace the lists of all earlier
+	// sources.
+	require.Len(t, c.OutletGroups, 1)
+	assert.Equal(t, "env", c.OutletGroups[0].ID)
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"RFOUTLET_LISTEN_ADDRESS":        "127.0.0.1:8080",
+		"RFOUTLET_DETECT_STATE_DRIFT":    "true",
+		"RFOUTLET_GPIO_TRANSMIT_PIN":     "22",
+		"RFOUTLET_GPIO_DEFAULT_PROTOCOL": "2",
+		"RFOUTLET_GPIO_DEDUP_WINDOW":     "500ms",
+		"RFOUTLET_OUTLET_GROUPS":         `[{"id":"foo","outlets":[{"id":"bar","codeOn":1,"codeOff":2}]}]`,
+	}
+
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+
+	c := &Config{
+		StateFile:    "state.json",
+		OutletGroups: []OutletGroupConfig{{ID: "baz"}},
+	}
+
+	require.NoError(t, applyEnv(c, EnvPrefix, lookup))
+
+	assert.Equal(t, "127.0.0.1:8080", c.ListenAddress)
+	assert.Equal(t, "state.json", c.StateFile)
+	assert.True(t, c.DetectStateDrift)
+	assert.Equal(t, uint(22), c.GPIO.TransmitPin)
+	assert.Equal(t, 2, c.GPIO.DefaultProtocol)
+	assert.Equal(t, Duration(500*time.Millisecond), c.GPIO.DedupWindow)
+	assert.Equal(t, []OutletGroupConfig{
+		{ID: "foo", Outlets: []OutletConfig{{ID: "bar", CodeOn: 1, CodeOff: 2}}},
+	}, c.OutletGroups)
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	lookup := func(key string) (string, bool) {
+		if key == "RFOUTLET_GPIO_RECEIVE_PIN" {
+			return "not-a-number", true
+		}
+
+		return "", false
+	}
+
+	err := applyEnv(&Config{}, EnvPrefix, lookup)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RFOUTLET_GPIO_RECEIVE_PIN")
+}
+
 func TestLoadInvalid(t *testing.T) {
 	_, err := Load("testdata/invalid.yml")
 	assert.Error(t, err)
@@ -83,6 +200,16 @@ func TestConfig_BuildOutletGroups(t *testing.T) {
 						CodeOn:  1,
 						CodeOff: 2,
 					},
+					{
+						ID:                "baz",
+						CodeOn:            3,
+						CodeOff:           4,
+						TransmissionCount: 20,
+						RepeatGap:         Duration(50 * time.Millisecond),
+						AlsoOnCodes:       []uint64{5},
+						AlsoOffCodes:      []uint64{6, 7},
+						RefreshInterval:   Duration(time.Hour),
+					},
 				},
 			},
 		},
@@ -102,9 +229,601 @@ func TestConfig_BuildOutletGroups(t *testing.T) {
 					Protocol:    1,
 					PulseLength: 123,
 				},
+				{
+					ID:                "baz",
+					DisplayName:       "baz",
+					CodeOn:            3,
+					CodeOff:           4,
+					Schedule:          schedule.New(),
+					Protocol:          1,
+					PulseLength:       123,
+					TransmissionCount: 20,
+					RepeatGap:         50 * time.Millisecond,
+					AlsoOnCodes:       []uint64{5},
+					AlsoOffCodes:      []uint64{6, 7},
+					RefreshInterval:   time.Hour,
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+	assert.Equal(t, expected, groups)
+}
+
+func TestConfig_BuildOutletGroups_Recordings(t *testing.T) {
+	config := Config{
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{
+						ID:           "bar",
+						RecordingOn:  "testdata/recording_on.json",
+						RecordingOff: "testdata/recording_off.json",
+					},
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+
+	o := groups[0].Outlets[0]
+	require.NotNil(t, o.RecordingOn)
+	require.NotNil(t, o.RecordingOff)
+	assert.Equal(t, []time.Duration{350 * time.Microsecond, 1050 * time.Microsecond}, o.RecordingOn.Pulses)
+	assert.Equal(t, []time.Duration{1050 * time.Microsecond, 350 * time.Microsecond}, o.RecordingOff.Pulses)
+
+	config.OutletGroups[0].Outlets[0].RecordingOff = "testdata/idonotexist.json"
+
+	_, err = config.BuildOutletGroups()
+	require.Error(t, err)
+}
+
+func TestGPIOConfig_ReceiverOptions(t *testing.T) {
+	assert.Len(t, GPIOConfig{}.ReceiverOptions(), 0)
+	assert.Len(t, GPIOConfig{ReceiveTolerance: 80, MaxChanges: 131}.ReceiverOptions(), 2)
+	assert.Len(t, GPIOConfig{MinPulseWidth: 100, DedupWindow: Duration(time.Second)}.ReceiverOptions(), 2)
+	assert.Len(t, DefaultConfig.GPIO.ReceiverOptions(), 5)
+	assert.Len(t, GPIOConfig{Protocols: []gpio.Protocol{{}}}.ReceiverOptions(), 1)
+}
+
+func TestGPIOConfig_ReceiverOptionsDefaultDedupWindow(t *testing.T) {
+	assert.Equal(t, DefaultDedupWindow, DefaultConfig.GPIO.DedupWindow)
+
+	withoutDedup := DefaultConfig.GPIO
+	withoutDedup.DedupWindow = 0
+
+	// The default options contain ReceiverDedupWindow in addition to the
+	// options for the other default values.
+	assert.Len(t, DefaultConfig.GPIO.ReceiverOptions(), len(withoutDedup.ReceiverOptions())+1)
+
+	c, err := LoadLayered("", "")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDedupWindow, c.GPIO.DedupWindow)
+}
+
+func TestGPIOConfig_TransmitterOptions(t *testing.T) {
+	assert.Len(t, GPIOConfig{}.TransmitterOptions(), 0)
+	assert.Len(t, DefaultConfig.GPIO.TransmitterOptions(), 1)
+	assert.Len(t, GPIOConfig{LockOSThread: true, RealtimePriority: 50}.TransmitterOptions(), 2)
+}
+
+func TestGPIOConfig_DutyCycleLimiter(t *testing.T) {
+	limiter, err := GPIOConfig{}.DutyCycleLimiter()
+	require.NoError(t, err)
+	assert.Nil(t, limiter)
+
+	limiter, err = GPIOConfig{DutyCycle: 0.1}.DutyCycleLimiter()
+	require.NoError(t, err)
+	require.NotNil(t, limiter)
+
+	stats := limiter.Stats()
+	assert.Equal(t, 0.1, stats.Limit)
+	assert.Equal(t, time.Hour, stats.Window)
+	assert.Equal(t, 6*time.Minute, stats.Remaining)
+
+	_, err = GPIOConfig{DutyCycle: 1.5}.DutyCycleLimiter()
+	assert.EqualError(t, err, "duty cycle must be between 0 and 1, got 1.5")
+
+	_, err = GPIOConfig{DutyCycle: 0.1, DutyCycleMode: "drop"}.DutyCycleLimiter()
+	assert.EqualError(t, err, `invalid duty-cycle mode "drop", expected "delay" or "reject"`)
+}
+
+func TestGPIOConfig_AllProtocols(t *testing.T) {
+	custom := gpio.Protocol{
+		Sync: gpio.HighLow{High: 1, Low: 20},
+		Zero: gpio.HighLow{High: 1, Low: 4},
+		One:  gpio.HighLow{High: 4, Low: 1},
+	}
+
+	assert.Equal(t, gpio.DefaultProtocols, GPIOConfig{}.AllProtocols())
+
+	protocols := GPIOConfig{Protocols: []gpio.Protocol{custom}}.AllProtocols()
+	require.Len(t, protocols, len(gpio.DefaultProtocols)+1)
+	assert.Equal(t, custom, protocols[len(gpio.DefaultProtocols)])
+}
+
+func TestGPIOConfig_Validate(t *testing.T) {
+	valid := gpio.Protocol{
+		Sync: gpio.HighLow{High: 1, Low: 20},
+		Zero: gpio.HighLow{High: 1, Low: 4},
+		One:  gpio.HighLow{High: 4, Low: 1},
+	}
+
+	assert.NoError(t, GPIOConfig{Protocols: []gpio.Protocol{valid}}.Validate())
+
+	cfg := `
+gpio:
+  protocols:
+    - zero: {high: 1, low: 4}
+      one: {high: 4, low: 1}`
+
+	c, err := LoadWithReader(strings.NewReader(cfg))
+	require.NoError(t, err)
+
+	err = c.GPIO.Validate()
+	assert.EqualError(t, err, "invalid protocol 6: sync.low must be greater than 0")
+
+	err = GPIOConfig{MaxChanges: gpio.MaxChangesLimit}.Validate()
+	assert.NoError(t, err)
+
+	err = GPIOConfig{MaxChanges: gpio.MaxChangesLimit + 1}.Validate()
+	assert.EqualError(t, err, "maxChanges must not exceed 130, longer frames cannot be decoded")
+}
+
+func TestLoadWithReader_Protocols(t *testing.T) {
+	cfg := `
+gpio:
+  protocols:
+    - sync: {high: 1, low: 20}
+      zero: {high: 1, low: 4}
+      one: {high: 4, low: 1}`
+
+	c, err := LoadWithReader(strings.NewReader(cfg))
+	require.NoError(t, err)
+	assert.Equal(t, []gpio.Protocol{
+		{
+			Sync: gpio.HighLow{High: 1, Low: 20},
+			Zero: gpio.HighLow{High: 1, Low: 4},
+			One:  gpio.HighLow{High: 4, Low: 1},
+		},
+	}, c.GPIO.Protocols)
+}
+
+func TestConfig_BuildRadios(t *testing.T) {
+	receivePin := uint(23)
+
+	config := Config{
+		GPIO: GPIOConfig{TransmissionCount: 10},
+		Radios: []RadioConfig{
+			{Name: "315mhz", Chip: "gpiochip1", TransmitPin: 22, ReceivePin: &receivePin},
+			{Name: "868mhz", TransmitPin: 24, TransmissionCount: 5},
+		},
+	}
+
+	radios, err := config.BuildRadios()
+	require.NoError(t, err)
+	assert.Equal(t, []RadioConfig{
+		{Name: "315mhz", Chip: "gpiochip1", TransmitPin: 22, ReceivePin: &receivePin, TransmissionCount: 10},
+		{Name: "868mhz", TransmitPin: 24, TransmissionCount: 5},
+	}, radios)
+
+	config.Radios = append(config.Radios, RadioConfig{Name: "315mhz"})
+
+	_, err = config.BuildRadios()
+	assert.EqualError(t, err, `duplicate radio name "315mhz"`)
+
+	config.Radios = []RadioConfig{{TransmitPin: 1}}
+
+	_, err = config.BuildRadios()
+	assert.EqualError(t, err, "radio #0: name must not be empty")
+}
+
+func TestConfig_BuildOutletGroups_Radios(t *testing.T) {
+	config := Config{
+		Radios: []RadioConfig{{Name: "315mhz", TransmitPin: 22}},
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{ID: "bar", Radio: "315mhz"},
+					{ID: "baz"},
+				},
 			},
 		},
 	}
 
-	assert.Equal(t, expected, config.BuildOutletGroups())
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+	assert.Equal(t, "315mhz", groups[0].Outlets[0].Radio)
+	assert.Equal(t, "", groups[0].Outlets[1].Radio)
+
+	config.OutletGroups[0].Outlets[1].Radio = "868mhz"
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `outlet "baz": radio "868mhz" does not exist`)
+}
+
+func TestConfig_BuildOutletGroups_Drivers(t *testing.T) {
+	config := Config{
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{ID: "bar"},
+					{ID: "baz", Driver: outlet.DriverShelly, Address: "192.168.1.11", Channel: 1},
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+	assert.Equal(t, outlet.Driver(""), groups[0].Outlets[0].Driver)
+	assert.Equal(t, outlet.DriverShelly, groups[0].Outlets[1].Driver)
+	assert.Equal(t, "192.168.1.11", groups[0].Outlets[1].Address)
+	assert.Equal(t, 1, groups[0].Outlets[1].Channel)
+
+	config.OutletGroups[0].Outlets[1].RefreshInterval = Duration(time.Hour)
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `outlet "baz": refreshInterval is not supported by driver "shelly"`)
+
+	config.OutletGroups[0].Outlets[1].RefreshInterval = 0
+	config.OutletGroups[0].Outlets[1].Address = ""
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `outlet "baz": address is required for driver "shelly"`)
+
+	config.OutletGroups[0].Outlets[1].Driver = "zigbee"
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `outlet "baz": invalid driver "zigbee", expected one of "rf", "tasmota" or "shelly"`)
+}
+
+func TestConfig_BuildOutletGroups_DriftPolicy(t *testing.T) {
+	config := Config{
+		DriftPolicy: outlet.DriftPolicyOverride,
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{ID: "bar"},
+					{ID: "baz", DriftPolicy: outlet.DriftPolicyIgnore},
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+	assert.Equal(t, outlet.DriftPolicyOverride, groups[0].Outlets[0].DriftPolicy)
+	assert.Equal(t, outlet.DriftPolicyIgnore, groups[0].Outlets[1].DriftPolicy)
+
+	config.OutletGroups[0].Outlets[1].DriftPolicy = "revert"
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `outlet "baz": invalid drift policy "revert", expected one of "enforce", "override" or "ignore"`)
+
+	config.DriftPolicy = "revert"
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `invalid drift policy "revert", expected one of "enforce", "override" or "ignore"`)
+}
+
+func TestConfig_BuildOutletGroups_StartupAction(t *testing.T) {
+	config := Config{
+		StartupAction: outlet.StartupActionRestore,
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{ID: "bar"},
+					{ID: "baz", StartupAction: outlet.StartupActionSchedule},
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+	assert.Equal(t, outlet.StartupActionRestore, groups[0].Outlets[0].StartupAction)
+	assert.Equal(t, outlet.StartupActionSchedule, groups[0].Outlets[1].StartupAction)
+
+	config.OutletGroups[0].Outlets[1].StartupAction = "toggle"
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `outlet "baz": invalid startup action "toggle", expected one of "restore", "on", "off", "schedule" or "none"`)
+
+	config.StartupAction = "toggle"
+
+	_, err = config.BuildOutletGroups()
+	assert.EqualError(t, err, `invalid startup action "toggle", expected one of "restore", "on", "off", "schedule" or "none"`)
+}
+
+func TestConfig_BuildOutletGroups_Actions(t *testing.T) {
+	config := Config{
+		GPIO: GPIOConfig{
+			DefaultProtocol:    1,
+			DefaultPulseLength: 123,
+		},
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{
+						ID:        "blinds",
+						Stateless: true,
+						Actions: []ActionConfig{
+							{Name: "up", DisplayName: "Up", Code: 1},
+							{Name: "stop", Code: 2, Protocol: 2, PulseLength: 300},
+						},
+					},
+					{
+						ID:      "bulb",
+						CodeOn:  3,
+						CodeOff: 4,
+						Actions: []ActionConfig{
+							{Name: "brighter", Code: 5, State: "on"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+
+	blinds := groups[0].Outlets[0]
+	assert.True(t, blinds.Stateless)
+	assert.Equal(t, []outlet.Action{
+		{Name: "up", DisplayName: "Up", Code: 1, Protocol: 1, PulseLength: 123},
+		{Name: "stop", DisplayName: "stop", Code: 2, Protocol: 2, PulseLength: 300},
+	}, blinds.Actions)
+
+	on := outlet.StateOn
+
+	bulb := groups[0].Outlets[1]
+	assert.False(t, bulb.Stateless)
+	assert.Equal(t, []outlet.Action{
+		{Name: "brighter", DisplayName: "brighter", Code: 5, Protocol: 1, PulseLength: 123, State: &on},
+	}, bulb.Actions)
+
+	tests := []struct {
+		outlet      OutletConfig
+		expectedErr string
+	}{
+		{
+			outlet:      OutletConfig{ID: "bar", Actions: []ActionConfig{{Code: 1}}},
+			expectedErr: `outlet "bar": action #0: name must not be empty`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Actions: []ActionConfig{{Name: "up"}, {Name: "up"}}},
+			expectedErr: `outlet "bar": duplicate action name "up"`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Actions: []ActionConfig{{Name: "up", State: "open"}}},
+			expectedErr: `outlet "bar": action "up": invalid state "open", expected "on" or "off"`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Stateless: true, Actions: []ActionConfig{{Name: "up", State: "on"}}},
+			expectedErr: `outlet "bar": action "up": state must not be set for stateless outlets`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Driver: outlet.DriverTasmota, Address: "http://localhost", Actions: []ActionConfig{{Name: "up"}}},
+			expectedErr: `outlet "bar": actions are not supported by driver "tasmota"`,
+		},
+	}
+
+	for _, test := range tests {
+		config.OutletGroups[0].Outlets = []OutletConfig{test.outlet}
+
+		_, err := config.BuildOutletGroups()
+		assert.EqualError(t, err, test.expectedErr)
+	}
+}
+
+func TestConfig_BuildOutletGroups_Cover(t *testing.T) {
+	config := Config{
+		GPIO: GPIOConfig{
+			DefaultProtocol:    1,
+			DefaultPulseLength: 123,
+		},
+		OutletGroups: []OutletGroupConfig{
+			{
+				ID: "foo",
+				Outlets: []OutletConfig{
+					{
+						ID:           "blinds",
+						Type:         "cover",
+						CodeUp:       1,
+						CodeDown:     2,
+						CodeStop:     3,
+						TravelTimeUp: Duration(20 * time.Second),
+					},
+					{
+						ID:             "shutter",
+						Type:           "cover",
+						TravelTimeUp:   Duration(20 * time.Second),
+						TravelTimeDown: Duration(15 * time.Second),
+					},
+				},
+			},
+		},
+	}
+
+	groups, err := config.BuildOutletGroups()
+	require.NoError(t, err)
+
+	blinds := groups[0].Outlets[0]
+	assert.True(t, blinds.Stateless)
+	assert.Equal(t, &outlet.Cover{
+		CodeUp:         1,
+		CodeDown:       2,
+		CodeStop:       3,
+		TravelTimeUp:   20 * time.Second,
+		TravelTimeDown: 20 * time.Second,
+	}, blinds.Cover)
+
+	shutter := groups[0].Outlets[1]
+	assert.Equal(t, 15*time.Second, shutter.Cover.TravelTimeDown)
+
+	tests := []struct {
+		outlet      OutletConfig
+		expectedErr string
+	}{
+		{
+			outlet:      OutletConfig{ID: "bar", Type: "blind"},
+			expectedErr: `outlet "bar": invalid type "blind", expected "outlet" or "cover"`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Type: "cover"},
+			expectedErr: `outlet "bar": travelTimeUp must be greater than zero for covers`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Type: "cover", TravelTimeUp: Duration(time.Second), RecordingOn: "on.json"},
+			expectedErr: `outlet "bar": recordings are not supported for covers`,
+		},
+		{
+			outlet:      OutletConfig{ID: "bar", Type: "cover", TravelTimeUp: Duration(time.Second), Driver: outlet.DriverShelly, Address: "http://localhost"},
+			expectedErr: `outlet "bar": covers are not supported by driver "shelly"`,
+		},
+	}
+
+	for _, test := range tests {
+		config.OutletGroups[0].Outlets = []OutletConfig{test.outlet}
+
+		_, err := config.BuildOutletGroups()
+		assert.EqualError(t, err, test.expectedErr)
+	}
+}
+
+func TestConfig_BuildWebhooks(t *testing.T) {
+	config := Config{
+		Webhooks: []WebhookConfig{
+			{URL: "https://example.com/hook", Groups: []string{"foo"}, Secret: "s3cr3t"},
+			{URL: "http://localhost:8080", Retry: RetryConfig{MaxAttempts: 2, MaxBackoff: Duration(10 * time.Second)}},
+		},
+	}
+
+	webhooks, err := config.BuildWebhooks()
+	require.NoError(t, err)
+
+	assert.Equal(t, []webhook.Webhook{
+		{
+			URL:    "https://example.com/hook",
+			Groups: []string{"foo"},
+			Secret: "s3cr3t",
+			Retry: webhook.RetryPolicy{
+				MaxAttempts:    webhook.DefaultMaxAttempts,
+				InitialBackoff: webhook.DefaultInitialBackoff,
+				MaxBackoff:     webhook.DefaultMaxBackoff,
+			},
+		},
+		{
+			URL: "http://localhost:8080",
+			Retry: webhook.RetryPolicy{
+				MaxAttempts:    2,
+				InitialBackoff: webhook.DefaultInitialBackoff,
+				MaxBackoff:     10 * time.Second,
+			},
+		},
+	}, webhooks)
+
+	config.Webhooks[1].URL = "localhost:8080"
+
+	_, err = config.BuildWebhooks()
+	assert.EqualError(t, err, `webhook #1: invalid url "localhost:8080"`)
+}
+
+func TestConfig_BuildTriggers(t *testing.T) {
+	config := Config{
+		GPIO: GPIOConfig{DefaultProtocol: 1},
+		OutletGroups: []OutletGroupConfig{
+			{ID: "foo", Outlets: []OutletConfig{{ID: "bar"}, {ID: "baz"}}},
+		},
+		Triggers: []TriggerConfig{
+			{Name: "button", Code: 1, Outlet: "bar"},
+			{Code: 2, Protocol: 2, Debounce: Duration(5 * time.Second), Group: "foo", Action: command.OffOutletAction},
+			{Code: 3, Scene: map[string]command.OutletAction{"bar": command.OnOutletAction, "baz": command.OffOutletAction}},
+			{Name: "doorbell", Code: 4, Webhook: &WebhookConfig{URL: "http://localhost:8080"}},
+		},
+	}
+
+	triggers, err := config.BuildTriggers()
+	require.NoError(t, err)
+
+	assert.Equal(t, []trigger.Trigger{
+		{Name: "button", Code: 1, Protocol: 1, Debounce: trigger.DefaultDebounce, OutletID: "bar", Action: command.ToggleOutletAction},
+		{Name: "#1", Code: 2, Protocol: 2, Debounce: 5 * time.Second, GroupID: "foo", Action: command.OffOutletAction},
+		{
+			Name:     "#2",
+			Code:     3,
+			Protocol: 1,
+			Debounce: trigger.DefaultDebounce,
+			Action:   command.ToggleOutletAction,
+			Scene:    map[string]command.OutletAction{"bar": command.OnOutletAction, "baz": command.OffOutletAction},
+		},
+		{
+			Name:     "doorbell",
+			Code:     4,
+			Protocol: 1,
+			Debounce: trigger.DefaultDebounce,
+			Action:   command.ToggleOutletAction,
+			Webhook: &webhook.Webhook{
+				URL: "http://localhost:8080",
+				Retry: webhook.RetryPolicy{
+					MaxAttempts:    webhook.DefaultMaxAttempts,
+					InitialBackoff: webhook.DefaultInitialBackoff,
+					MaxBackoff:     webhook.DefaultMaxBackoff,
+				},
+			},
+		},
+	}, triggers)
+
+	tests := []struct {
+		trigger     TriggerConfig
+		expectedErr string
+	}{
+		{
+			trigger:     TriggerConfig{Code: 1},
+			expectedErr: `trigger "#0": exactly one of outlet, group, scene or webhook must be set`,
+		},
+		{
+			trigger:     TriggerConfig{Code: 1, Outlet: "bar", Group: "foo"},
+			expectedErr: `trigger "#0": exactly one of outlet, group, scene or webhook must be set`,
+		},
+		{
+			trigger:     TriggerConfig{Code: 1, Outlet: "qux"},
+			expectedErr: `trigger "#0": outlet "qux" does not exist`,
+		},
+		{
+			trigger:     TriggerConfig{Code: 1, Group: "qux"},
+			expectedErr: `trigger "#0": group "qux" does not exist`,
+		},
+		{
+			trigger:     TriggerConfig{Code: 1, Outlet: "bar", Action: "dim"},
+			expectedErr: `trigger "#0": invalid action "dim", expected one of "on", "off" or "toggle"`,
+		},
+		{
+			trigger:     TriggerConfig{Code: 1, Scene: map[string]command.OutletAction{"bar": ""}},
+			expectedErr: `trigger "#0": scene: outlet "bar": invalid action "", expected one of "on", "off" or "toggle"`,
+		},
+		{
+			trigger:     TriggerConfig{Code: 1, Webhook: &WebhookConfig{URL: "localhost"}},
+			expectedErr: `trigger "#0": webhook: invalid url "localhost"`,
+		},
+	}
+
+	for _, test := range tests {
+		config.Triggers = []TriggerConfig{test.trigger}
+
+		_, err := config.BuildTriggers()
+		assert.EqualError(t, err, test.expectedErr)
+	}
 }
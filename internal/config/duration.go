@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration which can be unmarshaled from duration strings
+// like "500ms" or "1m30s" as well as from integer values in nanoseconds.
+type Duration time.Duration
+
+// Duration returns d as time.Duration.
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(buf []byte) error {
+	var v interface{}
+
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return err
+	}
+
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value)
+	case string:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		*d = Duration(duration)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(buf))
+	}
+
+	return nil
+}
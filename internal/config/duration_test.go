@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Duration
+		expectedErr bool
+	}{
+		{input: `"500ms"`, expected: Duration(500 * time.Millisecond)},
+		{input: `"1m30s"`, expected: Duration(90 * time.Second)},
+		{input: `1000`, expected: Duration(1000)},
+		{input: `"foo"`, expectedErr: true},
+		{input: `true`, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var d Duration
+
+			err := json.Unmarshal([]byte(test.input), &d)
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, d)
+		})
+	}
+}
+
+func TestDuration_YAMLRoundTrip(t *testing.T) {
+	in := struct {
+		Window Duration `json:"window"`
+	}{Duration(2 * time.Second)}
+
+	buf, err := yaml.Marshal(in)
+	require.NoError(t, err)
+	assert.Equal(t, "window: 2s\n", string(buf))
+
+	out := in
+	out.Window = 0
+
+	require.NoError(t, yaml.Unmarshal(buf, &out))
+	assert.Equal(t, in, out)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/ghodss/yaml"
+)
+
+// EnvPrefix is the prefix of all environment variables that are considered
+// when loading config values from the environment.
+const EnvPrefix = "RFOUTLET"
+
+// LoadEnv overrides fields of c with the values of matching RFOUTLET_*
+// environment variables. The variable names are derived from the json tags of
+// the config fields by converting them to upper snake case and joining nested
+// fields using underscores, e.g. RFOUTLET_LISTEN_ADDRESS or
+// RFOUTLET_GPIO_TRANSMIT_PIN. Values of fields that are not strings are
+// parsed as YAML. This allows to also set lists like outletGroups, e.g.
+// RFOUTLET_OUTLET_GROUPS='[{"id":"foo","outlets":[{"id":"bar"}]}]'.
+// In contrast to config fragments (see LoadDir), lists set via environment
+// variables replace the existing list instead of being appended to it.
+func LoadEnv(c *Config) error {
+	return applyEnv(c, EnvPrefix, os.LookupEnv)
+}
+
+func applyEnv(c *Config, prefix string, lookup func(string) (string, bool)) error {
+	return applyEnvToStruct(reflect.ValueOf(c).Elem(), prefix, lookup)
+}
+
+func applyEnvToStruct(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := jsonFieldName(field)
+		if name == "" {
+			continue
+		}
+
+		key := prefix + "_" + envName(name)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnvToStruct(fv, key, lookup); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		raw, ok := lookup(key)
+		if !ok {
+			continue
+		}
+
+		if err := setFieldValue(fv, raw); err != nil {
+			return fmt.Errorf("invalid value for environment variable %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+func setFieldValue(v reflect.Value, raw string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(raw)
+		return nil
+	}
+
+	// Unmarshal into a fresh value to avoid merging list values with
+	// previously loaded ones.
+	ptr := reflect.New(v.Type())
+
+	if err := yaml.Unmarshal([]byte(raw), ptr.Interface()); err != nil {
+		return err
+	}
+
+	v.Set(ptr.Elem())
+
+	return nil
+}
+
+// jsonFieldName returns the name of field as defined by its json tag. Returns
+// an empty string for unexported fields and fields that are excluded from
+// json.
+func jsonFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+// envName converts a camel case name into upper snake case, e.g. listenAddress
+// becomes LISTEN_ADDRESS.
+func envName(name string) string {
+	var sb strings.Builder
+
+	runes := []rune(name)
+
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(runes[i-1]) {
+			sb.WriteRune('_')
+		}
+
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/ghodss/yaml"
+)
+
+// mergeYAML merges the YAML document data into dst. Only keys that are
+// present in data override the values in dst, which allows to override
+// non-empty values with empty ones, e.g. false or 0. Lists in data are
+// appended to those in dst.
+func mergeYAML(dst *Config, data []byte) error {
+	merged := *dst
+
+	// Clear the lists before unmarshaling so that merged only contains the
+	// list items of data. Unmarshaling into a non-empty slice would also
+	// overwrite the items of the slice shared with dst.
+	walkLists(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(dst).Elem(), func(merged, _ reflect.Value) {
+		merged.Set(reflect.Zero(merged.Type()))
+	})
+
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return err
+	}
+
+	walkLists(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(dst).Elem(), func(merged, dst reflect.Value) {
+		items := reflect.MakeSlice(dst.Type(), 0, dst.Len()+merged.Len())
+		merged.Set(reflect.AppendSlice(reflect.AppendSlice(items, dst), merged))
+	})
+
+	*dst = merged
+
+	return nil
+}
+
+// walkLists calls fn for all pairs of corresponding list fields of the
+// structs a and b, including those of nested structs.
+func walkLists(a, b reflect.Value, fn func(a, b reflect.Value)) {
+	for i := 0; i < a.NumField(); i++ {
+		fa, fb := a.Field(i), b.Field(i)
+		if !fa.CanSet() {
+			continue
+		}
+
+		switch fa.Kind() {
+		case reflect.Struct:
+			walkLists(fa, fb, fn)
+		case reflect.Slice:
+			fn(fa, fb)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
@@ -19,6 +20,29 @@ type Broadcaster interface {
 	Broadcast(msg []byte)
 }
 
+// StateChange describes the state change of a single outlet.
+type StateChange struct {
+	// Outlet is the outlet that changed its state.
+	Outlet *outlet.Outlet
+	// GroupID is the ID of the group the outlet belongs to.
+	GroupID string
+	// PreviousState is the state of the outlet before the change.
+	PreviousState outlet.State
+	// State is the new state of the outlet.
+	State outlet.State
+	// Source is the source of the command that caused the change.
+	Source command.Source
+	// Time is the time of the state change.
+	Time time.Time
+}
+
+// StateChangeNotifier is notified about outlet state changes.
+type StateChangeNotifier interface {
+	// NotifyStateChange is called for every outlet that changed its state.
+	// It must not block.
+	NotifyStateChange(change StateChange)
+}
+
 // Controller controls the outlets registered to the registry.
 type Controller struct {
 	// Registry contains all known outlets and outlet groups.
@@ -32,6 +56,9 @@ type Controller struct {
 	// may cause outlet and group state changes which are communicated back to
 	// one or more connected clients.
 	CommandQueue <-chan command.Command
+	// Notifier is optional. If set, it is notified about every outlet state
+	// change that is broadcasted.
+	Notifier StateChangeNotifier
 }
 
 // Run runs the main control loop until stopCh is closed.
@@ -72,14 +99,68 @@ func (c *Controller) handleCommand(cmd command.Command) error {
 
 	ctx := c.commandContext()
 
+	var states map[*outlet.Outlet]outlet.State
+	if c.Notifier != nil {
+		states = c.outletStates()
+	}
+
 	broadcast, err := cmd.Execute(ctx)
 	if err != nil || !broadcast {
 		return err
 	}
 
+	if c.Notifier != nil {
+		c.notifyStateChanges(states, command.SourceOf(cmd))
+	}
+
 	return c.broadcastState()
 }
 
+// outletStates returns a snapshot of the states of all outlets.
+func (c *Controller) outletStates() map[*outlet.Outlet]outlet.State {
+	states := make(map[*outlet.Outlet]outlet.State)
+
+	for _, o := range c.Registry.GetOutlets() {
+		states[o] = o.GetState()
+	}
+
+	return states
+}
+
+// notifyStateChanges notifies the Notifier about all outlets whose state
+// differs from the snapshot in states.
+func (c *Controller) notifyStateChanges(states map[*outlet.Outlet]outlet.State, source command.Source) {
+	now := time.Now()
+
+	groupIDs := make(map[*outlet.Outlet]string)
+	for _, group := range c.Registry.GetGroups() {
+		for _, o := range group.Outlets {
+			groupIDs[o] = group.ID
+		}
+	}
+
+	for _, o := range c.Registry.GetOutlets() {
+		previous, ok := states[o]
+		if !ok {
+			continue
+		}
+
+		state := o.GetState()
+		if state == previous {
+			continue
+		}
+
+		c.Notifier.NotifyStateChange(StateChange{
+			Outlet:        o,
+			GroupID:       groupIDs[o],
+			PreviousState: previous,
+			State:         state,
+			Source:        source,
+			Time:          now,
+		})
+	}
+}
+
 // broadcastState broadcasts the current outlet group state back to connected
 // clients.
 func (c *Controller) broadcastState() error {
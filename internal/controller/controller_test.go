@@ -9,6 +9,7 @@ import (
 	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type fakeSwitcher struct{}
@@ -105,3 +106,49 @@ func TestController(t *testing.T) {
 		})
 	}
 }
+
+type testNotifier chan StateChange
+
+func (n testNotifier) NotifyStateChange(change StateChange) {
+	n <- change
+}
+
+func TestController_Notifier(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "foo", State: outlet.StateOff}
+	o2 := &outlet.Outlet{ID: "bar", State: outlet.StateOff}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterGroups(&outlet.Group{ID: "baz", Outlets: []*outlet.Outlet{o1, o2}})
+
+	n := make(testNotifier, 2)
+
+	c := &Controller{
+		Registry:    reg,
+		Switcher:    &outlet.FakeSwitch{},
+		Broadcaster: make(testBroadcaster, 2),
+		Notifier:    n,
+	}
+
+	err := c.handleCommand(command.StateCorrectionCommand{
+		Outlet:       o2,
+		DesiredState: outlet.StateOn,
+		Source:       command.SourceSchedule,
+	})
+	assert.NoError(t, err)
+
+	require.Len(t, n, 1)
+
+	change := <-n
+	assert.Equal(t, o2, change.Outlet)
+	assert.Equal(t, "baz", change.GroupID)
+	assert.Equal(t, outlet.StateOff, change.PreviousState)
+	assert.Equal(t, outlet.StateOn, change.State)
+	assert.Equal(t, command.SourceSchedule, change.Source)
+
+	err = c.handleCommand(command.OutletCommand{OutletID: "foo", Action: command.OnOutletAction})
+	assert.NoError(t, err)
+
+	change = <-n
+	assert.Equal(t, o1, change.Outlet)
+	assert.Equal(t, command.SourceUser, change.Source)
+}
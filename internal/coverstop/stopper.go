@@ -0,0 +1,81 @@
+// Package coverstop provides a stopper which stops moving covers once they
+// reached their target position. Covers do not report their position, so the
+// moment to send the stop code is computed from the cover's travel time.
+package coverstop
+
+import (
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("component", "coverstop")
+
+// DefaultTick is the default interval in which the stopper checks for covers
+// that reached their target position. It limits the precision of the
+// estimated cover positions.
+const DefaultTick = 100 * time.Millisecond
+
+// Stopper pushes a command into the command queue for every moving cover
+// once its target position is reached.
+type Stopper struct {
+	Registry     *outlet.Registry
+	CommandQueue chan<- command.Command
+	Clock        clockwork.Clock
+	Tick         time.Duration
+
+	// stopped holds the arrival times for which a stop command was already
+	// pushed, so that a cover is only stopped once per move.
+	stopped map[*outlet.Outlet]time.Time
+}
+
+// New creates a new *Stopper which will observe the covers in the registry.
+func New(registry *outlet.Registry, queue chan<- command.Command) *Stopper {
+	return &Stopper{
+		Registry:     registry,
+		CommandQueue: queue,
+		Clock:        clockwork.NewRealClock(),
+		Tick:         DefaultTick,
+		stopped:      make(map[*outlet.Outlet]time.Time),
+	}
+}
+
+// Run runs the stopper loop until stopCh is closed.
+func (s *Stopper) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-s.Clock.After(s.Tick):
+			s.check()
+		case <-stopCh:
+			log.Info("shutting down cover stopper")
+			return
+		}
+	}
+}
+
+func (s *Stopper) check() {
+	now := s.Clock.Now()
+
+	for _, o := range s.Registry.GetOutlets() {
+		if o.Cover == nil {
+			continue
+		}
+
+		arrival, ok := o.Cover.Arrival()
+		if !ok || arrival.After(now) || s.stopped[o].Equal(arrival) {
+			continue
+		}
+
+		log.WithField("outletID", o.ID).Debug("cover reached target position")
+
+		s.stopped[o] = arrival
+
+		s.CommandQueue <- command.CoverStopCommand{
+			Outlet:  o,
+			Arrival: arrival,
+		}
+	}
+}
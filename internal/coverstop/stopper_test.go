@@ -0,0 +1,50 @@
+package coverstop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopper(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "foo", Cover: &outlet.Cover{TravelTimeUp: 10 * time.Second, TravelTimeDown: 10 * time.Second}}
+	o2 := &outlet.Outlet{ID: "bar"}
+	o3 := &outlet.Outlet{ID: "baz", Cover: &outlet.Cover{TravelTimeUp: 10 * time.Second, TravelTimeDown: 10 * time.Second}}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2, o3)
+
+	queue := make(chan command.Command, 10)
+	fakeClock := clockwork.NewFakeClock()
+
+	s := New(reg, queue)
+	s.Clock = fakeClock
+
+	o1.Cover.Move(40, fakeClock.Now())
+
+	checkAfter := func(d time.Duration) {
+		fakeClock.Advance(d)
+		s.check()
+	}
+
+	checkAfter(3 * time.Second)
+	assert.Len(t, queue, 0)
+
+	checkAfter(time.Second)
+	assert.Equal(t, command.CoverStopCommand{Outlet: o1, Arrival: fakeClock.Now()}, <-queue)
+
+	// Covers are only stopped once per move.
+	checkAfter(time.Second)
+	assert.Len(t, queue, 0)
+
+	o1.Cover.Arrive()
+	o3.Cover.Move(outlet.PositionOpen, fakeClock.Now())
+
+	checkAfter(10 * time.Second)
+	assert.Equal(t, command.CoverStopCommand{Outlet: o3, Arrival: fakeClock.Now()}, <-queue)
+	assert.Len(t, queue, 0)
+}
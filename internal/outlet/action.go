@@ -0,0 +1,34 @@
+package outlet
+
+// Action is a named code of a device that does more than switching on and
+// off, e.g. the stop button of blinds or the brighter and dimmer buttons of
+// dimmable bulbs.
+type Action struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Code        uint64 `json:"-"`
+	Protocol    int    `json:"-"`
+	PulseLength uint   `json:"-"`
+	// State is the state the outlet is in after the action was performed.
+	// If nil, the action does not change the outlet state.
+	State *State `json:"-"`
+}
+
+// ActionSwitcher defines the interface for switchers that can perform named
+// actions on outlets.
+type ActionSwitcher interface {
+	// SwitchAction performs action on outlet.
+	SwitchAction(outlet *Outlet, action Action) error
+}
+
+// GetAction returns the action with name. The second return value is false
+// if the outlet does not have such an action.
+func (o *Outlet) GetAction(name string) (Action, bool) {
+	for _, action := range o.Actions {
+		if action.Name == name {
+			return action, true
+		}
+	}
+
+	return Action{}, false
+}
@@ -0,0 +1,118 @@
+package outlet
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceSwitcher defines the interface for switchers of devices that
+// acknowledge switch requests, e.g. Wi-Fi plugs. In contrast to Switcher, the
+// state of the outlet is not updated.
+type DeviceSwitcher interface {
+	// SwitchDevice switches the device of outlet into state. Returns an
+	// error if the device could not be reached or did not switch.
+	SwitchDevice(outlet *Outlet, state State) error
+}
+
+// SwitchFailedFunc is called by an *AsyncSwitch if outlet could not be
+// switched into state. The outlet was in state previous before.
+type SwitchFailedFunc func(outlet *Outlet, state, previous State, err error)
+
+// AsyncSwitch switches devices in the background, so that slow or
+// unreachable devices do not block the caller. Switch optimistically updates
+// the outlet state and returns immediately. Each outlet has its own worker,
+// so an unreachable device only delays the requests to itself. If an outlet
+// is switched again while a request is still pending, only the latest state
+// is sent to the device.
+type AsyncSwitch struct {
+	Device DeviceSwitcher
+	// Failed is optional. If set, it is called from the worker of the outlet
+	// for every failed request.
+	Failed SwitchFailedFunc
+
+	mu      sync.Mutex
+	pending map[*Outlet]*asyncRequest
+	busy    map[*Outlet]bool
+}
+
+type asyncRequest struct {
+	state    State
+	previous State
+}
+
+// NewAsyncSwitch creates a new *AsyncSwitch which switches devices using
+// device and reports failed requests to failed.
+func NewAsyncSwitch(device DeviceSwitcher, failed SwitchFailedFunc) *AsyncSwitch {
+	return &AsyncSwitch{
+		Device:  device,
+		Failed:  failed,
+		pending: make(map[*Outlet]*asyncRequest),
+		busy:    make(map[*Outlet]bool),
+	}
+}
+
+// Switch implements Switcher.
+func (s *AsyncSwitch) Switch(o *Outlet, state State) error {
+	previous := o.GetState()
+	o.SetState(state)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req, ok := s.pending[o]; ok {
+		req.state = state
+		return nil
+	}
+
+	s.pending[o] = &asyncRequest{state: state, previous: previous}
+
+	if !s.busy[o] {
+		s.busy[o] = true
+		go s.work(o)
+	}
+
+	return nil
+}
+
+// ReadState implements StateReader. Returns ErrStateUnsupported if the
+// device switcher cannot read back the state.
+func (s *AsyncSwitch) ReadState(o *Outlet) (State, error) {
+	reader, ok := s.Device.(StateReader)
+	if !ok {
+		return 0, ErrStateUnsupported
+	}
+
+	return reader.ReadState(o)
+}
+
+// work sends the pending requests of o to its device until there are none
+// left.
+func (s *AsyncSwitch) work(o *Outlet) {
+	for {
+		s.mu.Lock()
+		req, ok := s.pending[o]
+		if !ok {
+			delete(s.busy, o)
+			s.mu.Unlock()
+			return
+		}
+
+		delete(s.pending, o)
+		s.mu.Unlock()
+
+		err := s.Device.SwitchDevice(o, req.state)
+		if err == nil {
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"outletID":     o.ID,
+			"desiredState": req.state,
+		}).Errorf("failed to switch device: %v", err)
+
+		if s.Failed != nil {
+			s.Failed(o, req.state, req.previous, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package outlet
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDevice struct {
+	calls   chan string
+	release chan struct{}
+	err     error
+}
+
+func newFakeDevice(err error) *fakeDevice {
+	return &fakeDevice{
+		calls:   make(chan string, 10),
+		release: make(chan struct{}),
+		err:     err,
+	}
+}
+
+func (d *fakeDevice) SwitchDevice(o *Outlet, state State) error {
+	d.calls <- o.ID + ":" + state.String()
+	<-d.release
+	return d.err
+}
+
+func (d *fakeDevice) nextCall(t *testing.T) string {
+	select {
+	case call := <-d.calls:
+		return call
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for device call")
+		return ""
+	}
+}
+
+func TestAsyncSwitch(t *testing.T) {
+	device := newFakeDevice(nil)
+
+	s := NewAsyncSwitch(device, nil)
+
+	o := &Outlet{ID: "foo", State: StateOff}
+	other := &Outlet{ID: "bar", State: StateOff}
+
+	// Switch does not wait for the device.
+	require.NoError(t, s.Switch(o, StateOn))
+	assert.Equal(t, StateOn, o.GetState())
+	assert.Equal(t, "foo:on", device.nextCall(t))
+
+	// Other outlets are not blocked by the pending request.
+	require.NoError(t, s.Switch(other, StateOn))
+	assert.Equal(t, "bar:on", device.nextCall(t))
+
+	// Requests are coalesced while the first one is in flight.
+	require.NoError(t, s.Switch(o, StateOn))
+	require.NoError(t, s.Switch(o, StateOff))
+
+	close(device.release)
+
+	assert.Equal(t, "foo:off", device.nextCall(t))
+
+	select {
+	case call := <-device.calls:
+		t.Fatalf("unexpected device call %s", call)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAsyncSwitch_Failed(t *testing.T) {
+	device := newFakeDevice(errors.New("unreachable"))
+	close(device.release)
+
+	type failure struct {
+		state, previous State
+		err             error
+	}
+
+	failures := make(chan failure, 1)
+
+	s := NewAsyncSwitch(device, func(_ *Outlet, state, previous State, err error) {
+		failures <- failure{state, previous, err}
+	})
+
+	o := &Outlet{ID: "foo", State: StateOff}
+
+	require.NoError(t, s.Switch(o, StateOn))
+
+	select {
+	case f := <-failures:
+		assert.Equal(t, failure{StateOn, StateOff, device.err}, f)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for failure")
+	}
+
+	_, err := s.ReadState(o)
+	assert.Equal(t, ErrStateUnsupported, err)
+}
@@ -0,0 +1,237 @@
+package outlet
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+)
+
+// Positions of fully opened and fully closed covers.
+const (
+	PositionClosed = 0
+	PositionOpen   = 100
+)
+
+// Motion describes the direction a cover is moving in.
+type Motion string
+
+const (
+	// MotionStopped describes a cover that is not moving.
+	MotionStopped Motion = "stopped"
+	// MotionUp describes a cover that is opening.
+	MotionUp Motion = "up"
+	// MotionDown describes a cover that is closing.
+	MotionDown Motion = "down"
+)
+
+// Cover is a motorised blind or shutter. Covers do not report their
+// position, so it is estimated from the time the motor has been running.
+type Cover struct {
+	sync.Mutex
+	CodeUp   uint64
+	CodeDown uint64
+	CodeStop uint64
+	// TravelTimeUp and TravelTimeDown are the durations the cover takes to
+	// fully open from the closed position and vice versa.
+	TravelTimeUp   time.Duration
+	TravelTimeDown time.Duration
+
+	// position is the position in percent at the time the current motion
+	// started, or the current position if the cover is stopped.
+	position float64
+	motion   Motion
+	since    time.Time
+	target   int
+}
+
+// CoverSwitcher defines the interface for switchers that can move covers.
+type CoverSwitcher interface {
+	// SwitchCover sends the code for motion to the cover of outlet.
+	SwitchCover(outlet *Outlet, motion Motion) error
+}
+
+// Position returns the estimated position of the cover at now in percent,
+// where 0 is fully closed and 100 is fully open.
+func (c *Cover) Position(now time.Time) int {
+	c.Lock()
+	defer c.Unlock()
+	return int(math.Round(c.positionAt(now)))
+}
+
+// SetPosition sets the position of a stopped cover, e.g. after restoring it
+// from the state file. Positions outside of 0-100 are clamped.
+func (c *Cover) SetPosition(position int) {
+	c.Lock()
+	c.position = clampPosition(float64(position))
+	c.motion = MotionStopped
+	c.Unlock()
+}
+
+// Motion returns the direction the cover is currently moving in.
+func (c *Cover) Motion() Motion {
+	c.Lock()
+	defer c.Unlock()
+	return c.currentMotion()
+}
+
+// Target returns the position the cover is moving to. The second return
+// value is false if the cover is not moving.
+func (c *Cover) Target() (int, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.currentMotion() == MotionStopped {
+		return 0, false
+	}
+
+	return c.target, true
+}
+
+// Arrival returns the time at which the cover reaches its target position.
+// The second return value is false if the cover is not moving.
+func (c *Cover) Arrival() (time.Time, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.currentMotion() == MotionStopped {
+		return time.Time{}, false
+	}
+
+	distance := math.Abs(float64(c.target) - c.position)
+
+	return c.since.Add(c.travelTime(distance)), true
+}
+
+// MotionTo returns the direction the cover has to move in to get from its
+// estimated position at now to target. Returns MotionStopped if the cover is
+// already at target.
+func (c *Cover) MotionTo(target int, now time.Time) Motion {
+	c.Lock()
+	defer c.Unlock()
+	return motionBetween(c.positionAt(now), target)
+}
+
+// Move starts moving the cover from its estimated position at now to target.
+func (c *Cover) Move(target int, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.position = c.positionAt(now)
+	c.target = int(clampPosition(float64(target)))
+	c.motion = motionBetween(c.position, c.target)
+	c.since = now
+}
+
+// Stop stops the cover at its estimated position at now.
+func (c *Cover) Stop(now time.Time) {
+	c.Lock()
+	c.position = c.positionAt(now)
+	c.motion = MotionStopped
+	c.Unlock()
+}
+
+// Arrive stops the cover at its target position. This avoids accumulating
+// rounding errors if the cover was stopped slightly after it reached its
+// target.
+func (c *Cover) Arrive() {
+	c.Lock()
+	if c.currentMotion() != MotionStopped {
+		c.position = float64(c.target)
+		c.motion = MotionStopped
+	}
+	c.Unlock()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c *Cover) MarshalJSON() ([]byte, error) {
+	target, _ := c.Target()
+
+	return json.Marshal(struct {
+		Position int    `json:"position"`
+		Motion   Motion `json:"motion"`
+		Target   int    `json:"target"`
+	}{
+		Position: c.Position(time.Now()),
+		Motion:   c.Motion(),
+		Target:   target,
+	})
+}
+
+// currentMotion returns the motion of the cover. The zero value of Cover is
+// stopped. Must be called with the lock held.
+func (c *Cover) currentMotion() Motion {
+	if c.motion == "" {
+		return MotionStopped
+	}
+
+	return c.motion
+}
+
+// positionAt returns the estimated position at now. Must be called with the
+// lock held.
+func (c *Cover) positionAt(now time.Time) float64 {
+	travelTime := c.TravelTimeUp
+	sign := 1.0
+
+	switch c.currentMotion() {
+	case MotionStopped:
+		return c.position
+	case MotionDown:
+		travelTime = c.TravelTimeDown
+		sign = -1.0
+	}
+
+	if travelTime <= 0 {
+		return clampPosition(float64(c.target))
+	}
+
+	delta := float64(now.Sub(c.since)) / float64(travelTime) * 100
+
+	position := c.position + sign*delta
+	if sign*(position-float64(c.target)) > 0 {
+		position = float64(c.target)
+	}
+
+	return clampPosition(position)
+}
+
+// travelTime returns the time the cover takes to travel distance percent in
+// its current direction. Must be called with the lock held.
+func (c *Cover) travelTime(distance float64) time.Duration {
+	travelTime := c.TravelTimeUp
+	if c.currentMotion() == MotionDown {
+		travelTime = c.TravelTimeDown
+	}
+
+	return time.Duration(distance / 100 * float64(travelTime))
+}
+
+// codeForMotion returns the code to transmit to start motion.
+func (c *Cover) codeForMotion(motion Motion) uint64 {
+	switch motion {
+	case MotionUp:
+		return c.CodeUp
+	case MotionDown:
+		return c.CodeDown
+	default:
+		return c.CodeStop
+	}
+}
+
+// motionBetween returns the direction to move in to get from position to
+// target.
+func motionBetween(position float64, target int) Motion {
+	switch diff := float64(target) - position; {
+	case diff >= 0.5:
+		return MotionUp
+	case diff <= -0.5:
+		return MotionDown
+	default:
+		return MotionStopped
+	}
+}
+
+func clampPosition(position float64) float64 {
+	return math.Max(PositionClosed, math.Min(PositionOpen, position))
+}
@@ -0,0 +1,112 @@
+package outlet
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/martinohmann/rfoutlet/internal/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCover(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := &Cover{TravelTimeUp: 20 * time.Second, TravelTimeDown: 10 * time.Second}
+
+	assert.Equal(t, MotionStopped, c.Motion())
+	assert.Equal(t, PositionClosed, c.Position(now))
+	assert.Equal(t, MotionUp, c.MotionTo(40, now))
+	assert.Equal(t, MotionStopped, c.MotionTo(0, now))
+
+	_, ok := c.Arrival()
+	assert.False(t, ok)
+
+	c.Move(40, now)
+
+	assert.Equal(t, MotionUp, c.Motion())
+	assert.Equal(t, 20, c.Position(now.Add(4*time.Second)))
+
+	arrival, ok := c.Arrival()
+	require.True(t, ok)
+	assert.Equal(t, now.Add(8*time.Second), arrival)
+
+	target, ok := c.Target()
+	require.True(t, ok)
+	assert.Equal(t, 40, target)
+
+	// The estimated position never exceeds the target.
+	assert.Equal(t, 40, c.Position(now.Add(10*time.Second)))
+
+	c.Arrive()
+
+	assert.Equal(t, MotionStopped, c.Motion())
+	assert.Equal(t, 40, c.Position(now.Add(time.Minute)))
+
+	_, ok = c.Target()
+	assert.False(t, ok)
+
+	// Closing uses the travel time down.
+	now = now.Add(time.Minute)
+
+	c.Move(PositionClosed, now)
+
+	assert.Equal(t, MotionDown, c.Motion())
+	assert.Equal(t, 20, c.Position(now.Add(2*time.Second)))
+
+	arrival, ok = c.Arrival()
+	require.True(t, ok)
+	assert.Equal(t, now.Add(4*time.Second), arrival)
+
+	c.Stop(now.Add(time.Second))
+
+	assert.Equal(t, MotionStopped, c.Motion())
+	assert.Equal(t, 30, c.Position(now.Add(time.Minute)))
+
+	c.SetPosition(150)
+	assert.Equal(t, PositionOpen, c.Position(now))
+}
+
+func TestCover_MarshalJSON(t *testing.T) {
+	c := &Cover{TravelTimeUp: time.Hour, TravelTimeDown: time.Hour}
+	c.SetPosition(30)
+
+	buf, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.Equal(t, `{"position":30,"motion":"stopped","target":0}`, string(buf))
+
+	c.Move(80, time.Now())
+
+	buf, err = json.Marshal(c)
+	require.NoError(t, err)
+	assert.Equal(t, `{"position":30,"motion":"up","target":80}`, string(buf))
+}
+
+func TestOutlet_ScheduledPosition(t *testing.T) {
+	monday := time.Date(2018, 11, 5, 1, 0, 0, 0, time.UTC)
+	position := 40
+
+	o := &Outlet{
+		Cover: &Cover{},
+		Schedule: schedule.NewWithIntervals([]schedule.Interval{
+			{
+				Enabled:  true,
+				Weekdays: []time.Weekday{time.Monday},
+				From:     schedule.NewDayTime(0, 0),
+				To:       schedule.NewDayTime(2, 0),
+				Position: &position,
+			},
+			{
+				Enabled:  true,
+				Weekdays: []time.Weekday{time.Monday},
+				From:     schedule.NewDayTime(2, 0),
+				To:       schedule.NewDayTime(4, 0),
+			},
+		}),
+	}
+
+	assert.Equal(t, 40, o.ScheduledPosition(monday))
+	assert.Equal(t, PositionOpen, o.ScheduledPosition(monday.Add(2*time.Hour)))
+	assert.Equal(t, PositionClosed, o.ScheduledPosition(monday.Add(4*time.Hour)))
+}
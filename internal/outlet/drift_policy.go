@@ -0,0 +1,31 @@
+package outlet
+
+import "fmt"
+
+// DriftPolicy defines how state drift of outlets with an enabled schedule is
+// handled, e.g. if the outlet was switched using the physical remote control.
+type DriftPolicy string
+
+const (
+	// DriftPolicyEnforce re-transmits the scheduled state immediately. This
+	// is the default if no policy is set.
+	DriftPolicyEnforce DriftPolicy = "enforce"
+
+	// DriftPolicyOverride treats the detected state as a manual override
+	// which is kept until the next schedule transition.
+	DriftPolicyOverride DriftPolicy = "override"
+
+	// DriftPolicyIgnore ignores the detected state change.
+	DriftPolicyIgnore DriftPolicy = "ignore"
+)
+
+// Validate returns an error if p is not a known drift policy. The empty
+// policy is valid and means DriftPolicyEnforce.
+func (p DriftPolicy) Validate() error {
+	switch p {
+	case "", DriftPolicyEnforce, DriftPolicyOverride, DriftPolicyIgnore:
+		return nil
+	default:
+		return fmt.Errorf("invalid drift policy %q, expected one of %q, %q or %q", p, DriftPolicyEnforce, DriftPolicyOverride, DriftPolicyIgnore)
+	}
+}
@@ -0,0 +1,138 @@
+package outlet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Driver defines how an outlet is switched.
+type Driver string
+
+const (
+	// DriverRF switches outlets by sending out rf codes. This is the
+	// default if no driver is set.
+	DriverRF Driver = "rf"
+
+	// DriverTasmota switches Wi-Fi plugs running Tasmota firmware via their
+	// local HTTP API.
+	DriverTasmota Driver = "tasmota"
+
+	// DriverShelly switches Shelly (Gen1) Wi-Fi plugs via their local HTTP
+	// API.
+	DriverShelly Driver = "shelly"
+)
+
+// Validate returns an error if d is not a known driver. The empty driver is
+// valid and means DriverRF.
+func (d Driver) Validate() error {
+	switch d {
+	case "", DriverRF, DriverTasmota, DriverShelly:
+		return nil
+	default:
+		return fmt.Errorf("invalid driver %q, expected one of %q, %q or %q", d, DriverRF, DriverTasmota, DriverShelly)
+	}
+}
+
+// ErrStateUnsupported is returned by a StateReader if the state of an outlet
+// cannot be read back, e.g. because it is switched via rf codes.
+var ErrStateUnsupported = errors.New("reading the outlet state is not supported")
+
+// StateReader defines the interface for switchers that can read back the
+// actual state of an outlet from the device.
+type StateReader interface {
+	// ReadState reads the current state of outlet from the device.
+	ReadState(outlet *Outlet) (State, error)
+}
+
+// DispatchSwitch routes outlets to the Switcher of their driver. This allows
+// outlets with different drivers to live in the same groups and schedules.
+type DispatchSwitch struct {
+	// Switchers maps drivers to their switchers. Outlets without driver are
+	// routed to the switcher of DriverRF.
+	Switchers map[Driver]Switcher
+}
+
+// NewDispatchSwitch creates a new *DispatchSwitch which uses rf for outlets
+// switched via rf codes and the HTTP switchers for Wi-Fi plugs.
+func NewDispatchSwitch(rf Switcher) *DispatchSwitch {
+	return &DispatchSwitch{
+		Switchers: map[Driver]Switcher{
+			DriverRF:      rf,
+			DriverTasmota: NewTasmotaSwitch(),
+			DriverShelly:  NewShellySwitch(),
+		},
+	}
+}
+
+// switcherFor returns the switcher for the driver of o.
+func (s *DispatchSwitch) switcherFor(o *Outlet) (Switcher, error) {
+	driver := o.Driver
+	if driver == "" {
+		driver = DriverRF
+	}
+
+	switcher, ok := s.Switchers[driver]
+	if !ok {
+		return nil, fmt.Errorf("no switcher for driver %q", driver)
+	}
+
+	return switcher, nil
+}
+
+// Switch implements Switcher.
+func (s *DispatchSwitch) Switch(o *Outlet, state State) error {
+	switcher, err := s.switcherFor(o)
+	if err != nil {
+		return err
+	}
+
+	return switcher.Switch(o, state)
+}
+
+// SwitchAction implements ActionSwitcher. Returns an error if the switcher of
+// the outlet's driver does not support actions.
+func (s *DispatchSwitch) SwitchAction(o *Outlet, action Action) error {
+	switcher, err := s.switcherFor(o)
+	if err != nil {
+		return err
+	}
+
+	actionSwitcher, ok := switcher.(ActionSwitcher)
+	if !ok {
+		return fmt.Errorf("driver %q does not support actions", o.Driver)
+	}
+
+	return actionSwitcher.SwitchAction(o, action)
+}
+
+// SwitchCover implements CoverSwitcher. Returns an error if the switcher of
+// the outlet's driver does not support covers.
+func (s *DispatchSwitch) SwitchCover(o *Outlet, motion Motion) error {
+	switcher, err := s.switcherFor(o)
+	if err != nil {
+		return err
+	}
+
+	coverSwitcher, ok := switcher.(CoverSwitcher)
+	if !ok {
+		return fmt.Errorf("driver %q does not support covers", o.Driver)
+	}
+
+	return coverSwitcher.SwitchCover(o, motion)
+}
+
+// ReadState implements StateReader. Returns ErrStateUnsupported if the
+// switcher of the outlet's driver cannot read back the state.
+func (s *DispatchSwitch) ReadState(o *Outlet) (State, error) {
+	switcher, err := s.switcherFor(o)
+	if err != nil {
+		return 0, err
+	}
+
+	reader, ok := switcher.(StateReader)
+	if !ok {
+		return 0, ErrStateUnsupported
+	}
+
+	return reader.ReadState(o)
+}
@@ -0,0 +1,229 @@
+package outlet
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePlug is a fake Wi-Fi plug which records requests and serves a
+// configurable power state.
+type fakePlug struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	on       bool
+	status   int
+}
+
+func (p *fakePlug) serve(handle func(p *fakePlug, w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.requests = append(p.requests, r)
+
+		if p.status != 0 {
+			w.WriteHeader(p.status)
+			return
+		}
+
+		handle(p, w, r)
+	}))
+}
+
+func tasmota(p *fakePlug, w http.ResponseWriter, r *http.Request) {
+	cmnd := strings.Fields(r.URL.Query().Get("cmnd"))
+
+	if len(cmnd) > 1 {
+		p.on = cmnd[1] == "On"
+	}
+
+	power := "OFF"
+	if p.on {
+		power = "ON"
+	}
+
+	fmt.Fprintf(w, `{"%s":"%s"}`, strings.ToUpper(cmnd[0]), power)
+}
+
+func shelly(p *fakePlug, w http.ResponseWriter, r *http.Request) {
+	if turn := r.URL.Query().Get("turn"); turn != "" {
+		p.on = turn == "on"
+	}
+
+	fmt.Fprintf(w, `{"ison":%t,"has_timer":false}`, p.on)
+}
+
+func TestTasmotaSwitch(t *testing.T) {
+	plug := &fakePlug{}
+	srv := plug.serve(tasmota)
+	defer srv.Close()
+
+	s := NewTasmotaSwitch()
+	o := &Outlet{ID: "foo", Driver: DriverTasmota, Address: srv.URL}
+
+	require.NoError(t, s.Switch(o, StateOn))
+	assert.Equal(t, StateOn, o.GetState())
+	assert.True(t, plug.on)
+
+	state, err := s.ReadState(o)
+	require.NoError(t, err)
+	assert.Equal(t, StateOn, state)
+
+	plug.on = false
+
+	state, err = s.ReadState(o)
+	require.NoError(t, err)
+	assert.Equal(t, StateOff, state)
+
+	require.Len(t, plug.requests, 3)
+	assert.Equal(t, "/cm", plug.requests[0].URL.Path)
+	assert.Equal(t, "Power On", plug.requests[0].URL.Query().Get("cmnd"))
+	assert.Equal(t, "Power", plug.requests[1].URL.Query().Get("cmnd"))
+}
+
+func TestTasmotaSwitch_ChannelAndCredentials(t *testing.T) {
+	plug := &fakePlug{}
+	srv := plug.serve(tasmota)
+	defer srv.Close()
+
+	s := NewTasmotaSwitch()
+	o := &Outlet{
+		ID:      "foo",
+		Address: strings.Replace(srv.URL, "http://", "http://admin:secret@", 1),
+		Channel: 2,
+	}
+
+	require.NoError(t, s.Switch(o, StateOn))
+
+	query := plug.requests[0].URL.Query()
+	assert.Equal(t, "Power2 On", query.Get("cmnd"))
+	assert.Equal(t, "admin", query.Get("user"))
+	assert.Equal(t, "secret", query.Get("password"))
+}
+
+func TestTasmotaSwitch_Errors(t *testing.T) {
+	plug := &fakePlug{status: http.StatusInternalServerError}
+	srv := plug.serve(tasmota)
+	defer srv.Close()
+
+	s := NewTasmotaSwitch()
+	o := &Outlet{ID: "foo", Address: srv.URL}
+
+	err := s.Switch(o, StateOn)
+	assert.EqualError(t, err, `outlet "foo": unexpected status 500 Internal Server Error`)
+	assert.Equal(t, StateOff, o.GetState())
+
+	_, err = s.ReadState(&Outlet{ID: "bar"})
+	assert.EqualError(t, err, `outlet "bar": address must not be empty`)
+}
+
+func TestTasmotaSwitch_StateMismatch(t *testing.T) {
+	plug := &fakePlug{}
+	srv := plug.serve(func(p *fakePlug, w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"POWER":"OFF"}`)
+	})
+	defer srv.Close()
+
+	s := NewTasmotaSwitch()
+	o := &Outlet{ID: "foo", Address: srv.URL}
+
+	err := s.Switch(o, StateOn)
+	assert.EqualError(t, err, `failed to switch outlet "foo": plug reported state off`)
+	assert.Equal(t, StateOff, o.GetState())
+}
+
+func TestShellySwitch(t *testing.T) {
+	plug := &fakePlug{}
+	srv := plug.serve(shelly)
+	defer srv.Close()
+
+	s := NewShellySwitch()
+	o := &Outlet{
+		ID:      "foo",
+		Driver:  DriverShelly,
+		Address: strings.Replace(srv.URL, "http://", "http://admin:secret@", 1),
+		Channel: 1,
+	}
+
+	require.NoError(t, s.Switch(o, StateOn))
+	assert.Equal(t, StateOn, o.GetState())
+
+	plug.on = false
+
+	state, err := s.ReadState(o)
+	require.NoError(t, err)
+	assert.Equal(t, StateOff, state)
+
+	require.Len(t, plug.requests, 2)
+	assert.Equal(t, "/relay/1", plug.requests[0].URL.Path)
+	assert.Equal(t, "on", plug.requests[0].URL.Query().Get("turn"))
+	assert.Empty(t, plug.requests[1].URL.RawQuery)
+
+	user, password, ok := plug.requests[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "admin", user)
+	assert.Equal(t, "secret", password)
+}
+
+func TestShellySwitch_UnexpectedResponse(t *testing.T) {
+	plug := &fakePlug{}
+	srv := plug.serve(func(_ *fakePlug, w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{}`)
+	})
+	defer srv.Close()
+
+	_, err := NewShellySwitch().ReadState(&Outlet{ID: "foo", Address: srv.URL})
+	assert.EqualError(t, err, `outlet "foo": unexpected response from shelly plug`)
+}
+
+func TestDispatchSwitch(t *testing.T) {
+	plug := &fakePlug{}
+	srv := plug.serve(shelly)
+	defer srv.Close()
+
+	tx := &fakeTransmitter{}
+	s := NewDispatchSwitch(NewSwitch(tx))
+
+	rf := &Outlet{ID: "rf", CodeOn: 1, Protocol: 1}
+	wifi := &Outlet{ID: "wifi", Driver: DriverShelly, Address: srv.URL}
+
+	require.NoError(t, s.Switch(rf, StateOn))
+	require.NoError(t, s.Switch(wifi, StateOn))
+
+	assert.Equal(t, []uint64{1}, tx.codes)
+	assert.Len(t, plug.requests, 1)
+
+	_, err := s.ReadState(rf)
+	assert.Equal(t, ErrStateUnsupported, err)
+
+	state, err := s.ReadState(wifi)
+	require.NoError(t, err)
+	assert.Equal(t, StateOn, state)
+
+	err = s.Switch(&Outlet{ID: "foo", Driver: "zigbee"}, StateOn)
+	assert.EqualError(t, err, `no switcher for driver "zigbee"`)
+
+	require.NoError(t, s.SwitchAction(rf, Action{Name: "stop", Code: 2, Protocol: 1}))
+	assert.Equal(t, []uint64{1, 2}, tx.codes)
+
+	err = s.SwitchAction(wifi, Action{Name: "stop"})
+	assert.EqualError(t, err, `driver "shelly" does not support actions`)
+
+	err = s.SwitchCover(wifi, MotionUp)
+	assert.EqualError(t, err, `driver "shelly" does not support covers`)
+}
+
+func TestDriver_Validate(t *testing.T) {
+	assert.NoError(t, Driver("").Validate())
+	assert.NoError(t, DriverRF.Validate())
+	assert.NoError(t, DriverTasmota.Validate())
+	assert.NoError(t, DriverShelly.Validate())
+	assert.EqualError(t, Driver("zigbee").Validate(), `invalid driver "zigbee", expected one of "rf", "tasmota" or "shelly"`)
+}
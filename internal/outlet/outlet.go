@@ -4,8 +4,10 @@ package outlet
 
 import (
 	"sync"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/internal/schedule"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
 )
 
 // State describes the state of an outlet (on or off).
@@ -18,6 +20,15 @@ const (
 	StateOn
 )
 
+// String implements fmt.Stringer.
+func (s State) String() string {
+	if s == StateOn {
+		return "on"
+	}
+
+	return "off"
+}
+
 // Group is a group of outlets that can be switched as one.
 type Group struct {
 	ID          string    `json:"id"`
@@ -25,7 +36,8 @@ type Group struct {
 	Outlets     []*Outlet `json:"outlets"`
 }
 
-// Outlet is an rf controlled outlet that can be switched on or off.
+// Outlet is an rf controlled outlet or Wi-Fi plug that can be switched on or
+// off.
 type Outlet struct {
 	sync.Mutex
 	ID          string             `json:"id"`
@@ -36,6 +48,56 @@ type Outlet struct {
 	PulseLength uint               `json:"-"`
 	Schedule    *schedule.Schedule `json:"schedule"`
 	State       State              `json:"state"`
+	// AlsoOnCodes and AlsoOffCodes are additional codes which switch the
+	// outlet on or off, e.g. the codes of "all on" and "all off" buttons of
+	// a remote control. They are only used to detect state drift and are
+	// never transmitted.
+	AlsoOnCodes  []uint64 `json:"-"`
+	AlsoOffCodes []uint64 `json:"-"`
+	// RecordingOn and RecordingOff are raw recordings which are transmitted
+	// verbatim instead of CodeOn and CodeOff if set. This allows to control
+	// outlets whose remote controls use encodings not supported by any of the
+	// known protocols.
+	RecordingOn  *gpio.Recording `json:"-"`
+	RecordingOff *gpio.Recording `json:"-"`
+	// Radio is the name of the radio that is used to switch the outlet. If
+	// empty, the default transmitter is used.
+	Radio string `json:"-"`
+	// TransmissionCount overrides the number of times a code is transmitted
+	// in a row for this outlet. If zero, the transmitter's default is used.
+	TransmissionCount int `json:"-"`
+	// RepeatGap is the pause between two repetitions of a code. If zero,
+	// repetitions are sent back-to-back.
+	RepeatGap time.Duration `json:"-"`
+	// Driver defines how the outlet is switched. If empty, DriverRF is used.
+	Driver Driver `json:"-"`
+	// Address is the address of the HTTP API of Wi-Fi plugs. It is ignored
+	// by DriverRF.
+	Address string `json:"-"`
+	// Channel selects the relay of Wi-Fi plugs with multiple relays.
+	Channel int `json:"-"`
+	// Actions are named codes in addition to CodeOn and CodeOff, e.g. for
+	// blinds or dimmable bulbs.
+	Actions []Action `json:"actions,omitempty"`
+	// Stateless outlets do not track an on/off state and can only be
+	// controlled via Actions.
+	Stateless bool `json:"stateless,omitempty"`
+	// Cover is set if the outlet is a motorised blind or shutter. Covers
+	// are always stateless.
+	Cover *Cover `json:"cover,omitempty"`
+	// StartupAction defines which state is transmitted when rfoutlet
+	// starts. If empty, StartupActionNone is used.
+	StartupAction StartupAction `json:"-"`
+	// RefreshInterval is the interval in which the current state of the
+	// outlet is re-transmitted. Zero disables refreshing.
+	RefreshInterval time.Duration `json:"-"`
+	// DriftPolicy defines how state drift is handled while the outlet's
+	// schedule is enabled. If empty, DriftPolicyEnforce is used.
+	DriftPolicy DriftPolicy `json:"-"`
+
+	// override holds the scheduled state at the time a manual override
+	// started. It is nil if there is no override.
+	override *State
 }
 
 // SetState sets the state of the outlet
@@ -52,6 +114,62 @@ func (o *Outlet) GetState() State {
 	return o.State
 }
 
+// ScheduledState returns the state the outlet should be in at t according to
+// its schedule.
+func (o *Outlet) ScheduledState(t time.Time) State {
+	if o.Schedule.Contains(t) {
+		return StateOn
+	}
+
+	return StateOff
+}
+
+// ScheduledPosition returns the position the cover of the outlet should be
+// in at t according to its schedule. Covers are moved to the position of the
+// interval containing t, or are closed if no interval contains t.
+func (o *Outlet) ScheduledPosition(t time.Time) int {
+	interval, ok := o.Schedule.IntervalAt(t)
+	if !ok {
+		return PositionClosed
+	}
+
+	if interval.Position != nil {
+		return *interval.Position
+	}
+
+	return PositionOpen
+}
+
+// SetOverride marks the outlet's state as a manual override of its schedule.
+// scheduledState is the state the schedule demands at the time the override
+// started. The override lasts until the schedule demands a different state.
+func (o *Outlet) SetOverride(scheduledState State) {
+	o.Lock()
+	o.override = &scheduledState
+	o.Unlock()
+}
+
+// Override returns the scheduled state at the time the current manual
+// override started. The second return value is false if there is no
+// override.
+func (o *Outlet) Override() (State, bool) {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.override == nil {
+		return StateOff, false
+	}
+
+	return *o.override, true
+}
+
+// ClearOverride ends the manual override of the outlet's schedule.
+func (o *Outlet) ClearOverride() {
+	o.Lock()
+	o.override = nil
+	o.Unlock()
+}
+
 // getCodeForState returns the code to transmit to bring the outlet into state.
 func (o *Outlet) getCodeForState(state State) uint64 {
 	switch state {
@@ -61,3 +179,14 @@ func (o *Outlet) getCodeForState(state State) uint64 {
 		return o.CodeOff
 	}
 }
+
+// getRecordingForState returns the raw recording to transmit to bring the
+// outlet into state. Returns nil if the outlet does not use raw recordings.
+func (o *Outlet) getRecordingForState(state State) *gpio.Recording {
+	switch state {
+	case StateOn:
+		return o.RecordingOn
+	default:
+		return o.RecordingOff
+	}
+}
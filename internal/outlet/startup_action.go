@@ -0,0 +1,39 @@
+package outlet
+
+import "fmt"
+
+// StartupAction defines which state is transmitted to an outlet when rfoutlet
+// starts, e.g. to bring outlets back into their known state after a power
+// outage.
+type StartupAction string
+
+const (
+	// StartupActionNone does not transmit anything on startup. This is the
+	// default if no startup action is set.
+	StartupActionNone StartupAction = "none"
+
+	// StartupActionRestore transmits the state restored from the state file.
+	StartupActionRestore StartupAction = "restore"
+
+	// StartupActionOn switches the outlet on.
+	StartupActionOn StartupAction = "on"
+
+	// StartupActionOff switches the outlet off.
+	StartupActionOff StartupAction = "off"
+
+	// StartupActionSchedule transmits the state demanded by the outlet's
+	// schedule. Outlets without enabled schedule are not switched.
+	StartupActionSchedule StartupAction = "schedule"
+)
+
+// Validate returns an error if a is not a known startup action. The empty
+// action is valid and means StartupActionNone.
+func (a StartupAction) Validate() error {
+	switch a {
+	case "", StartupActionNone, StartupActionRestore, StartupActionOn, StartupActionOff, StartupActionSchedule:
+		return nil
+	default:
+		return fmt.Errorf("invalid startup action %q, expected one of %q, %q, %q, %q or %q",
+			a, StartupActionRestore, StartupActionOn, StartupActionOff, StartupActionSchedule, StartupActionNone)
+	}
+}
@@ -3,6 +3,7 @@ package outlet
 import (
 	"encoding/json"
 	"io/ioutil"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/internal/schedule"
 )
@@ -11,6 +12,8 @@ import (
 type outletState struct {
 	State    State              `json:"state,omitempty"`
 	Schedule *schedule.Schedule `json:"schedule,omitempty"`
+	// Position is the estimated position of covers.
+	Position int `json:"position,omitempty"`
 }
 
 // StateFile holds the state and schedule of all configured outlets. This is
@@ -68,6 +71,9 @@ func applyOutletStates(outlets []*Outlet, stateMap map[string]outletState) {
 		}
 
 		o.SetState(outletState.State)
+		if o.Cover != nil {
+			o.Cover.SetPosition(outletState.Position)
+		}
 		o.Schedule = outletState.Schedule
 		if o.Schedule == nil {
 			o.Schedule = schedule.New()
@@ -77,12 +83,19 @@ func applyOutletStates(outlets []*Outlet, stateMap map[string]outletState) {
 
 func collectOutletStates(outlets []*Outlet) map[string]outletState {
 	stateMap := make(map[string]outletState)
+	now := time.Now()
 
 	for _, o := range outlets {
-		stateMap[o.ID] = outletState{
+		state := outletState{
 			State:    o.GetState(),
 			Schedule: o.Schedule,
 		}
+
+		if o.Cover != nil {
+			state.Position = o.Cover.Position(now)
+		}
+
+		stateMap[o.ID] = state
 	}
 
 	return stateMap
@@ -72,3 +72,30 @@ func TestStateFile_WriteOut(t *testing.T) {
 
 	assert.Equal(t, expected, string(buf))
 }
+
+func TestStateFile_Cover(t *testing.T) {
+	f, err := ioutil.TempFile("", "rfoutlet-state-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	sf := NewStateFile(f.Name())
+
+	cover := &Cover{}
+	cover.SetPosition(40)
+
+	require.NoError(t, sf.WriteOut([]*Outlet{{ID: "foo", Cover: cover}}))
+
+	buf, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `{"foo":{"position":40}}`, string(buf))
+
+	o := &Outlet{ID: "foo", Cover: &Cover{}}
+
+	require.NoError(t, sf.ReadBack([]*Outlet{o}))
+	assert.Equal(t, 40, o.Cover.Position(time.Now()))
+}
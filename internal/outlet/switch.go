@@ -1,6 +1,7 @@
 package outlet
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
@@ -17,23 +18,63 @@ type Switcher interface {
 
 // Switch switches outlets by sending out codes using an gpio transmitter.
 type Switch struct {
+	// Transmitter is used for outlets that do not specify a radio.
 	Transmitter gpio.CodeTransmitter
+	// Transmitters maps radio names to the transmitters of these radios.
+	Transmitters map[string]gpio.CodeTransmitter
+	// Protocols are the protocols that are available to outlets. The
+	// protocol of an outlet is the 1-based index into Protocols.
+	Protocols []gpio.Protocol
 }
 
-// NewSwitch creates a new *Switch.
+// NewSwitch creates a new *Switch which uses gpio.DefaultProtocols.
 func NewSwitch(transmitter gpio.CodeTransmitter) *Switch {
 	return &Switch{
-		Transmitter: transmitter,
+		Transmitter:  transmitter,
+		Transmitters: make(map[string]gpio.CodeTransmitter),
+		Protocols:    gpio.DefaultProtocols,
 	}
 }
 
+// transmitterFor returns the transmitter of the outlet's radio.
+func (s *Switch) transmitterFor(o *Outlet) (gpio.CodeTransmitter, error) {
+	if o.Radio == "" {
+		return s.Transmitter, nil
+	}
+
+	transmitter, ok := s.Transmitters[o.Radio]
+	if !ok {
+		return nil, fmt.Errorf("radio %q does not exist", o.Radio)
+	}
+
+	return transmitter, nil
+}
+
 // Switch switches an outlet to the provided state.
 func (s *Switch) Switch(o *Outlet, state State) error {
-	if o.Protocol < 1 || o.Protocol > len(gpio.DefaultProtocols) {
-		return fmt.Errorf("protocol %d does not exist", o.Protocol)
+	transmitter, err := s.transmitterFor(o)
+	if err != nil {
+		return err
 	}
 
-	proto := gpio.DefaultProtocols[o.Protocol-1]
+	if rec := o.getRecordingForState(state); rec != nil {
+		log.WithFields(logrus.Fields{
+			"outletID":     o.ID,
+			"outletState":  o.GetState(),
+			"desiredState": state,
+			"pulses":       len(rec.Pulses),
+			"radio":        o.Radio,
+		}).Debug("transmitting raw recording")
+
+		errs := transmitter.TransmitRecording(context.Background(), rec, transmitOptions(o))
+		if err := checkTransmission(o, errs); err != nil {
+			return err
+		}
+
+		o.SetState(state)
+
+		return nil
+	}
 
 	code := o.getCodeForState(state)
 
@@ -43,14 +84,144 @@ func (s *Switch) Switch(o *Outlet, state State) error {
 		"desiredState": state,
 		"protocol":     o.Protocol,
 		"pulseLength":  o.PulseLength,
+		"radio":        o.Radio,
 	}).Debugf("transmitting code %d", code)
 
-	s.Transmitter.Transmit(code, proto, o.PulseLength)
+	if err := s.transmit(transmitter, o, code, o.Protocol, o.PulseLength); err != nil {
+		return err
+	}
+
 	o.SetState(state)
 
 	return nil
 }
 
+// SwitchAction implements ActionSwitcher.
+func (s *Switch) SwitchAction(o *Outlet, action Action) error {
+	transmitter, err := s.transmitterFor(o)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(logrus.Fields{
+		"outletID":    o.ID,
+		"action":      action.Name,
+		"protocol":    action.Protocol,
+		"pulseLength": action.PulseLength,
+		"radio":       o.Radio,
+	}).Debugf("transmitting code %d", action.Code)
+
+	if err := s.transmit(transmitter, o, action.Code, action.Protocol, action.PulseLength); err != nil {
+		return err
+	}
+
+	if action.State != nil {
+		o.SetState(*action.State)
+	}
+
+	return nil
+}
+
+// SwitchCover implements CoverSwitcher.
+func (s *Switch) SwitchCover(o *Outlet, motion Motion) error {
+	if o.Cover == nil {
+		return fmt.Errorf("outlet %q is not a cover", o.ID)
+	}
+
+	transmitter, err := s.transmitterFor(o)
+	if err != nil {
+		return err
+	}
+
+	code := o.Cover.codeForMotion(motion)
+
+	log.WithFields(logrus.Fields{
+		"outletID":    o.ID,
+		"motion":      motion,
+		"protocol":    o.Protocol,
+		"pulseLength": o.PulseLength,
+		"radio":       o.Radio,
+	}).Debugf("transmitting code %d", code)
+
+	return s.transmit(transmitter, o, code, o.Protocol, o.PulseLength)
+}
+
+// transmit transmits code to o using transmitter.
+func (s *Switch) transmit(transmitter gpio.CodeTransmitter, o *Outlet, code uint64, protocol int, pulseLength uint) error {
+	if protocol < 1 || protocol > len(s.Protocols) {
+		return fmt.Errorf("protocol %d does not exist", protocol)
+	}
+
+	errs := transmitter.Transmit(context.Background(), code, s.Protocols[protocol-1], pulseLength, transmitOptions(o))
+
+	return checkTransmission(o, errs)
+}
+
+// transmissionLister is implemented by transmitters that report their
+// pending and active transmissions, e.g. *gpio.Transmitter.
+type transmissionLister interface {
+	Transmissions() []gpio.TransmissionInfo
+}
+
+// Busy returns true if the transmitter of the outlet's radio is currently
+// sending out codes or has transmissions pending. Transmitters that do not
+// report their transmissions are never considered busy.
+func (s *Switch) Busy(o *Outlet) bool {
+	transmitter, err := s.transmitterFor(o)
+	if err != nil {
+		return false
+	}
+
+	lister, ok := transmitter.(transmissionLister)
+
+	return ok && len(lister.Transmissions()) > 0
+}
+
+// transmitOptions returns the options for transmissions to o. Transmissions
+// are keyed by outlet ID, so that a pending transmission to an outlet is
+// superseded if the outlet is switched again before it was sent out.
+func transmitOptions(o *Outlet) gpio.TransmitOptions {
+	return gpio.TransmitOptions{
+		Key:               o.ID,
+		TransmissionCount: o.TransmissionCount,
+		RepeatGap:         o.RepeatGap,
+	}
+}
+
+// checkTransmission returns an error if the transmission to o was rejected
+// right away, e.g. because the transmit queue is full. Errors that occur
+// while the transmission is in progress are only logged as the caller must
+// not be blocked until it is done.
+func checkTransmission(o *Outlet, errs <-chan error) error {
+	select {
+	case err := <-errs:
+		if err != nil {
+			return fmt.Errorf("failed to transmit code for outlet %q: %v", o.ID, err)
+		}
+
+		return nil
+	default:
+	}
+
+	go func() {
+		err := <-errs
+		if err == nil {
+			return
+		}
+
+		entry := log.WithField("outletID", o.ID).WithError(err)
+
+		if err == gpio.ErrTransmissionSuperseded {
+			entry.Debug("transmission superseded")
+			return
+		}
+
+		entry.Error("transmission failed")
+	}()
+
+	return nil
+}
+
 // FakeSwitch can be used in tests,
 type FakeSwitch struct {
 	// Err is the error that should be returned by Switch. If non-nil, the
@@ -70,3 +241,27 @@ func (s *FakeSwitch) Switch(outlet *Outlet, state State) error {
 
 	return nil
 }
+
+// SwitchAction implements ActionSwitcher.
+//
+// It will only set the outlet state if the action defines one or return the
+// configured error.
+func (s *FakeSwitch) SwitchAction(outlet *Outlet, action Action) error {
+	if s.Err != nil {
+		return s.Err
+	}
+
+	if action.State != nil {
+		outlet.SetState(*action.State)
+	}
+
+	return nil
+}
+
+// SwitchCover implements CoverSwitcher.
+//
+// It will only return the configured error. Tracking the position of the
+// cover is up to the caller.
+func (s *FakeSwitch) SwitchCover(outlet *Outlet, motion Motion) error {
+	return s.Err
+}
@@ -1,8 +1,10 @@
 package outlet
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,180 @@ func TestSwitch(t *testing.T) {
 	assert.Equal(t, StateOn, o.GetState())
 }
 
+type fakeTransmitter struct {
+	codes      []uint64
+	protocols  []gpio.Protocol
+	recordings []*gpio.Recording
+	opts       []gpio.TransmitOptions
+	err        error
+}
+
+func (t *fakeTransmitter) Transmit(_ context.Context, code uint64, protocol gpio.Protocol, _ uint, opts gpio.TransmitOptions) <-chan error {
+	t.codes = append(t.codes, code)
+	t.opts = append(t.opts, opts)
+	t.protocols = append(t.protocols, protocol)
+	return t.result()
+}
+
+func (t *fakeTransmitter) TransmitRecording(_ context.Context, rec *gpio.Recording, _ gpio.TransmitOptions) <-chan error {
+	t.recordings = append(t.recordings, rec)
+	return t.result()
+}
+
+func (t *fakeTransmitter) Close() error { return nil }
+
+func (t *fakeTransmitter) result() <-chan error {
+	ch := make(chan error, 1)
+	if t.err != nil {
+		ch <- t.err
+	}
+	close(ch)
+	return ch
+}
+
+func TestSwitch_Recordings(t *testing.T) {
+	tx := &fakeTransmitter{}
+	s := NewSwitch(tx)
+
+	recOn := &gpio.Recording{Pulses: []time.Duration{time.Millisecond}}
+	recOff := &gpio.Recording{Pulses: []time.Duration{2 * time.Millisecond}}
+
+	o := &Outlet{CodeOn: 1, CodeOff: 2, RecordingOn: recOn, RecordingOff: recOff}
+
+	assert.NoError(t, s.Switch(o, StateOn))
+	assert.Equal(t, StateOn, o.GetState())
+	assert.NoError(t, s.Switch(o, StateOff))
+	assert.Equal(t, StateOff, o.GetState())
+
+	assert.Empty(t, tx.codes)
+	assert.Equal(t, []*gpio.Recording{recOn, recOff}, tx.recordings)
+}
+
+func TestSwitch_CustomProtocols(t *testing.T) {
+	custom := gpio.Protocol{
+		Sync: gpio.HighLow{High: 1, Low: 20},
+		Zero: gpio.HighLow{High: 1, Low: 4},
+		One:  gpio.HighLow{High: 4, Low: 1},
+	}
+
+	tx := &fakeTransmitter{}
+	s := NewSwitch(tx)
+
+	o := &Outlet{CodeOn: 1, CodeOff: 2, Protocol: len(gpio.DefaultProtocols) + 1}
+
+	assert.Error(t, s.Switch(o, StateOn))
+
+	s.Protocols = append(gpio.DefaultProtocols[:len(gpio.DefaultProtocols):len(gpio.DefaultProtocols)], custom)
+
+	assert.NoError(t, s.Switch(o, StateOn))
+	assert.Equal(t, []gpio.Protocol{custom}, tx.protocols)
+}
+
+func TestSwitch_Radios(t *testing.T) {
+	tx := &fakeTransmitter{}
+	tx315 := &fakeTransmitter{}
+
+	s := NewSwitch(tx)
+	s.Transmitters["315mhz"] = tx315
+
+	o1 := &Outlet{CodeOn: 1, CodeOff: 2, Protocol: 1}
+	o2 := &Outlet{CodeOn: 3, CodeOff: 4, Protocol: 1, Radio: "315mhz"}
+	o3 := &Outlet{CodeOn: 5, CodeOff: 6, Protocol: 1, Radio: "868mhz"}
+
+	assert.NoError(t, s.Switch(o1, StateOn))
+	assert.NoError(t, s.Switch(o2, StateOn))
+	assert.EqualError(t, s.Switch(o3, StateOn), `radio "868mhz" does not exist`)
+	assert.Equal(t, StateOff, o3.GetState())
+
+	assert.Equal(t, []uint64{1}, tx.codes)
+	assert.Equal(t, []uint64{3}, tx315.codes)
+}
+
+func TestSwitch_TransmitOptions(t *testing.T) {
+	tx := &fakeTransmitter{}
+	s := NewSwitch(tx)
+
+	o := &Outlet{ID: "foo", CodeOn: 1, CodeOff: 2, Protocol: 1, TransmissionCount: 20, RepeatGap: 50 * time.Millisecond}
+
+	assert.NoError(t, s.Switch(o, StateOn))
+
+	expected := []gpio.TransmitOptions{
+		{Key: "foo", TransmissionCount: 20, RepeatGap: 50 * time.Millisecond},
+	}
+
+	assert.Equal(t, expected, tx.opts)
+}
+
+func TestSwitch_TransmitError(t *testing.T) {
+	tx := &fakeTransmitter{err: gpio.ErrQueueFull}
+	s := NewSwitch(tx)
+
+	o := &Outlet{ID: "foo", CodeOn: 1, CodeOff: 2, Protocol: 1}
+
+	assert.EqualError(t, s.Switch(o, StateOn), `failed to transmit code for outlet "foo": transmit queue is full`)
+	assert.Equal(t, StateOff, o.GetState())
+}
+
+func TestSwitch_SwitchAction(t *testing.T) {
+	tx := &fakeTransmitter{}
+	s := NewSwitch(tx)
+
+	on := StateOn
+
+	o := &Outlet{ID: "foo", Protocol: 1}
+
+	assert.NoError(t, s.SwitchAction(o, Action{Name: "stop", Code: 1, Protocol: 2, PulseLength: 300}))
+	assert.Equal(t, StateOff, o.GetState())
+
+	assert.NoError(t, s.SwitchAction(o, Action{Name: "brighter", Code: 2, Protocol: 1, State: &on}))
+	assert.Equal(t, StateOn, o.GetState())
+
+	assert.EqualError(t, s.SwitchAction(o, Action{Name: "dimmer", Code: 3, Protocol: 10}), "protocol 10 does not exist")
+
+	assert.Equal(t, []uint64{1, 2}, tx.codes)
+	assert.Equal(t, []gpio.Protocol{gpio.DefaultProtocols[1], gpio.DefaultProtocols[0]}, tx.protocols)
+	assert.Equal(t, []gpio.TransmitOptions{{Key: "foo"}, {Key: "foo"}}, tx.opts)
+}
+
+func TestSwitch_SwitchCover(t *testing.T) {
+	tx := &fakeTransmitter{}
+	s := NewSwitch(tx)
+
+	o := &Outlet{ID: "foo", Protocol: 1, Cover: &Cover{CodeUp: 1, CodeDown: 2, CodeStop: 3}}
+
+	assert.NoError(t, s.SwitchCover(o, MotionUp))
+	assert.NoError(t, s.SwitchCover(o, MotionStopped))
+	assert.NoError(t, s.SwitchCover(o, MotionDown))
+
+	assert.EqualError(t, s.SwitchCover(&Outlet{ID: "bar"}, MotionUp), `outlet "bar" is not a cover`)
+
+	assert.Equal(t, []uint64{1, 3, 2}, tx.codes)
+}
+
+type fakeBusyTransmitter struct {
+	fakeTransmitter
+	transmissions []gpio.TransmissionInfo
+}
+
+func (t *fakeBusyTransmitter) Transmissions() []gpio.TransmissionInfo {
+	return t.transmissions
+}
+
+func TestSwitch_Busy(t *testing.T) {
+	tx := &fakeBusyTransmitter{}
+	s := NewSwitch(&fakeTransmitter{})
+	s.Transmitters["433mhz"] = tx
+
+	o := &Outlet{Radio: "433mhz"}
+
+	assert.False(t, s.Busy(&Outlet{}))
+	assert.False(t, s.Busy(o))
+
+	tx.transmissions = []gpio.TransmissionInfo{{Key: "foo", Active: true}}
+
+	assert.True(t, s.Busy(o))
+}
+
 func TestFakeSwitch(t *testing.T) {
 	s := &FakeSwitch{}
 	o := &Outlet{State: StateOn}
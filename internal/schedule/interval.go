@@ -10,6 +10,10 @@ type Interval struct {
 	Weekdays []time.Weekday `json:"weekdays"`
 	From     DayTime        `json:"from"`
 	To       DayTime        `json:"to"`
+	// Position is the position in percent that covers are moved to while
+	// the interval is active. If nil, covers are fully opened. It is
+	// ignored for outlets that are not covers.
+	Position *int `json:"position,omitempty"`
 }
 
 // Contains returns true if interval is enabled and t lies within.
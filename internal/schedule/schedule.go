@@ -50,8 +50,15 @@ func (s *Schedule) Enabled() bool {
 
 // Contains returns true if any of the intervals contains t.
 func (s *Schedule) Contains(t time.Time) bool {
+	_, ok := s.IntervalAt(t)
+	return ok
+}
+
+// IntervalAt returns the first interval that contains t. The second return
+// value is false if no interval contains t.
+func (s *Schedule) IntervalAt(t time.Time) (Interval, bool) {
 	if s == nil {
-		return false
+		return Interval{}, false
 	}
 
 	s.RLock()
@@ -60,11 +67,11 @@ func (s *Schedule) Contains(t time.Time) bool {
 
 	for _, i := range intervals {
 		if i.Contains(t) {
-			return true
+			return i, true
 		}
 	}
 
-	return false
+	return Interval{}, false
 }
 
 // AddInterval adds an interval to the schedule of an outlet.
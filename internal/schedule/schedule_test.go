@@ -146,3 +146,39 @@ func TestUpdateInterval(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, true, s.intervals[0].Enabled)
 }
+
+func TestIntervalAt(t *testing.T) {
+	position := 40
+	monday := time.Date(2018, 11, 5, 1, 0, 0, 0, time.UTC)
+
+	s := NewWithIntervals([]Interval{
+		{
+			ID:       "foo",
+			Enabled:  true,
+			Weekdays: []time.Weekday{time.Tuesday},
+			From:     NewDayTime(0, 0),
+			To:       NewDayTime(3, 0),
+		},
+		{
+			ID:       "bar",
+			Enabled:  true,
+			Weekdays: []time.Weekday{time.Monday},
+			From:     NewDayTime(0, 0),
+			To:       NewDayTime(3, 0),
+			Position: &position,
+		},
+	})
+
+	interval, ok := s.IntervalAt(monday)
+	assert.True(t, ok)
+	assert.Equal(t, "bar", interval.ID)
+	assert.Equal(t, &position, interval.Position)
+
+	_, ok = s.IntervalAt(monday.Add(3 * time.Hour))
+	assert.False(t, ok)
+
+	var nilSchedule *Schedule
+
+	_, ok = nilSchedule.IntervalAt(monday)
+	assert.False(t, ok)
+}
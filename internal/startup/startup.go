@@ -0,0 +1,107 @@
+// Package startup provides an applier which transmits the startup action of
+// each outlet once rfoutlet starts, e.g. to bring outlets back into their
+// known state after a power outage.
+package startup
+
+import (
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("component", "startup")
+
+// DefaultStagger is the default pause between the startup transmissions of
+// two outlets.
+const DefaultStagger = 500 * time.Millisecond
+
+// Applier pushes commands that apply the startup action of each outlet into
+// the command queue. The commands are staggered to avoid flooding the
+// transmit queue.
+type Applier struct {
+	Registry     *outlet.Registry
+	CommandQueue chan<- command.Command
+	Clock        clockwork.Clock
+	Stagger      time.Duration
+}
+
+// New creates a new *Applier.
+func New(registry *outlet.Registry, queue chan<- command.Command) *Applier {
+	return &Applier{
+		Registry:     registry,
+		CommandQueue: queue,
+		Clock:        clockwork.NewRealClock(),
+		Stagger:      DefaultStagger,
+	}
+}
+
+// Run applies the startup actions of all outlets. It returns once all
+// commands were pushed into the command queue or when stopCh is closed.
+func (a *Applier) Run(stopCh <-chan struct{}) {
+	first := true
+
+	for _, o := range a.Registry.GetOutlets() {
+		state, ok := startupState(o, a.Clock.Now())
+		if !ok {
+			continue
+		}
+
+		if !first {
+			select {
+			case <-a.Clock.After(a.Stagger):
+			case <-stopCh:
+				return
+			}
+		}
+
+		first = false
+
+		log.WithFields(logrus.Fields{
+			"outletID":      o.ID,
+			"startupAction": o.StartupAction,
+			"desiredState":  state,
+		}).Debug("applying startup action")
+
+		cmd := command.StateCorrectionCommand{
+			Outlet:       o,
+			DesiredState: state,
+			Source:       command.SourceStartup,
+			Force:        true,
+		}
+
+		select {
+		case a.CommandQueue <- cmd:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// startupState returns the state that should be transmitted to o at time now
+// according to its startup action. The second return value is false if
+// nothing should be transmitted.
+func startupState(o *outlet.Outlet, now time.Time) (outlet.State, bool) {
+	if o.Stateless {
+		return outlet.StateOff, false
+	}
+
+	switch o.StartupAction {
+	case outlet.StartupActionRestore:
+		return o.GetState(), true
+	case outlet.StartupActionOn:
+		return outlet.StateOn, true
+	case outlet.StartupActionOff:
+		return outlet.StateOff, true
+	case outlet.StartupActionSchedule:
+		if !o.Schedule.Enabled() {
+			return outlet.StateOff, false
+		}
+
+		return o.ScheduledState(now), true
+	default:
+		return outlet.StateOff, false
+	}
+}
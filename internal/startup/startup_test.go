@@ -0,0 +1,70 @@
+package startup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/martinohmann/rfoutlet/internal/schedule"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplier(t *testing.T) {
+	now := time.Now()
+	plus1 := now.Add(time.Hour)
+
+	sched := schedule.NewWithIntervals([]schedule.Interval{
+		{
+			Enabled:  true,
+			Weekdays: []time.Weekday{now.Weekday()},
+			From:     schedule.NewDayTime(now.Hour(), now.Minute()),
+			To:       schedule.NewDayTime(plus1.Hour(), plus1.Minute()),
+		},
+	})
+
+	o1 := &outlet.Outlet{ID: "none", State: outlet.StateOn}
+	o2 := &outlet.Outlet{ID: "restore", State: outlet.StateOn, StartupAction: outlet.StartupActionRestore}
+	o3 := &outlet.Outlet{ID: "off", State: outlet.StateOn, StartupAction: outlet.StartupActionOff}
+	o4 := &outlet.Outlet{ID: "on", StartupAction: outlet.StartupActionOn}
+	o5 := &outlet.Outlet{ID: "schedule", Schedule: sched, StartupAction: outlet.StartupActionSchedule}
+	o6 := &outlet.Outlet{ID: "no-schedule", Schedule: schedule.New(), StartupAction: outlet.StartupActionSchedule}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2, o3, o4, o5, o6)
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	fakeClock := clockwork.NewFakeClockAt(now)
+
+	a := New(reg, queue)
+	a.Clock = fakeClock
+
+	go func() {
+		a.Run(stopCh)
+		close(queue)
+	}()
+
+	expected := []command.Command{
+		command.StateCorrectionCommand{Outlet: o2, DesiredState: outlet.StateOn, Source: command.SourceStartup, Force: true},
+		command.StateCorrectionCommand{Outlet: o3, DesiredState: outlet.StateOff, Source: command.SourceStartup, Force: true},
+		command.StateCorrectionCommand{Outlet: o4, DesiredState: outlet.StateOn, Source: command.SourceStartup, Force: true},
+		command.StateCorrectionCommand{Outlet: o5, DesiredState: outlet.StateOn, Source: command.SourceStartup, Force: true},
+	}
+
+	for i, cmd := range expected {
+		if i > 0 {
+			// The next command is only sent after the stagger delay.
+			fakeClock.BlockUntil(1)
+			fakeClock.Advance(DefaultStagger)
+		}
+
+		assert.Equal(t, cmd, <-queue)
+	}
+
+	_, ok := <-queue
+	assert.False(t, ok)
+}
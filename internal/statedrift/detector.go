@@ -4,6 +4,8 @@
 package statedrift
 
 import (
+	"time"
+
 	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
@@ -20,6 +22,9 @@ type Detector struct {
 	Registry     *outlet.Registry
 	Receiver     gpio.CodeReceiver
 	CommandQueue chan<- command.Command
+	// EchoLog is optional. If set, received codes that were sent out by
+	// rfoutlet's own transmitters are ignored.
+	EchoLog *gpio.EchoLog
 }
 
 // NewDetector creates a new *Detector.
@@ -44,27 +49,113 @@ func (d *Detector) Run(stopCh <-chan struct{}) {
 				return
 			}
 
-			var found bool
+			if d.isEcho(result) {
+				log.WithField("code", result.Code).Debug("ignoring echo of own transmission")
+				continue
+			}
 
 			for _, o := range d.Registry.GetOutlets() {
-				if result.Code == o.CodeOn && o.GetState() != outlet.StateOn {
-					found = true
-					d.CommandQueue <- command.StateCorrectionCommand{
-						Outlet:       o,
-						DesiredState: outlet.StateOn,
-					}
-				} else if result.Code == o.CodeOff && o.GetState() != outlet.StateOff {
-					found = true
-					d.CommandQueue <- command.StateCorrectionCommand{
-						Outlet:       o,
-						DesiredState: outlet.StateOff,
-					}
+				state, ok := matchState(o, result)
+				if !ok || o.GetState() == state {
+					continue
 				}
 
-				if found {
-					break
+				if o.Schedule.Enabled() {
+					d.handleScheduledDrift(o, state, receivedAt(result))
+					continue
+				}
+
+				d.CommandQueue <- command.StateCorrectionCommand{
+					Outlet:       o,
+					DesiredState: state,
+					Source:       command.SourceStateDrift,
 				}
 			}
 		}
 	}
 }
+
+// handleScheduledDrift handles the drift of an outlet with enabled schedule
+// into state at time at according to the outlet's drift policy.
+func (d *Detector) handleScheduledDrift(o *outlet.Outlet, state outlet.State, at time.Time) {
+	log := log.WithFields(logrus.Fields{
+		"outletID":    o.ID,
+		"driftPolicy": o.DriftPolicy,
+	})
+
+	switch o.DriftPolicy {
+	case outlet.DriftPolicyIgnore:
+		log.Debug("ignoring state drift of scheduled outlet")
+	case outlet.DriftPolicyOverride:
+		log.Info("state drift of scheduled outlet, overriding schedule until next transition")
+
+		o.SetOverride(o.ScheduledState(at))
+
+		d.CommandQueue <- command.StateCorrectionCommand{
+			Outlet:       o,
+			DesiredState: state,
+			Source:       command.SourceStateDrift,
+		}
+	default:
+		log.Info("state drift of scheduled outlet, enforcing scheduled state")
+
+		d.CommandQueue <- command.StateCorrectionCommand{
+			Outlet:       o,
+			DesiredState: o.ScheduledState(at),
+			Source:       command.SourceStateDrift,
+			Force:        true,
+		}
+	}
+}
+
+// matchState returns the state that o is switched into by result. The second
+// return value is false if the code, protocol or bit length of result do not
+// match o. Codes can match multiple outlets, e.g. if they are sent by the
+// "all off" button of a remote control.
+func matchState(o *outlet.Outlet, result gpio.ReceiveResult) (outlet.State, bool) {
+	if o.Stateless || (o.Driver != "" && o.Driver != outlet.DriverRF) {
+		return outlet.StateOff, false
+	}
+
+	if result.Protocol != o.Protocol || result.BitLength != gpio.BitLength {
+		return outlet.StateOff, false
+	}
+
+	switch {
+	case result.Code == o.CodeOn || containsCode(o.AlsoOnCodes, result.Code):
+		return outlet.StateOn, true
+	case result.Code == o.CodeOff || containsCode(o.AlsoOffCodes, result.Code):
+		return outlet.StateOff, true
+	default:
+		return outlet.StateOff, false
+	}
+}
+
+func containsCode(codes []uint64, code uint64) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isEcho returns true if result is an echo of a code sent out by one of
+// rfoutlet's transmitters.
+func (d *Detector) isEcho(result gpio.ReceiveResult) bool {
+	if d.EchoLog == nil {
+		return false
+	}
+
+	return d.EchoLog.IsEcho(result, receivedAt(result))
+}
+
+// receivedAt returns the time result was first received.
+func receivedAt(result gpio.ReceiveResult) time.Time {
+	if result.FirstSeen.IsZero() {
+		return time.Now()
+	}
+
+	return result.FirstSeen
+}
@@ -1,10 +1,13 @@
 package statedrift
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/martinohmann/rfoutlet/internal/command"
 	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/martinohmann/rfoutlet/internal/schedule"
 	"github.com/martinohmann/rfoutlet/pkg/gpio"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,8 +23,8 @@ func (f *fakeReceiver) Receive() <-chan gpio.ReceiveResult {
 func (f *fakeReceiver) Close() error { return nil }
 
 func TestDetector(t *testing.T) {
-	o1 := &outlet.Outlet{ID: "foo", CodeOn: 123, CodeOff: 456, State: outlet.StateOff}
-	o2 := &outlet.Outlet{ID: "bar", CodeOn: 789, CodeOff: 234, State: outlet.StateOn}
+	o1 := &outlet.Outlet{ID: "foo", CodeOn: 123, CodeOff: 456, Protocol: 1, State: outlet.StateOff}
+	o2 := &outlet.Outlet{ID: "bar", CodeOn: 789, CodeOff: 234, Protocol: 1, State: outlet.StateOn}
 
 	reg := outlet.NewRegistry()
 	reg.RegisterOutlets(o1, o2)
@@ -40,16 +43,16 @@ func TestDetector(t *testing.T) {
 
 	go func() {
 		defer close(recv.results)
-		recv.results <- gpio.ReceiveResult{Code: 456}
-		recv.results <- gpio.ReceiveResult{Code: 123}
-		recv.results <- gpio.ReceiveResult{Code: 42}
-		recv.results <- gpio.ReceiveResult{Code: 789}
-		recv.results <- gpio.ReceiveResult{Code: 234}
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 1, BitLength: 24}
+		recv.results <- gpio.ReceiveResult{Code: 123, Protocol: 1, BitLength: 24}
+		recv.results <- gpio.ReceiveResult{Code: 42, Protocol: 1, BitLength: 24}
+		recv.results <- gpio.ReceiveResult{Code: 789, Protocol: 1, BitLength: 24}
+		recv.results <- gpio.ReceiveResult{Code: 234, Protocol: 1, BitLength: 24}
 	}()
 
 	expected := []command.Command{
-		command.StateCorrectionCommand{Outlet: o1, DesiredState: outlet.StateOn},
-		command.StateCorrectionCommand{Outlet: o2, DesiredState: outlet.StateOff},
+		command.StateCorrectionCommand{Outlet: o1, DesiredState: outlet.StateOn, Source: command.SourceStateDrift},
+		command.StateCorrectionCommand{Outlet: o2, DesiredState: outlet.StateOff, Source: command.SourceStateDrift},
 	}
 
 	received := make([]command.Command, 0)
@@ -60,3 +63,187 @@ func TestDetector(t *testing.T) {
 
 	assert.Equal(t, expected, received)
 }
+
+func TestDetector_Matching(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "foo", CodeOn: 123, CodeOff: 456, AlsoOffCodes: []uint64{999}, Protocol: 1, State: outlet.StateOn}
+	o2 := &outlet.Outlet{ID: "bar", CodeOn: 789, CodeOff: 234, AlsoOffCodes: []uint64{999}, Protocol: 2, State: outlet.StateOn}
+	o3 := &outlet.Outlet{ID: "baz", CodeOn: 345, CodeOff: 678, AlsoOffCodes: []uint64{999}, Protocol: 1, State: outlet.StateOn}
+	o4 := &outlet.Outlet{ID: "qux", Driver: outlet.DriverTasmota, Address: "http://localhost", State: outlet.StateOn}
+	o5 := &outlet.Outlet{ID: "quux", AlsoOffCodes: []uint64{999}, Protocol: 1, Stateless: true, State: outlet.StateOn}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2, o3, o4, o5)
+
+	recv := &fakeReceiver{make(chan gpio.ReceiveResult)}
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	d := NewDetector(reg, recv, queue)
+	go func() {
+		d.Run(stopCh)
+		close(queue)
+	}()
+
+	go func() {
+		defer close(recv.results)
+		// Wrong protocol.
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 2, BitLength: 24}
+		// Wrong bit length.
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 1, BitLength: 32}
+		// Wi-Fi plugs do not have codes.
+		recv.results <- gpio.ReceiveResult{Code: 0, Protocol: 0, BitLength: 24}
+		// Master code shared by o1 and o3.
+		recv.results <- gpio.ReceiveResult{Code: 999, Protocol: 1, BitLength: 24}
+	}()
+
+	expected := []command.Command{
+		command.StateCorrectionCommand{Outlet: o1, DesiredState: outlet.StateOff, Source: command.SourceStateDrift},
+		command.StateCorrectionCommand{Outlet: o3, DesiredState: outlet.StateOff, Source: command.SourceStateDrift},
+	}
+
+	received := make([]command.Command, 0)
+
+	for cmd := range queue {
+		received = append(received, cmd)
+	}
+
+	assert.Equal(t, expected, received)
+}
+
+func TestDetector_DriftPolicy(t *testing.T) {
+	now := time.Now()
+	plus1 := now.Add(time.Hour)
+
+	// The schedule demands the outlets to be on.
+	newSchedule := func() *schedule.Schedule {
+		return schedule.NewWithIntervals([]schedule.Interval{
+			{
+				Enabled:  true,
+				Weekdays: []time.Weekday{now.Weekday()},
+				From:     schedule.NewDayTime(now.Hour(), now.Minute()),
+				To:       schedule.NewDayTime(plus1.Hour(), plus1.Minute()),
+			},
+		})
+	}
+
+	o1 := &outlet.Outlet{ID: "foo", CodeOn: 1, CodeOff: 2, Protocol: 1, State: outlet.StateOn, Schedule: newSchedule()}
+	o2 := &outlet.Outlet{ID: "bar", CodeOn: 3, CodeOff: 4, Protocol: 1, State: outlet.StateOn, Schedule: newSchedule(), DriftPolicy: outlet.DriftPolicyOverride}
+	o3 := &outlet.Outlet{ID: "baz", CodeOn: 5, CodeOff: 6, Protocol: 1, State: outlet.StateOn, Schedule: newSchedule(), DriftPolicy: outlet.DriftPolicyIgnore}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2, o3)
+
+	recv := &fakeReceiver{make(chan gpio.ReceiveResult)}
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	d := NewDetector(reg, recv, queue)
+	go func() {
+		d.Run(stopCh)
+		close(queue)
+	}()
+
+	go func() {
+		defer close(recv.results)
+		recv.results <- gpio.ReceiveResult{Code: 2, Protocol: 1, BitLength: 24, FirstSeen: now}
+		recv.results <- gpio.ReceiveResult{Code: 4, Protocol: 1, BitLength: 24, FirstSeen: now}
+		recv.results <- gpio.ReceiveResult{Code: 6, Protocol: 1, BitLength: 24, FirstSeen: now}
+	}()
+
+	expected := []command.Command{
+		command.StateCorrectionCommand{Outlet: o1, DesiredState: outlet.StateOn, Source: command.SourceStateDrift, Force: true},
+		command.StateCorrectionCommand{Outlet: o2, DesiredState: outlet.StateOff, Source: command.SourceStateDrift},
+	}
+
+	received := make([]command.Command, 0)
+
+	for cmd := range queue {
+		received = append(received, cmd)
+	}
+
+	assert.Equal(t, expected, received)
+
+	_, ok := o1.Override()
+	assert.False(t, ok)
+
+	scheduledState, ok := o2.Override()
+	assert.True(t, ok)
+	assert.Equal(t, outlet.StateOn, scheduledState)
+
+	_, ok = o3.Override()
+	assert.False(t, ok)
+}
+
+func TestDetector_Medium(t *testing.T) {
+	o := &outlet.Outlet{ID: "foo", CodeOn: 5510451, CodeOff: 5510460, Protocol: 1, PulseLength: 184, State: outlet.StateOff}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o)
+
+	medium := gpio.NewMedium(gpio.MediumSeed(1))
+
+	receiver := gpio.NewWatcherReceiver(medium.Watcher())
+	defer receiver.Close()
+
+	remote := gpio.NewPinTransmitter(medium.OutputPin())
+	defer remote.Close()
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go NewDetector(reg, receiver, queue).Run(stopCh)
+
+	<-remote.Transmit(context.Background(), o.CodeOn, gpio.DefaultProtocols[0], o.PulseLength, gpio.TransmitOptions{})
+
+	select {
+	case cmd := <-queue:
+		assert.Equal(t, command.StateCorrectionCommand{Outlet: o, DesiredState: outlet.StateOn, Source: command.SourceStateDrift}, cmd)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for state correction command")
+	}
+}
+
+func TestDetector_EchoLog(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "foo", CodeOn: 5510451, CodeOff: 5510460, Protocol: 1, State: outlet.StateOff}
+	o2 := &outlet.Outlet{ID: "bar", CodeOn: 5510227, CodeOff: 5510236, Protocol: 1, State: outlet.StateOff}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2)
+
+	medium := gpio.NewMedium(gpio.MediumSeed(1))
+
+	receiver := gpio.NewWatcherReceiver(medium.Watcher())
+	defer receiver.Close()
+
+	echoLog := gpio.NewEchoLog(gpio.DefaultEchoWindow, gpio.DefaultProtocols)
+
+	own := gpio.NewPinTransmitter(medium.OutputPin(), gpio.TransmitterEchoLog(echoLog))
+	defer own.Close()
+
+	remote := gpio.NewPinTransmitter(medium.OutputPin())
+	defer remote.Close()
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	d := NewDetector(reg, receiver, queue)
+	d.EchoLog = echoLog
+
+	go d.Run(stopCh)
+
+	<-own.Transmit(context.Background(), o1.CodeOn, gpio.DefaultProtocols[0], 184, gpio.TransmitOptions{})
+	<-remote.Transmit(context.Background(), o2.CodeOn, gpio.DefaultProtocols[0], 184, gpio.TransmitOptions{})
+
+	select {
+	case cmd := <-queue:
+		assert.Equal(t, command.StateCorrectionCommand{Outlet: o2, DesiredState: outlet.StateOn, Source: command.SourceStateDrift}, cmd)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for state correction command")
+	}
+}
@@ -0,0 +1,101 @@
+// Package statepoll provides a poller which periodically reads back the
+// actual state of outlets that support it (e.g. Wi-Fi plugs) and emits state
+// correction commands if the state was changed outside of rfoutlet.
+package statepoll
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("component", "statepoll")
+
+// Poller periodically reads the state of all outlets using the StateReader
+// and pushes state correction commands into the command queue if an outlet's
+// actual state differs from the known state.
+type Poller struct {
+	Registry     *outlet.Registry
+	Reader       outlet.StateReader
+	CommandQueue chan<- command.Command
+	Clock        clockwork.Clock
+	Interval     time.Duration
+}
+
+// New creates a new *Poller which reads outlet states using reader every
+// interval.
+func New(registry *outlet.Registry, reader outlet.StateReader, queue chan<- command.Command, interval time.Duration) *Poller {
+	return &Poller{
+		Registry:     registry,
+		Reader:       reader,
+		CommandQueue: queue,
+		Clock:        clockwork.NewRealClock(),
+		Interval:     interval,
+	}
+}
+
+// Run runs the poll loop until stopCh is closed.
+func (p *Poller) Run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-p.Clock.After(p.Interval):
+			p.poll()
+		case <-stopCh:
+			log.Info("shutting down state poller")
+			return
+		}
+	}
+}
+
+func (p *Poller) poll() {
+	outlets := p.Registry.GetOutlets()
+	states := make([]outlet.State, len(outlets))
+	errs := make([]error, len(outlets))
+
+	// Outlets are read in parallel, so that unreachable devices do not delay
+	// reading the others.
+	var wg sync.WaitGroup
+
+	for i, o := range outlets {
+		wg.Add(1)
+
+		go func(i int, o *outlet.Outlet) {
+			defer wg.Done()
+			states[i], errs[i] = p.Reader.ReadState(o)
+		}(i, o)
+	}
+
+	wg.Wait()
+
+	for i, o := range outlets {
+		state, err := states[i], errs[i]
+		if err == outlet.ErrStateUnsupported {
+			continue
+		}
+
+		if err != nil {
+			log.WithField("outletID", o.ID).WithError(err).Warn("failed to read outlet state")
+			continue
+		}
+
+		if o.GetState() == state {
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"outletID":    o.ID,
+			"outletState": o.GetState(),
+			"actualState": state,
+		}).Info("detected outlet state change")
+
+		p.CommandQueue <- command.StateCorrectionCommand{
+			Outlet:       o,
+			DesiredState: state,
+			Source:       command.SourceStatePoll,
+		}
+	}
+}
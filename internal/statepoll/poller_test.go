@@ -0,0 +1,114 @@
+package statepoll
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeReader struct {
+	states map[string]outlet.State
+	errs   map[string]error
+}
+
+func (r *fakeReader) ReadState(o *outlet.Outlet) (outlet.State, error) {
+	if err, ok := r.errs[o.ID]; ok {
+		return 0, err
+	}
+
+	return r.states[o.ID], nil
+}
+
+func TestPoller(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "rf", State: outlet.StateOff}
+	o2 := &outlet.Outlet{ID: "unchanged", State: outlet.StateOn}
+	o3 := &outlet.Outlet{ID: "changed", State: outlet.StateOff}
+	o4 := &outlet.Outlet{ID: "unreachable", State: outlet.StateOff}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2, o3, o4)
+
+	reader := &fakeReader{
+		states: map[string]outlet.State{
+			"unchanged": outlet.StateOn,
+			"changed":   outlet.StateOn,
+		},
+		errs: map[string]error{
+			"rf":          outlet.ErrStateUnsupported,
+			"unreachable": errors.New("connection refused"),
+		},
+	}
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	fakeClock := clockwork.NewFakeClock()
+
+	p := New(reg, reader, queue, time.Minute)
+	p.Clock = fakeClock
+
+	go p.Run(stopCh)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Minute)
+
+	select {
+	case cmd := <-queue:
+		assert.Equal(t, command.StateCorrectionCommand{Outlet: o3, DesiredState: outlet.StateOn, Source: command.SourceStatePoll}, cmd)
+	case <-time.After(time.Second):
+		t.Fatal("timeout exceeded waiting for command")
+	}
+
+	// The poller waits for the next interval after polling all outlets.
+	fakeClock.BlockUntil(1)
+}
+
+// barrierReader blocks every read until the number of reads added to wg are
+// in flight at the same time.
+type barrierReader struct {
+	wg sync.WaitGroup
+}
+
+func (r *barrierReader) ReadState(o *outlet.Outlet) (outlet.State, error) {
+	r.wg.Done()
+	r.wg.Wait()
+
+	return outlet.StateOn, nil
+}
+
+func TestPoller_Parallel(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "foo", State: outlet.StateOff}
+	o2 := &outlet.Outlet{ID: "bar", State: outlet.StateOff}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2)
+
+	reader := &barrierReader{}
+	reader.wg.Add(2)
+
+	queue := make(chan command.Command, 2)
+
+	p := New(reg, reader, queue, time.Minute)
+
+	done := make(chan struct{})
+
+	go func() {
+		p.poll()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("outlets were not read in parallel")
+	}
+
+	assert.Len(t, queue, 2)
+}
@@ -0,0 +1,125 @@
+// Package staterefresh provides a refresher which periodically re-transmits
+// the current state of rf outlets. Rf outlets do not acknowledge received
+// codes, so a code that was missed due to interference leaves the outlet in
+// a different state than rfoutlet assumes until it is switched again.
+package staterefresh
+
+import (
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("component", "staterefresh")
+
+// DefaultTick is the default interval in which the refresher checks for
+// outlets that are due for a refresh.
+const DefaultTick = time.Second
+
+// BusyChecker can tell whether the transmitter of an outlet is busy.
+type BusyChecker interface {
+	// Busy returns true if the transmitter used to switch outlet is
+	// currently sending out codes or has transmissions pending.
+	Busy(outlet *outlet.Outlet) bool
+}
+
+// Refresher periodically pushes commands into the command queue that
+// re-transmit the current state of all outlets with a refresh interval. The
+// first refresh of each outlet is spread over its refresh interval and at
+// most one outlet is refreshed per tick to avoid bursts. Refreshes are
+// postponed while the outlet's transmitter is busy.
+type Refresher struct {
+	Registry     *outlet.Registry
+	Busy         BusyChecker
+	CommandQueue chan<- command.Command
+	Clock        clockwork.Clock
+	Tick         time.Duration
+
+	entries []*entry
+}
+
+type entry struct {
+	outlet *outlet.Outlet
+	due    time.Time
+}
+
+// New creates a new *Refresher.
+func New(registry *outlet.Registry, busy BusyChecker, queue chan<- command.Command) *Refresher {
+	return &Refresher{
+		Registry:     registry,
+		Busy:         busy,
+		CommandQueue: queue,
+		Clock:        clockwork.NewRealClock(),
+		Tick:         DefaultTick,
+	}
+}
+
+// Run runs the refresh loop until stopCh is closed.
+func (r *Refresher) Run(stopCh <-chan struct{}) {
+	r.schedule()
+
+	for {
+		select {
+		case <-r.Clock.After(r.Tick):
+			r.refresh()
+		case <-stopCh:
+			log.Info("shutting down state refresher")
+			return
+		}
+	}
+}
+
+// schedule spreads the first refresh of the outlets with a refresh interval
+// evenly over their intervals.
+func (r *Refresher) schedule() {
+	var outlets []*outlet.Outlet
+
+	for _, o := range r.Registry.GetOutlets() {
+		if o.RefreshInterval > 0 && !o.Stateless {
+			outlets = append(outlets, o)
+		}
+	}
+
+	now := r.Clock.Now()
+
+	r.entries = make([]*entry, len(outlets))
+
+	for i, o := range outlets {
+		offset := o.RefreshInterval * time.Duration(i+1) / time.Duration(len(outlets))
+
+		r.entries[i] = &entry{outlet: o, due: now.Add(offset)}
+	}
+}
+
+// refresh re-transmits the state of the outlet that is overdue the longest.
+func (r *Refresher) refresh() {
+	now := r.Clock.Now()
+
+	var next *entry
+
+	for _, e := range r.entries {
+		if !e.due.After(now) && (next == nil || e.due.Before(next.due)) {
+			next = e
+		}
+	}
+
+	if next == nil {
+		return
+	}
+
+	log := log.WithField("outletID", next.outlet.ID)
+
+	if r.Busy.Busy(next.outlet) {
+		log.Debug("transmitter is busy, postponing refresh")
+		return
+	}
+
+	log.Debug("refreshing outlet state")
+
+	next.due = now.Add(next.outlet.RefreshInterval)
+
+	r.CommandQueue <- command.RefreshCommand{Outlet: next.outlet}
+}
@@ -0,0 +1,104 @@
+package staterefresh
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBusyChecker struct {
+	busy bool
+}
+
+func (c *fakeBusyChecker) Busy(*outlet.Outlet) bool {
+	return c.busy
+}
+
+func TestRefresher(t *testing.T) {
+	o1 := &outlet.Outlet{ID: "foo", State: outlet.StateOn, RefreshInterval: time.Minute}
+	o2 := &outlet.Outlet{ID: "bar", State: outlet.StateOff}
+	o3 := &outlet.Outlet{ID: "baz", State: outlet.StateOff, RefreshInterval: time.Minute}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o1, o2, o3)
+
+	busy := &fakeBusyChecker{}
+	queue := make(chan command.Command, 10)
+	fakeClock := clockwork.NewFakeClock()
+
+	r := New(reg, busy, queue)
+	r.Clock = fakeClock
+	r.schedule()
+
+	refreshAfter := func(d time.Duration) {
+		fakeClock.Advance(d)
+		r.refresh()
+	}
+
+	// The first refreshes are spread over the interval.
+	refreshAfter(29 * time.Second)
+	assert.Len(t, queue, 0)
+
+	refreshAfter(time.Second)
+	assert.Equal(t, command.RefreshCommand{Outlet: o1}, <-queue)
+
+	// Refreshes are postponed while the transmitter is busy.
+	busy.busy = true
+	refreshAfter(30 * time.Second)
+	assert.Len(t, queue, 0)
+
+	busy.busy = false
+	refreshAfter(time.Second)
+	assert.Equal(t, command.RefreshCommand{Outlet: o3}, <-queue)
+
+	// Only one outlet is refreshed per tick.
+	refreshAfter(time.Minute)
+	assert.Equal(t, command.RefreshCommand{Outlet: o1}, <-queue)
+	assert.Len(t, queue, 0)
+
+	r.refresh()
+	assert.Equal(t, command.RefreshCommand{Outlet: o3}, <-queue)
+}
+
+func TestRefresher_ToggleBeforeExecute(t *testing.T) {
+	o := &outlet.Outlet{ID: "foo", State: outlet.StateOn, RefreshInterval: time.Minute}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o)
+
+	queue := make(chan command.Command, 10)
+	fakeClock := clockwork.NewFakeClock()
+
+	r := New(reg, &fakeBusyChecker{}, queue)
+	r.Clock = fakeClock
+	r.schedule()
+
+	fakeClock.Advance(time.Minute)
+	r.refresh()
+
+	refresh := <-queue
+
+	ctx, reg, s := command.NewTestContext()
+	reg.RegisterOutlets(o)
+
+	// The user switches the outlet off after the refresh was enqueued but
+	// before it is executed.
+	_, err := command.OutletCommand{OutletID: "foo", Action: command.ToggleOutletAction}.Execute(ctx)
+	require.NoError(t, err)
+
+	broadcast, err := refresh.Execute(ctx)
+	require.NoError(t, err)
+	assert.False(t, broadcast)
+	assert.Equal(t, outlet.StateOff, o.GetState())
+
+	s.Err = errors.New("whoops")
+
+	_, err = refresh.Execute(ctx)
+	assert.EqualError(t, err, "whoops")
+}
@@ -55,11 +55,30 @@ func (s *TimeSwitch) secondsUntilNextMinute() time.Duration {
 
 func (s *TimeSwitch) check() {
 	for _, outlet := range s.Registry.GetOutlets() {
-		if !outlet.Schedule.Enabled() {
+		if outlet.Cover != nil {
+			s.checkCover(outlet)
 			continue
 		}
 
-		desiredState := getDesiredState(outlet)
+		if outlet.Stateless || !outlet.Schedule.Enabled() {
+			outlet.ClearOverride()
+			continue
+		}
+
+		desiredState := outlet.ScheduledState(time.Now())
+
+		// Manual overrides (see outlet.DriftPolicyOverride) are kept until
+		// the schedule demands a different state than at the time the
+		// override started.
+		if scheduledState, ok := outlet.Override(); ok {
+			if scheduledState == desiredState {
+				continue
+			}
+
+			log.WithField("outletID", outlet.ID).Info("schedule transition, ending manual override")
+
+			outlet.ClearOverride()
+		}
 
 		// We only send out commands if the outlet is not in the desired state
 		// to avoid spamming the command queue.
@@ -67,15 +86,34 @@ func (s *TimeSwitch) check() {
 			s.CommandQueue <- command.StateCorrectionCommand{
 				Outlet:       outlet,
 				DesiredState: desiredState,
+				Source:       command.SourceSchedule,
 			}
 		}
 	}
 }
 
-func getDesiredState(o *outlet.Outlet) outlet.State {
-	if o.Schedule.Contains(time.Now()) {
-		return outlet.StateOn
+// checkCover moves the cover of o to the position demanded by its schedule.
+func (s *TimeSwitch) checkCover(o *outlet.Outlet) {
+	if !o.Schedule.Enabled() {
+		return
 	}
 
-	return outlet.StateOff
+	now := time.Now()
+	desiredPosition := o.ScheduledPosition(now)
+
+	// Covers that are already moving to the desired position or are
+	// already there are left alone.
+	if target, ok := o.Cover.Target(); ok {
+		if target == desiredPosition {
+			return
+		}
+	} else if o.Cover.Position(now) == desiredPosition {
+		return
+	}
+
+	s.CommandQueue <- command.CoverPositionCommand{
+		Outlet:   o,
+		Position: desiredPosition,
+		Source:   command.SourceSchedule,
+	}
 }
@@ -63,6 +63,7 @@ func TestTimeSwitch(t *testing.T) {
 			expectedCommands: []command.Command{
 				command.StateCorrectionCommand{
 					DesiredState: outlet.StateOn,
+					Source:       command.SourceSchedule,
 					Outlet: &outlet.Outlet{
 						State: outlet.StateOff,
 						Schedule: schedule.NewWithIntervals([]schedule.Interval{
@@ -111,6 +112,7 @@ func TestTimeSwitch(t *testing.T) {
 			expectedCommands: []command.Command{
 				command.StateCorrectionCommand{
 					DesiredState: outlet.StateOff,
+					Source:       command.SourceSchedule,
 					Outlet: &outlet.Outlet{
 						State: outlet.StateOn,
 						Schedule: schedule.NewWithIntervals([]schedule.Interval{
@@ -191,3 +193,85 @@ func TestTimeSwitch(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeSwitch_Override(t *testing.T) {
+	now := time.Now()
+	plus1 := now.Add(time.Hour)
+
+	o := &outlet.Outlet{
+		State: outlet.StateOff,
+		Schedule: schedule.NewWithIntervals([]schedule.Interval{
+			{
+				Enabled:  true,
+				Weekdays: []time.Weekday{now.Weekday()},
+				From:     schedule.NewDayTime(now.Hour(), now.Minute()),
+				To:       schedule.NewDayTime(plus1.Hour(), plus1.Minute()),
+			},
+		}),
+	}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o)
+
+	queue := make(chan command.Command, 1)
+
+	timeSwitch := New(reg, queue)
+
+	// The outlet was switched off manually while the schedule demands it to
+	// be on.
+	o.SetOverride(outlet.StateOn)
+
+	timeSwitch.check()
+	assert.Len(t, queue, 0)
+
+	// A schedule transition happened since the override started.
+	o.SetOverride(outlet.StateOff)
+
+	timeSwitch.check()
+	assert.Equal(t, command.StateCorrectionCommand{Outlet: o, DesiredState: outlet.StateOn, Source: command.SourceSchedule}, <-queue)
+
+	_, ok := o.Override()
+	assert.False(t, ok)
+}
+
+func TestTimeSwitch_Cover(t *testing.T) {
+	now := time.Now()
+	plus1 := now.Add(time.Hour)
+	position := 40
+
+	o := &outlet.Outlet{
+		Stateless: true,
+		Cover:     &outlet.Cover{TravelTimeUp: 10 * time.Second, TravelTimeDown: 10 * time.Second},
+		Schedule: schedule.NewWithIntervals([]schedule.Interval{
+			{
+				Enabled:  true,
+				Weekdays: []time.Weekday{now.Weekday()},
+				From:     schedule.NewDayTime(now.Hour(), now.Minute()),
+				To:       schedule.NewDayTime(plus1.Hour(), plus1.Minute()),
+				Position: &position,
+			},
+		}),
+	}
+
+	reg := outlet.NewRegistry()
+	reg.RegisterOutlets(o)
+
+	queue := make(chan command.Command, 1)
+
+	timeSwitch := New(reg, queue)
+
+	timeSwitch.check()
+	assert.Equal(t, command.CoverPositionCommand{Outlet: o, Position: 40, Source: command.SourceSchedule}, <-queue)
+
+	// Covers that are already moving to the scheduled position are left
+	// alone.
+	o.Cover.Move(40, time.Now())
+
+	timeSwitch.check()
+	assert.Len(t, queue, 0)
+
+	o.Cover.SetPosition(40)
+
+	timeSwitch.check()
+	assert.Len(t, queue, 0)
+}
@@ -0,0 +1,168 @@
+// Package trigger provides a handler which maps received rf codes, e.g. from
+// spare remote control buttons, doorbells or motion sensors, to actions.
+package trigger
+
+import (
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/webhook"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("component", "trigger")
+
+// DefaultDebounce is the default duration in which repeated receptions of a
+// trigger's code are ignored after it fired.
+const DefaultDebounce = time.Second
+
+// Trigger maps a received code to an action. Exactly one of OutletID,
+// GroupID, Scene or Webhook should be set.
+type Trigger struct {
+	// Name identifies the trigger in logs and webhook events.
+	Name string
+	// Code and Protocol are matched against received codes of
+	// gpio.BitLength bits.
+	Code     uint64
+	Protocol int
+	// Debounce is the duration in which further receptions of Code are
+	// ignored. The window is extended with every reception, so that holding
+	// down a remote control button fires the trigger only once.
+	Debounce time.Duration
+	// OutletID is the ID of the outlet that Action is performed on.
+	OutletID string
+	// GroupID is the ID of the outlet group that Action is performed on.
+	GroupID string
+	// Action is performed on the outlet or outlet group.
+	Action command.OutletAction
+	// Scene maps outlet IDs to the actions that are performed on them.
+	Scene map[string]command.OutletAction
+	// Webhook is notified if set.
+	Webhook *webhook.Webhook
+}
+
+// matches returns true if result contains the code of t. Like outlet codes,
+// trigger codes have a bit length of gpio.BitLength.
+func (t Trigger) matches(result gpio.ReceiveResult) bool {
+	return result.Code == t.Code && result.Protocol == t.Protocol && result.BitLength == gpio.BitLength
+}
+
+// WebhookSender can send trigger events to webhooks.
+type WebhookSender interface {
+	// SendTriggerEvent delivers event to webhook. It must not block.
+	SendTriggerEvent(webhook webhook.Webhook, event webhook.TriggerEvent)
+}
+
+// Handler listens for received codes and fires the matching triggers. Outlet,
+// group and scene actions are pushed into the command queue, webhooks are
+// notified via the WebhookSender.
+type Handler struct {
+	Triggers      []Trigger
+	Receiver      gpio.CodeReceiver
+	CommandQueue  chan<- command.Command
+	WebhookSender WebhookSender
+	Clock         clockwork.Clock
+	// EchoLog is optional. If set, received codes that were sent out by
+	// rfoutlet's own transmitters are ignored.
+	EchoLog *gpio.EchoLog
+
+	// lastSeen holds the time each trigger's code was last received.
+	lastSeen map[int]time.Time
+}
+
+// NewHandler creates a new *Handler.
+func NewHandler(triggers []Trigger, receiver gpio.CodeReceiver, queue chan<- command.Command, sender WebhookSender) *Handler {
+	return &Handler{
+		Triggers:      triggers,
+		Receiver:      receiver,
+		CommandQueue:  queue,
+		WebhookSender: sender,
+		Clock:         clockwork.NewRealClock(),
+	}
+}
+
+// Run runs the trigger loop until stopCh is closed.
+func (h *Handler) Run(stopCh <-chan struct{}) {
+	h.lastSeen = make(map[int]time.Time)
+
+	for {
+		select {
+		case <-stopCh:
+			log.Info("shutting down trigger handler")
+			return
+		case result, ok := <-h.Receiver.Receive():
+			if !ok {
+				log.Error("receiver was closed unexpectedly, shutting down trigger handler")
+				return
+			}
+
+			if h.isEcho(result) {
+				log.WithField("code", result.Code).Debug("ignoring echo of own transmission")
+				continue
+			}
+
+			at := result.LastSeen
+			if at.IsZero() {
+				at = h.Clock.Now()
+			}
+
+			for i, t := range h.Triggers {
+				if t.matches(result) && !h.debounced(i, t, at) {
+					h.fire(t, result, at)
+				}
+			}
+		}
+	}
+}
+
+// debounced records the reception of the i-th trigger's code at time at and
+// returns true if it was already received within the trigger's debounce
+// window before.
+func (h *Handler) debounced(i int, t Trigger, at time.Time) bool {
+	lastSeen, seen := h.lastSeen[i]
+	h.lastSeen[i] = at
+
+	return seen && at.Sub(lastSeen) < t.Debounce
+}
+
+// isEcho returns true if result is an echo of a code sent out by one of
+// rfoutlet's transmitters.
+func (h *Handler) isEcho(result gpio.ReceiveResult) bool {
+	if h.EchoLog == nil {
+		return false
+	}
+
+	at := result.FirstSeen
+	if at.IsZero() {
+		at = h.Clock.Now()
+	}
+
+	return h.EchoLog.IsEcho(result, at)
+}
+
+func (h *Handler) fire(t Trigger, result gpio.ReceiveResult, at time.Time) {
+	log.WithFields(logrus.Fields{
+		"trigger": t.Name,
+		"code":    result.Code,
+	}).Info("trigger fired")
+
+	if t.Webhook != nil {
+		h.WebhookSender.SendTriggerEvent(*t.Webhook, webhook.TriggerEvent{
+			Trigger:   t.Name,
+			Code:      result.Code,
+			Protocol:  result.Protocol,
+			Timestamp: at,
+		})
+		return
+	}
+
+	h.CommandQueue <- command.TriggerCommand{
+		Trigger:  t.Name,
+		OutletID: t.OutletID,
+		GroupID:  t.GroupID,
+		Action:   t.Action,
+		Scene:    t.Scene,
+	}
+}
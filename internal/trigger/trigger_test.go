@@ -0,0 +1,137 @@
+package trigger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/webhook"
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReceiver struct {
+	results chan gpio.ReceiveResult
+}
+
+func (f *fakeReceiver) Receive() <-chan gpio.ReceiveResult {
+	return f.results
+}
+
+func (f *fakeReceiver) Close() error { return nil }
+
+type fakeSender struct {
+	events []webhook.TriggerEvent
+}
+
+func (s *fakeSender) SendTriggerEvent(_ webhook.Webhook, event webhook.TriggerEvent) {
+	s.events = append(s.events, event)
+}
+
+func TestHandler(t *testing.T) {
+	triggers := []Trigger{
+		{Name: "button", Code: 123, Protocol: 1, Debounce: time.Second, OutletID: "foo", Action: command.ToggleOutletAction},
+		{Name: "all-off", Code: 456, Protocol: 1, GroupID: "bar", Action: command.OffOutletAction},
+		{Name: "doorbell", Code: 789, Protocol: 2, Webhook: &webhook.Webhook{URL: "http://localhost"}},
+	}
+
+	recv := &fakeReceiver{make(chan gpio.ReceiveResult)}
+	sender := &fakeSender{}
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	h := NewHandler(triggers, recv, queue, sender)
+	h.Clock = clockwork.NewFakeClockAt(now)
+
+	go func() {
+		h.Run(stopCh)
+		close(queue)
+	}()
+
+	go func() {
+		defer close(recv.results)
+		recv.results <- gpio.ReceiveResult{Code: 123, Protocol: 1, BitLength: gpio.BitLength, LastSeen: now}
+		// Debounced.
+		recv.results <- gpio.ReceiveResult{Code: 123, Protocol: 1, BitLength: gpio.BitLength, LastSeen: now.Add(500 * time.Millisecond)}
+		// Still debounced because the window was extended.
+		recv.results <- gpio.ReceiveResult{Code: 123, Protocol: 1, BitLength: gpio.BitLength, LastSeen: now.Add(1400 * time.Millisecond)}
+		// Protocol mismatch.
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 2, BitLength: gpio.BitLength}
+		// Bit length mismatch.
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 1, BitLength: 32}
+		recv.results <- gpio.ReceiveResult{Code: 789, Protocol: 2, BitLength: gpio.BitLength}
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 1, BitLength: gpio.BitLength}
+		recv.results <- gpio.ReceiveResult{Code: 123, Protocol: 1, BitLength: gpio.BitLength, LastSeen: now.Add(3 * time.Second)}
+	}()
+
+	expected := []command.Command{
+		command.TriggerCommand{Trigger: "button", OutletID: "foo", Action: command.ToggleOutletAction},
+		command.TriggerCommand{Trigger: "all-off", GroupID: "bar", Action: command.OffOutletAction},
+		command.TriggerCommand{Trigger: "button", OutletID: "foo", Action: command.ToggleOutletAction},
+	}
+
+	received := make([]command.Command, 0)
+
+	for cmd := range queue {
+		received = append(received, cmd)
+	}
+
+	assert.Equal(t, expected, received)
+	assert.Len(t, sender.events, 1)
+	assert.Equal(t, "doorbell", sender.events[0].Trigger)
+	assert.Equal(t, uint64(789), sender.events[0].Code)
+	assert.Equal(t, now, sender.events[0].Timestamp)
+}
+
+func TestHandler_EchoLog(t *testing.T) {
+	triggers := []Trigger{
+		{Name: "button", Code: 123, Protocol: 1, OutletID: "foo", Action: command.ToggleOutletAction},
+		{Name: "all-off", Code: 456, Protocol: 1, GroupID: "bar", Action: command.OffOutletAction},
+	}
+
+	echoLog := gpio.NewEchoLog(time.Minute, gpio.DefaultProtocols)
+
+	tx := gpio.NewPinTransmitter(gpio.NewFakeOutputPin(), gpio.TransmitterEchoLog(echoLog))
+	defer tx.Close()
+
+	require.NoError(t, <-tx.Transmit(context.Background(), 123, gpio.DefaultProtocols[0], 189, gpio.TransmitOptions{}))
+
+	recv := &fakeReceiver{make(chan gpio.ReceiveResult)}
+
+	queue := make(chan command.Command)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	h := NewHandler(triggers, recv, queue, &fakeSender{})
+	h.EchoLog = echoLog
+
+	go func() {
+		h.Run(stopCh)
+		close(queue)
+	}()
+
+	go func() {
+		defer close(recv.results)
+		// Echo of the own transmission.
+		recv.results <- gpio.ReceiveResult{Code: 123, Protocol: 1, BitLength: gpio.BitLength}
+		recv.results <- gpio.ReceiveResult{Code: 456, Protocol: 1, BitLength: gpio.BitLength}
+	}()
+
+	expected := []command.Command{
+		command.TriggerCommand{Trigger: "all-off", GroupID: "bar", Action: command.OffOutletAction},
+	}
+
+	received := make([]command.Command, 0)
+
+	for cmd := range queue {
+		received = append(received, cmd)
+	}
+
+	assert.Equal(t, expected, received)
+}
@@ -0,0 +1,329 @@
+// Package webhook provides a dispatcher which notifies other systems about
+// outlet state changes via signed HTTP POST requests.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/controller"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/sirupsen/logrus"
+)
+
+var log = logrus.WithField("component", "webhook")
+
+const (
+	// DefaultMaxAttempts is the default number of delivery attempts for a
+	// single event.
+	DefaultMaxAttempts = 5
+
+	// DefaultInitialBackoff is the default delay before the first retry.
+	DefaultInitialBackoff = time.Second
+
+	// DefaultMaxBackoff is the default maximum delay between two retries.
+	DefaultMaxBackoff = time.Minute
+
+	// DefaultTimeout is the default timeout for a single delivery attempt.
+	DefaultTimeout = 10 * time.Second
+
+	// EventStateChanged is the type of events sent when an outlet changed
+	// its state.
+	EventStateChanged = "outlet.stateChanged"
+
+	// EventTriggerFired is the type of events sent when a trigger with a
+	// webhook action fired.
+	EventTriggerFired = "trigger.fired"
+
+	// SignatureHeader is the header containing the hex encoded HMAC-SHA256
+	// signature of the request body, prefixed with "sha256=". It is only
+	// set if the webhook has a secret.
+	SignatureHeader = "X-Rfoutlet-Signature"
+
+	// EventHeader is the header containing the event type.
+	EventHeader = "X-Rfoutlet-Event"
+
+	// maxPendingEvents is the maximum number of events waiting to be
+	// dispatched. Further events are dropped.
+	maxPendingEvents = 64
+)
+
+// RetryPolicy defines how failed deliveries are retried. The delay between
+// retries starts at InitialBackoff and doubles after each attempt up to
+// MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// backoff returns the delay before the retry following attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+
+	for i := 1; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return backoff
+}
+
+// Webhook is an endpoint that is notified about outlet state changes.
+type Webhook struct {
+	// URL is the URL the events are POSTed to.
+	URL string
+	// Outlets and Groups are optional filters. If both are empty, the
+	// webhook receives events for all outlets. Otherwise it only receives
+	// events for the listed outlets and outlets of the listed groups.
+	Outlets []string
+	Groups  []string
+	// Secret is used to sign request bodies if non-empty.
+	Secret string
+	// Retry is the retry policy for failed deliveries.
+	Retry RetryPolicy
+}
+
+// matches returns true if the webhook should receive events for the outlet
+// with outletID in the group with groupID.
+func (w Webhook) matches(outletID, groupID string) bool {
+	if len(w.Outlets) == 0 && len(w.Groups) == 0 {
+		return true
+	}
+
+	return contains(w.Outlets, outletID) || contains(w.Groups, groupID)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Event is the JSON payload sent to webhooks.
+type Event struct {
+	Event         string         `json:"event"`
+	OutletID      string         `json:"outletID"`
+	GroupID       string         `json:"groupID"`
+	State         string         `json:"state"`
+	PreviousState string         `json:"previousState"`
+	Source        command.Source `json:"source"`
+	Timestamp     time.Time      `json:"timestamp"`
+}
+
+// TriggerEvent is the JSON payload sent to webhooks of triggers.
+type TriggerEvent struct {
+	Event     string    `json:"event"`
+	Trigger   string    `json:"trigger"`
+	Code      uint64    `json:"code"`
+	Protocol  int       `json:"protocol"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func stateName(state outlet.State) string {
+	if state == outlet.StateOn {
+		return "on"
+	}
+
+	return "off"
+}
+
+// Dispatcher delivers outlet state changes to webhooks. It implements
+// controller.StateChangeNotifier.
+type Dispatcher struct {
+	Webhooks []Webhook
+	Client   *http.Client
+	Clock    clockwork.Clock
+
+	events     chan controller.StateChange
+	deliveries chan pendingDelivery
+}
+
+// pendingDelivery is a single event that should be delivered to a webhook.
+type pendingDelivery struct {
+	webhook Webhook
+	event   string
+	body    []byte
+}
+
+var _ controller.StateChangeNotifier = (*Dispatcher)(nil)
+
+// NewDispatcher creates a new *Dispatcher for webhooks.
+func NewDispatcher(webhooks []Webhook) *Dispatcher {
+	return &Dispatcher{
+		Webhooks:   webhooks,
+		Client:     &http.Client{Timeout: DefaultTimeout},
+		Clock:      clockwork.NewRealClock(),
+		events:     make(chan controller.StateChange, maxPendingEvents),
+		deliveries: make(chan pendingDelivery, maxPendingEvents),
+	}
+}
+
+// NotifyStateChange implements controller.StateChangeNotifier. The state
+// change is delivered in the background, it is dropped if too many events
+// are pending.
+func (d *Dispatcher) NotifyStateChange(change controller.StateChange) {
+	select {
+	case d.events <- change:
+	default:
+		log.WithField("outletID", change.Outlet.ID).Warn("too many pending webhook events, dropping event")
+	}
+}
+
+// SendTriggerEvent delivers event to webhook in the background. Unlike state
+// changes, trigger events are only sent to webhook and not to d.Webhooks. The
+// event is dropped if too many events are pending.
+func (d *Dispatcher) SendTriggerEvent(webhook Webhook, event TriggerEvent) {
+	event.Event = EventTriggerFired
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.WithError(err).Error("failed to marshal webhook event")
+		return
+	}
+
+	select {
+	case d.deliveries <- pendingDelivery{webhook: webhook, event: EventTriggerFired, body: body}:
+	default:
+		log.WithField("trigger", event.Trigger).Warn("too many pending webhook events, dropping event")
+	}
+}
+
+// Run dispatches state changes to the webhooks until stopCh is closed. Each
+// delivery including its retries runs in its own goroutine, so that slow or
+// failing webhooks do not delay others. Pending retries are abandoned when
+// stopCh is closed.
+func (d *Dispatcher) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		select {
+		case change := <-d.events:
+			d.dispatch(ctx, change)
+		case delivery := <-d.deliveries:
+			go d.deliver(ctx, delivery.webhook, delivery.event, delivery.body)
+		case <-stopCh:
+			log.Info("shutting down webhook dispatcher")
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, change controller.StateChange) {
+	body, err := json.Marshal(Event{
+		Event:         EventStateChanged,
+		OutletID:      change.Outlet.ID,
+		GroupID:       change.GroupID,
+		State:         stateName(change.State),
+		PreviousState: stateName(change.PreviousState),
+		Source:        change.Source,
+		Timestamp:     change.Time,
+	})
+	if err != nil {
+		log.WithError(err).Error("failed to marshal webhook event")
+		return
+	}
+
+	for _, webhook := range d.Webhooks {
+		if webhook.matches(change.Outlet.ID, change.GroupID) {
+			go d.deliver(ctx, webhook, EventStateChanged, body)
+		}
+	}
+}
+
+// deliver POSTs body of an event to webhook and retries failed attempts according to the
+// webhook's retry policy.
+func (d *Dispatcher) deliver(ctx context.Context, webhook Webhook, event string, body []byte) {
+	log := log.WithField("url", webhook.URL)
+
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(ctx, webhook, event, body)
+		if err == nil {
+			log.Debug("delivered webhook event")
+			return
+		}
+
+		log := log.WithError(err).WithField("attempt", attempt)
+
+		if !retry || attempt >= webhook.Retry.MaxAttempts {
+			log.Error("failed to deliver webhook event, giving up")
+			return
+		}
+
+		backoff := webhook.Retry.backoff(attempt)
+
+		log.WithField("backoff", backoff).Warn("failed to deliver webhook event, retrying")
+
+		select {
+		case <-d.Clock.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// post sends a single delivery attempt. Returns an error if the attempt
+// failed and whether the delivery should be retried.
+func (d *Dispatcher) post(ctx context.Context, webhook Webhook, event string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, body))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+
+	// Client errors other than timeouts and rate limiting are not going to
+	// go away by retrying.
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true, err
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, err
+	default:
+		return true, err
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body using secret. Receivers
+// can use it to verify the SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/martinohmann/rfoutlet/internal/command"
+	"github.com/martinohmann/rfoutlet/internal/controller"
+	"github.com/martinohmann/rfoutlet/internal/outlet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// newServer creates a test server which responds with the given status codes
+// in order and with 200 once they are exhausted.
+func newServer(t *testing.T, statusCodes ...int) (*httptest.Server, <-chan delivery) {
+	deliveries := make(chan delivery, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		deliveries <- delivery{header: r.Header, body: body}
+
+		if len(statusCodes) > 0 {
+			w.WriteHeader(statusCodes[0])
+			statusCodes = statusCodes[1:]
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, deliveries
+}
+
+func receive(t *testing.T, deliveries <-chan delivery) delivery {
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("timeout exceeded waiting for delivery")
+		return delivery{}
+	}
+}
+
+func stateChange(outletID, groupID string) controller.StateChange {
+	return controller.StateChange{
+		Outlet:        &outlet.Outlet{ID: outletID},
+		GroupID:       groupID,
+		PreviousState: outlet.StateOff,
+		State:         outlet.StateOn,
+		Source:        command.SourceSchedule,
+		Time:          time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	srv, deliveries := newServer(t)
+
+	d := NewDispatcher([]Webhook{{URL: srv.URL, Secret: "s3cr3t"}})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go d.Run(stopCh)
+
+	d.NotifyStateChange(stateChange("foo", "bar"))
+
+	del := receive(t, deliveries)
+
+	assert.Equal(t, "application/json", del.header.Get("Content-Type"))
+	assert.Equal(t, EventStateChanged, del.header.Get(EventHeader))
+	assert.Equal(t, "sha256="+Sign("s3cr3t", del.body), del.header.Get(SignatureHeader))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(del.body, &event))
+
+	assert.Equal(t, Event{
+		Event:         EventStateChanged,
+		OutletID:      "foo",
+		GroupID:       "bar",
+		State:         "on",
+		PreviousState: "off",
+		Source:        command.SourceSchedule,
+		Timestamp:     time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC),
+	}, event)
+}
+
+func TestDispatcher_SendTriggerEvent(t *testing.T) {
+	srv, deliveries := newServer(t)
+
+	// Trigger events are not sent to the state change webhooks.
+	d := NewDispatcher([]Webhook{{URL: "http://localhost:1"}})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	go d.Run(stopCh)
+
+	timestamp := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	d.SendTriggerEvent(Webhook{URL: srv.URL, Secret: "s3cr3t"}, TriggerEvent{Trigger: "doorbell", Code: 123, Protocol: 1, Timestamp: timestamp})
+
+	del := receive(t, deliveries)
+
+	assert.Equal(t, EventTriggerFired, del.header.Get(EventHeader))
+	assert.Equal(t, "sha256="+Sign("s3cr3t", del.body), del.header.Get(SignatureHeader))
+
+	var event TriggerEvent
+	require.NoError(t, json.Unmarshal(del.body, &event))
+
+	assert.Equal(t, TriggerEvent{
+		Event:     EventTriggerFired,
+		Trigger:   "doorbell",
+		Code:      123,
+		Protocol:  1,
+		Timestamp: timestamp,
+	}, event)
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	srv, deliveries := newServer(t)
+
+	d := NewDispatcher([]Webhook{
+		{URL: srv.URL + "/outlets", Outlets: []string{"foo"}},
+		{URL: srv.URL + "/groups", Groups: []string{"qux"}},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d.dispatch(ctx, stateChange("foo", "bar"))
+	d.dispatch(ctx, stateChange("baz", "qux"))
+	d.dispatch(ctx, stateChange("baz", "bar"))
+
+	received := make(map[string]bool)
+
+	for i := 0; i < 2; i++ {
+		var event Event
+		require.NoError(t, json.Unmarshal(receive(t, deliveries).body, &event))
+		received[event.OutletID+"/"+event.GroupID] = true
+	}
+
+	assert.Equal(t, map[string]bool{"foo/bar": true, "baz/qux": true}, received)
+
+	select {
+	case <-deliveries:
+		t.Fatal("unexpected delivery")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	srv, deliveries := newServer(t, http.StatusInternalServerError, http.StatusTooManyRequests)
+
+	fakeClock := clockwork.NewFakeClock()
+
+	d := NewDispatcher([]Webhook{{
+		URL: srv.URL,
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		},
+	}})
+	d.Clock = fakeClock
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d.dispatch(ctx, stateChange("foo", "bar"))
+
+	first := receive(t, deliveries)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Second)
+
+	receive(t, deliveries)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(2 * time.Second)
+
+	third := receive(t, deliveries)
+
+	assert.Equal(t, first.body, third.body)
+}
+
+func TestDispatcher_post(t *testing.T) {
+	tests := []struct {
+		status        int
+		expectedRetry bool
+		expectedErr   string
+	}{
+		{status: http.StatusNoContent},
+		{status: http.StatusBadRequest, expectedErr: "unexpected status 400 Bad Request"},
+		{status: http.StatusRequestTimeout, expectedRetry: true, expectedErr: "unexpected status 408 Request Timeout"},
+		{status: http.StatusTooManyRequests, expectedRetry: true, expectedErr: "unexpected status 429 Too Many Requests"},
+		{status: http.StatusBadGateway, expectedRetry: true, expectedErr: "unexpected status 502 Bad Gateway"},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			srv, _ := newServer(t, test.status)
+
+			d := NewDispatcher(nil)
+
+			retry, err := d.post(context.Background(), Webhook{URL: srv.URL}, EventStateChanged, []byte(`{}`))
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, test.expectedRetry, retry)
+		})
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+	assert.Equal(t, 5*time.Second, p.backoff(10))
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
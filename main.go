@@ -17,6 +17,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/martinohmann/rfoutlet/cmd"
+	"github.com/martinohmann/rfoutlet/pkg/gpio/pigpio"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -50,6 +51,8 @@ func newRootCommand() *cobra.Command {
 	cmd.PersistentFlags().BoolVar(&debug, "debug", debug, "enable debug mode. this will cause more verbose output")
 	cmd.PersistentFlags().String("gpio-chip", "gpiochip0", "name of the GPIO chip to interact with")
 	cmd.PersistentFlags().Bool("gpio-mockup", false, "automatically load and unload the gpio-mockup kernel module, useful for testing")
+	cmd.PersistentFlags().String("gpio-backend", "gpiod", "gpio backend to use. Either 'gpiod' to use the gpio character device or 'pigpio' to use a pigpio daemon")
+	cmd.PersistentFlags().String("pigpio-address", pigpio.DefaultAddress, "address of the pigpio daemon, only used with --gpio-backend=pigpio")
 
 	return cmd
 }
@@ -57,8 +60,12 @@ func newRootCommand() *cobra.Command {
 func main() {
 	rootCmd := newRootCommand()
 
+	rootCmd.AddCommand(cmd.NewLearnCommand())
+	rootCmd.AddCommand(cmd.NewRecordCommand())
+	rootCmd.AddCommand(cmd.NewReplayCommand())
 	rootCmd.AddCommand(cmd.NewServeCommand())
 	rootCmd.AddCommand(cmd.NewSniffCommand())
+	rootCmd.AddCommand(cmd.NewTimingTestCommand())
 	rootCmd.AddCommand(cmd.NewTransmitCommand())
 
 	if err := rootCmd.Execute(); err != nil {
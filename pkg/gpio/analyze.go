@@ -0,0 +1,271 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// clusterGap is the minimum factor between two consecutive sorted pulse
+	// widths to consider them to be part of different clusters.
+	clusterGap = 1.15
+
+	// maxPulseDivisor is the maximum number of base pulses the shortest
+	// observed pulse is assumed to consist of.
+	maxPulseDivisor = 10
+
+	// maxRatioDeviation is the maximum deviation of a cluster's pulse width
+	// ratio from an integer for a base pulse length to be accepted.
+	maxRatioDeviation = 0.2
+)
+
+// Frame contains the raw timings of a received frame.
+type Frame struct {
+	// Timings contains the durations of the level changes in microseconds.
+	// The first element is the sync gap preceding the frame, followed by
+	// the alternating high and low pulses of the data bits. The last element
+	// is the high pulse of the frame's sync sequence.
+	Timings []int64 `json:"timings"`
+}
+
+// bitLength returns the number of data bits in f.
+func (f Frame) bitLength() int {
+	return (len(f.Timings) - 2) / 2
+}
+
+// Analysis is the result of a protocol inference.
+type Analysis struct {
+	// Protocol is the inferred protocol.
+	Protocol Protocol `json:"protocol"`
+
+	// PulseLength is the inferred base pulse length in microseconds.
+	PulseLength int64 `json:"pulseLength"`
+
+	// BitLength is the number of data bits per frame.
+	BitLength uint `json:"bitLength"`
+
+	// Frames is the number of frames that were considered for the analysis.
+	Frames int `json:"frames"`
+
+	// Codes contains the distinct codes decoded from the frames using
+	// Protocol.
+	Codes []uint64 `json:"codes"`
+}
+
+// Analyze tries to infer the protocol used to send frames. Only frames with
+// the most common bit length are considered. The pulse widths of all data bits
+// are clustered to determine the base pulse length and the high/low ratios of
+// zeros and ones. Returns an error if no consistent protocol could be
+// inferred.
+func Analyze(frames []Frame) (*Analysis, error) {
+	frames = framesWithCommonBitLength(frames)
+	if len(frames) == 0 {
+		return nil, errors.New("no frames to analyze")
+	}
+
+	var widths []int64
+	var syncHigh, syncLow int64
+
+	for _, f := range frames {
+		widths = append(widths, f.Timings[1:len(f.Timings)-1]...)
+		syncLow += f.Timings[0]
+		syncHigh += f.Timings[len(f.Timings)-1]
+	}
+
+	clusters := clusterWidths(widths)
+	if len(clusters) < 2 {
+		return nil, errors.New("all pulses have the same width, unable to distinguish zeros and ones")
+	}
+
+	pulseLength, err := basePulseLength(clusters)
+	if err != nil {
+		return nil, err
+	}
+
+	pulseLength = refinePulseLength(widths, pulseLength)
+
+	ratio := func(width int64) uint {
+		r := uint(math.Round(float64(width) / pulseLength))
+		if r == 0 {
+			return 1
+		}
+
+		return r
+	}
+
+	n := int64(len(frames))
+
+	proto := Protocol{
+		Sync: HighLow{High: ratio(syncHigh / n), Low: ratio(syncLow / n)},
+	}
+
+	seen := make(map[HighLow]bool)
+	pairs := make([]HighLow, 0, 2)
+
+	for _, f := range frames {
+		for i := 1; i < len(f.Timings)-1; i += 2 {
+			hl := HighLow{ratio(f.Timings[i]), ratio(f.Timings[i+1])}
+
+			if !seen[hl] {
+				seen[hl] = true
+				pairs = append(pairs, hl)
+			}
+		}
+	}
+
+	if len(pairs) != 2 {
+		return nil, fmt.Errorf("expected 2 distinct bit encodings, found %d", len(pairs))
+	}
+
+	// By convention, zeros have the shorter high pulse.
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].High < pairs[j].High })
+
+	proto.Zero, proto.One = pairs[0], pairs[1]
+
+	if proto.Zero.High == proto.One.High {
+		return nil, errors.New("zeros and ones have high pulses of the same width")
+	}
+
+	analysis := &Analysis{
+		Protocol:    proto,
+		PulseLength: int64(math.Round(pulseLength)),
+		BitLength:   uint(frames[0].bitLength()),
+		Frames:      len(frames),
+		Codes:       decodeFrames(frames, proto, pulseLength),
+	}
+
+	return analysis, nil
+}
+
+// framesWithCommonBitLength returns all frames having the most common bit
+// length.
+func framesWithCommonBitLength(frames []Frame) []Frame {
+	counts := make(map[int]int)
+
+	var common int
+
+	for _, f := range frames {
+		if len(f.Timings) < 4 {
+			continue
+		}
+
+		bl := f.bitLength()
+		counts[bl]++
+
+		if counts[bl] > counts[common] || (counts[bl] == counts[common] && bl > common) {
+			common = bl
+		}
+	}
+
+	result := make([]Frame, 0, counts[common])
+
+	for _, f := range frames {
+		if len(f.Timings) >= 4 && f.bitLength() == common {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// clusterWidths groups similar pulse widths and returns the mean width of
+// each cluster in ascending order.
+func clusterWidths(widths []int64) []float64 {
+	sorted := make([]int64, len(widths))
+	copy(sorted, widths)
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var (
+		means        []float64
+		prev         int64
+		sum, samples int64
+	)
+
+	for _, w := range sorted {
+		if samples > 0 && float64(w) > float64(prev)*clusterGap {
+			means = append(means, float64(sum)/float64(samples))
+			sum, samples = 0, 0
+		}
+
+		sum += w
+		samples++
+		prev = w
+	}
+
+	if samples > 0 {
+		means = append(means, float64(sum)/float64(samples))
+	}
+
+	return means
+}
+
+// basePulseLength finds the longest base pulse length of which all cluster
+// widths are approximately integer multiples.
+func basePulseLength(clusters []float64) (float64, error) {
+	for divisor := 1; divisor <= maxPulseDivisor; divisor++ {
+		base := clusters[0] / float64(divisor)
+
+		ok := true
+
+		for _, c := range clusters {
+			r := c / base
+			if math.Abs(r-math.Round(r)) > maxRatioDeviation {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			return base, nil
+		}
+	}
+
+	return 0, errors.New("pulse widths are not multiples of a common base pulse length")
+}
+
+// refinePulseLength refines the estimated pulseLength by taking all widths
+// into account instead of only the shortest ones.
+func refinePulseLength(widths []int64, pulseLength float64) float64 {
+	var sum, units float64
+
+	for _, w := range widths {
+		sum += float64(w)
+		units += math.Round(float64(w) / pulseLength)
+	}
+
+	if units == 0 {
+		return pulseLength
+	}
+
+	return sum / units
+}
+
+// decodeFrames decodes frames using proto and returns the distinct codes in
+// the order of their first occurrence.
+func decodeFrames(frames []Frame, proto Protocol, pulseLength float64) []uint64 {
+	seen := make(map[uint64]bool)
+	codes := make([]uint64, 0)
+
+	for _, f := range frames {
+		var code uint64
+
+		for i := 1; i < len(f.Timings)-1; i += 2 {
+			code <<= 1
+
+			high := float64(f.Timings[i]) / pulseLength
+			if math.Abs(high-float64(proto.One.High)) < math.Abs(high-float64(proto.Zero.High)) {
+				code |= 1
+			}
+		}
+
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+
+	return codes
+}
@@ -0,0 +1,149 @@
+package gpio
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeFrame creates a frame as it would be observed by a *Receiver. Each
+// timing is distorted by up to jitter percent.
+func makeFrame(proto Protocol, code uint64, bitLength int, pulseLength uint, jitter int64, rnd *rand.Rand) Frame {
+	pulses := proto.pulses(code, bitLength, pulseLength)
+
+	// The receiver sees the sync gap of the previous frame first.
+	timings := []int64{int64(pulses[len(pulses)-1] / time.Microsecond)}
+
+	for _, p := range pulses[:len(pulses)-1] {
+		timings = append(timings, int64(p/time.Microsecond))
+	}
+
+	if jitter > 0 {
+		for i, t := range timings {
+			timings[i] = t + t*(rnd.Int63n(2*jitter+1)-jitter)/100
+		}
+	}
+
+	return Frame{Timings: timings}
+}
+
+func TestAnalyze(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name        string
+		frames      []Frame
+		expected    *Analysis
+		expectedErr string
+	}{
+		{
+			name:        "no frames",
+			expectedErr: "no frames to analyze",
+		},
+		{
+			name: "protocol 1 with jitter",
+			frames: []Frame{
+				makeFrame(DefaultProtocols[0], 5510451, 24, 350, 5, rnd),
+				makeFrame(DefaultProtocols[0], 5510451, 24, 350, 5, rnd),
+				makeFrame(DefaultProtocols[0], 5510460, 24, 350, 5, rnd),
+				makeFrame(DefaultProtocols[0], 1, 12, 350, 5, rnd),
+			},
+			expected: &Analysis{
+				Protocol:    DefaultProtocols[0],
+				PulseLength: 350,
+				BitLength:   24,
+				Frames:      3,
+				Codes:       []uint64{5510451, 5510460},
+			},
+		},
+		{
+			name: "protocol 3",
+			frames: []Frame{
+				makeFrame(DefaultProtocols[2], 83281, 20, 100, 0, rnd),
+				makeFrame(DefaultProtocols[2], 83281, 20, 100, 0, rnd),
+			},
+			expected: &Analysis{
+				Protocol:    DefaultProtocols[2],
+				PulseLength: 100,
+				BitLength:   20,
+				Frames:      2,
+				Codes:       []uint64{83281},
+			},
+		},
+		{
+			name: "only zeros",
+			frames: []Frame{
+				makeFrame(DefaultProtocols[0], 0, 24, 350, 0, rnd),
+			},
+			expectedErr: "expected 2 distinct bit encodings, found 1",
+		},
+		{
+			name: "same width",
+			frames: []Frame{
+				{Timings: []int64{10000, 300, 300, 300, 300, 300}},
+			},
+			expectedErr: "all pulses have the same width, unable to distinguish zeros and ones",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis, err := Analyze(test.frames)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErr, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+
+			// Allow small deviations of the pulse length caused by jitter.
+			assert.InDelta(t, test.expected.PulseLength, analysis.PulseLength, 2)
+			analysis.PulseLength = test.expected.PulseLength
+
+			assert.Equal(t, test.expected, analysis)
+		})
+	}
+}
+
+func TestReceiverUnmatchedFrames(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+	pipe := newPinWatcherPipe(fakeClock)
+
+	tx := NewPinTransmitter(pipe, TransmissionCount(10))
+	tx.delay = fakeClock.Advance
+	defer tx.Close()
+
+	rx := NewWatcherReceiver(pipe,
+		ReceiverProtocols(DefaultProtocols[:1]),
+		ReceiverUnmatchedFrames(true),
+	)
+
+	<-tx.Transmit(context.Background(), 83281, DefaultProtocols[2], 100, TransmitOptions{})
+	rx.Close()
+
+	var frames []Frame
+	for frame := range rx.Unmatched() {
+		frames = append(frames, frame)
+	}
+
+	require.NotEmpty(t, frames)
+
+	for result := range rx.Receive() {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+
+	analysis, err := Analyze(frames)
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultProtocols[2], analysis.Protocol)
+	assert.Equal(t, int64(100), analysis.PulseLength)
+	assert.Equal(t, uint(24), analysis.BitLength)
+	assert.Equal(t, []uint64{83281}, analysis.Codes)
+}
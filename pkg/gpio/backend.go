@@ -0,0 +1,77 @@
+package gpio
+
+import (
+	"time"
+
+	"github.com/warthog618/gpiod"
+)
+
+// Backend provides access to the pins of a gpio device.
+type Backend interface {
+	Closer
+	// OutputPin requests the pin at offset as output pin.
+	OutputPin(offset int) (OutputPin, error)
+
+	// Watcher creates a Watcher for the pin at offset.
+	Watcher(offset int) (Watcher, error)
+}
+
+// PulseSender is an OutputPin that can send out a whole sequence of pulses at
+// once, e.g. using hardware-timed waveforms. The *Transmitter uses it instead
+// of busy-waiting if the pin implements it.
+type PulseSender interface {
+	// SendPulses sends a sequence of alternating high and low pulses,
+	// starting with a high pulse, and blocks until all pulses were sent. The
+	// pin is left in low state.
+	SendPulses(pulses []time.Duration) error
+}
+
+type chipBackend struct {
+	chip *gpiod.Chip
+}
+
+// NewChipBackend creates a Backend which uses the pins of a gpiod chip.
+// Closing the Backend closes the chip.
+func NewChipBackend(chip *gpiod.Chip) Backend {
+	return &chipBackend{chip: chip}
+}
+
+// OutputPin implements Backend.
+func (b *chipBackend) OutputPin(offset int) (OutputPin, error) {
+	return b.chip.RequestLine(offset, gpiod.AsOutput(0))
+}
+
+// Watcher implements Backend.
+func (b *chipBackend) Watcher(offset int) (Watcher, error) {
+	return NewWatcher(b.chip, offset)
+}
+
+// Close implements Closer.
+func (b *chipBackend) Close() error {
+	return b.chip.Close()
+}
+
+type mediumBackend struct {
+	medium *Medium
+}
+
+// Backend returns a Backend for m. Pin offsets are ignored since all pins are
+// attached to the same medium.
+func (m *Medium) Backend() Backend {
+	return &mediumBackend{medium: m}
+}
+
+// OutputPin implements Backend.
+func (b *mediumBackend) OutputPin(int) (OutputPin, error) {
+	return b.medium.OutputPin(), nil
+}
+
+// Watcher implements Backend.
+func (b *mediumBackend) Watcher(int) (Watcher, error) {
+	return b.medium.Watcher(), nil
+}
+
+// Close implements Closer.
+func (b *mediumBackend) Close() error {
+	return nil
+}
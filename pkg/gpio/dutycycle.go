@@ -0,0 +1,229 @@
+package gpio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// maxDutyCycleSleep is the maximum duration a transmission waits for budget
+// before checking whether it was cancelled.
+const maxDutyCycleSleep = 100 * time.Millisecond
+
+// ErrDutyCycleExceeded is returned if a transmission is rejected because it
+// would exceed the duty-cycle budget.
+var ErrDutyCycleExceeded = errors.New("duty-cycle budget exceeded")
+
+// DutyCycleMode defines what happens to transmissions that would exceed the
+// duty-cycle budget.
+type DutyCycleMode string
+
+const (
+	// DutyCycleDelay delays transmissions until enough budget is available.
+	DutyCycleDelay DutyCycleMode = "delay"
+
+	// DutyCycleReject rejects transmissions with ErrDutyCycleExceeded.
+	DutyCycleReject DutyCycleMode = "reject"
+)
+
+// Validate returns an error if m is not a known mode.
+func (m DutyCycleMode) Validate() error {
+	switch m {
+	case DutyCycleDelay, DutyCycleReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid duty-cycle mode %q, expected %q or %q", m, DutyCycleDelay, DutyCycleReject)
+	}
+}
+
+// DutyCycleStats describes the state of a duty-cycle budget.
+type DutyCycleStats struct {
+	// Limit is the maximum fraction of the window the transmitter may be
+	// on air.
+	Limit float64
+
+	// Window is the duration of the rolling window.
+	Window time.Duration
+
+	// Used is the airtime used within the current window.
+	Used time.Duration
+
+	// Reserved is the airtime reserved by pending transmissions. Only used
+	// in DutyCycleReject mode.
+	Reserved time.Duration
+
+	// Remaining is the airtime that is still available within the current
+	// window.
+	Remaining time.Duration
+}
+
+type airtimeEntry struct {
+	at      time.Time
+	airtime time.Duration
+}
+
+// DutyCycleLimiter limits the airtime of transmissions to a fraction of a
+// rolling window, e.g. to comply with the duty-cycle limits of the 433 MHz
+// SRD band. It is safe for concurrent use and can be shared by multiple
+// transmitters that use the same band.
+type DutyCycleLimiter struct {
+	limit  float64
+	window time.Duration
+	mode   DutyCycleMode
+	clock  clockwork.Clock
+
+	mu       sync.Mutex
+	entries  []airtimeEntry
+	reserved time.Duration
+}
+
+// NewDutyCycleLimiter creates a new *DutyCycleLimiter which allows
+// transmissions to be on air for the fraction limit (e.g. 0.1 for 10%) of
+// window. Transmissions exceeding the budget are handled according to mode.
+func NewDutyCycleLimiter(limit float64, window time.Duration, mode DutyCycleMode) *DutyCycleLimiter {
+	return &DutyCycleLimiter{
+		limit:  limit,
+		window: window,
+		mode:   mode,
+		clock:  clockwork.NewRealClock(),
+	}
+}
+
+// Stats returns the current state of the duty-cycle budget.
+func (l *DutyCycleLimiter) Stats() DutyCycleStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	used := l.used()
+
+	remaining := l.budget() - used - l.reserved
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return DutyCycleStats{
+		Limit:     l.limit,
+		Window:    l.window,
+		Used:      used,
+		Reserved:  l.reserved,
+		Remaining: remaining,
+	}
+}
+
+// budget returns the total airtime available per window.
+func (l *DutyCycleLimiter) budget() time.Duration {
+	return time.Duration(float64(l.window) * l.limit)
+}
+
+// used prunes entries that left the window and returns the airtime used by
+// the remaining ones. Must be called with l.mu held.
+func (l *DutyCycleLimiter) used() time.Duration {
+	cutoff := l.clock.Now().Add(-l.window)
+
+	i := 0
+	for i < len(l.entries) && !l.entries[i].at.After(cutoff) {
+		i++
+	}
+
+	l.entries = l.entries[i:]
+
+	var used time.Duration
+
+	for _, e := range l.entries {
+		used += e.airtime
+	}
+
+	return used
+}
+
+// admit is called when a transmission is enqueued which sends out pulses with
+// given airtime count times in a row. It returns the airtime that was reserved
+// for the transmission or ErrDutyCycleExceeded if the transmission must be
+// rejected. In DutyCycleDelay mode, transmissions are only rejected if a
+// single repetition exceeds the whole budget.
+func (l *DutyCycleLimiter) admit(airtime time.Duration, count int) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.mode != DutyCycleReject {
+		if airtime > l.budget() {
+			return 0, ErrDutyCycleExceeded
+		}
+
+		return 0, nil
+	}
+
+	total := airtime * time.Duration(count)
+
+	if l.used()+l.reserved+total > l.budget() {
+		return 0, ErrDutyCycleExceeded
+	}
+
+	l.reserved += total
+
+	return total, nil
+}
+
+// release releases airtime that was reserved but not used.
+func (l *DutyCycleLimiter) release(airtime time.Duration) {
+	l.mu.Lock()
+	l.reserved -= airtime
+	l.mu.Unlock()
+}
+
+// consume records airtime that was just used. reserved is the part of it
+// that was reserved beforehand.
+func (l *DutyCycleLimiter) consume(airtime, reserved time.Duration) {
+	l.mu.Lock()
+	l.reserved -= reserved
+	l.entries = append(l.entries, airtimeEntry{at: l.clock.Now(), airtime: airtime})
+	l.mu.Unlock()
+}
+
+// wait blocks until airtime is available. In DutyCycleReject mode the airtime
+// was already reserved, so wait returns immediately. Returns early if
+// cancelled returns true.
+func (l *DutyCycleLimiter) wait(airtime time.Duration, cancelled func() bool) {
+	if l.mode == DutyCycleReject {
+		return
+	}
+
+	for !cancelled() {
+		d := l.waitTime(airtime)
+		if d <= 0 {
+			return
+		}
+
+		if d > maxDutyCycleSleep {
+			d = maxDutyCycleSleep
+		}
+
+		l.clock.Sleep(d)
+	}
+}
+
+// waitTime returns the duration until airtime is available.
+func (l *DutyCycleLimiter) waitTime(airtime time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	used := l.used()
+	budget := l.budget()
+
+	if used+airtime <= budget {
+		return 0
+	}
+
+	for _, e := range l.entries {
+		used -= e.airtime
+
+		if used+airtime <= budget {
+			return e.at.Add(l.window).Sub(l.clock.Now())
+		}
+	}
+
+	return l.window
+}
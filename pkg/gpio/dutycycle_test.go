@@ -0,0 +1,153 @@
+package gpio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDutyCycleLimiter(clock clockwork.Clock, mode DutyCycleMode) *DutyCycleLimiter {
+	l := NewDutyCycleLimiter(0.1, time.Second, mode)
+	l.clock = clock
+	return l
+}
+
+func TestDutyCycleModeValidate(t *testing.T) {
+	assert.NoError(t, DutyCycleDelay.Validate())
+	assert.NoError(t, DutyCycleReject.Validate())
+	assert.EqualError(t, DutyCycleMode("foo").Validate(), `invalid duty-cycle mode "foo", expected "delay" or "reject"`)
+}
+
+func TestDutyCycleLimiter_Reject(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	l := newTestDutyCycleLimiter(fakeClock, DutyCycleReject)
+
+	reserved, err := l.admit(30*time.Millisecond, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 60*time.Millisecond, reserved)
+
+	_, err = l.admit(30*time.Millisecond, 2)
+	assert.Equal(t, ErrDutyCycleExceeded, err)
+
+	l.consume(40*time.Millisecond, 40*time.Millisecond)
+	l.release(20 * time.Millisecond)
+
+	assert.Equal(t, DutyCycleStats{
+		Limit:     0.1,
+		Window:    time.Second,
+		Used:      40 * time.Millisecond,
+		Remaining: 60 * time.Millisecond,
+	}, l.Stats())
+
+	_, err = l.admit(30*time.Millisecond, 2)
+	require.NoError(t, err)
+
+	fakeClock.Advance(time.Second)
+
+	stats := l.Stats()
+	assert.Equal(t, time.Duration(0), stats.Used)
+	assert.Equal(t, 60*time.Millisecond, stats.Reserved)
+	assert.Equal(t, 40*time.Millisecond, stats.Remaining)
+}
+
+func TestDutyCycleLimiter_Delay(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	l := newTestDutyCycleLimiter(fakeClock, DutyCycleDelay)
+
+	_, err := l.admit(time.Second, 1)
+	assert.Equal(t, ErrDutyCycleExceeded, err)
+
+	reserved, err := l.admit(100*time.Millisecond, 5)
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), reserved)
+
+	l.consume(30*time.Millisecond, 0)
+	fakeClock.Advance(200 * time.Millisecond)
+	l.consume(50*time.Millisecond, 0)
+
+	assert.Equal(t, time.Duration(0), l.waitTime(20*time.Millisecond))
+	assert.Equal(t, 800*time.Millisecond, l.waitTime(40*time.Millisecond))
+	assert.Equal(t, time.Second, l.waitTime(80*time.Millisecond))
+}
+
+func TestTransmitterDutyCycle_Reject(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	limiter := newTestDutyCycleLimiter(fakeClock, DutyCycleReject)
+
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmissionCount(1), TransmitterDutyCycle(limiter))
+	tx.delay = func(time.Duration) {}
+	defer tx.Close()
+
+	// Each transmission takes 128 pulses of 190µs, so that 4 of them fit
+	// into the budget of 100ms.
+	for i := 0; i < 4; i++ {
+		require.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{}))
+	}
+
+	assert.Equal(t, ErrDutyCycleExceeded, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{}))
+
+	fakeClock.Advance(time.Second)
+
+	assert.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{}))
+}
+
+func TestTransmitterDutyCycle_Delay(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	limiter := newTestDutyCycleLimiter(fakeClock, DutyCycleDelay)
+
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmissionCount(5), TransmitterDutyCycle(limiter))
+	tx.delay = func(time.Duration) {}
+	defer tx.Close()
+
+	done := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+
+	// The fifth repetition has to wait until the first ones leave the
+	// window.
+	fakeClock.BlockUntil(1)
+
+	select {
+	case <-done:
+		t.Fatal("expected transmission to be delayed")
+	default:
+	}
+
+	for i := 0; i < 10; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(maxDutyCycleSleep)
+	}
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout exceeded waiting for transmission")
+	}
+}
+
+func TestTransmitterDutyCycle_DelayCancel(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	limiter := newTestDutyCycleLimiter(fakeClock, DutyCycleDelay)
+
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmissionCount(5), TransmitterDutyCycle(limiter))
+	tx.delay = func(time.Duration) {}
+
+	done := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+
+	fakeClock.BlockUntil(1)
+
+	go func() {
+		require.Eventually(t, func() bool {
+			tx.mu.Lock()
+			defer tx.mu.Unlock()
+			return tx.closed
+		}, time.Second, time.Millisecond)
+		fakeClock.Advance(maxDutyCycleSleep)
+	}()
+
+	require.NoError(t, tx.Close())
+	assert.Equal(t, ErrTransmitterClosed, <-done)
+}
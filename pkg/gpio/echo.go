@@ -0,0 +1,117 @@
+package gpio
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// DefaultEchoWindow is the default duration after the end of a transmission
+// in which the same code received by a receiver is considered an echo.
+const DefaultEchoWindow = time.Second
+
+// EchoLog records which codes are sent out by transmitters and when. If a
+// receiver is mounted near a transmitter, it picks up the transmitter's own
+// output. The EchoLog allows consumers of the receiver to tell these echoes
+// apart from codes sent by other devices, e.g. remote controls. An EchoLog
+// can be shared by multiple transmitters.
+type EchoLog struct {
+	window    time.Duration
+	protocols []Protocol
+	clock     clockwork.Clock
+
+	mu      sync.Mutex
+	entries []*echoEntry
+}
+
+type echoEntry struct {
+	code      uint64
+	protocol  Protocol
+	bitLength uint
+	start     time.Time
+	// end is zero while the transmission is in flight.
+	end time.Time
+}
+
+// NewEchoLog creates a new *EchoLog which considers codes as echoes while
+// they are being transmitted and for window after the transmission ended.
+// The protocols must be the protocols the receivers were configured with, as
+// they are needed to resolve the protocol of received codes.
+func NewEchoLog(window time.Duration, protocols []Protocol) *EchoLog {
+	return &EchoLog{
+		window:    window,
+		protocols: protocols,
+		clock:     clockwork.NewRealClock(),
+	}
+}
+
+// IsEcho returns true if the code of result was being transmitted using the
+// same protocol and bit length at time at or if the transmission ended less
+// than the echo window before at.
+func (l *EchoLog) IsEcho(result ReceiveResult, at time.Time) bool {
+	if result.Protocol < 1 || result.Protocol > len(l.protocols) {
+		return false
+	}
+
+	protocol := l.protocols[result.Protocol-1]
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, e := range l.entries {
+		if e.code != result.Code || e.protocol != protocol || e.bitLength != result.BitLength || at.Before(e.start) {
+			continue
+		}
+
+		if e.end.IsZero() || !at.After(e.end.Add(l.window)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// begin records the start of a transmission of code using protocol and
+// bitLength. The returned entry must be passed to end once the transmission
+// is done.
+func (l *EchoLog) begin(code uint64, protocol Protocol, bitLength uint) *echoEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune()
+
+	e := &echoEntry{
+		code:      code,
+		protocol:  protocol,
+		bitLength: bitLength,
+		start:     l.clock.Now(),
+	}
+
+	l.entries = append(l.entries, e)
+
+	return e
+}
+
+// end records the end of the transmission of e.
+func (l *EchoLog) end(e *echoEntry) {
+	l.mu.Lock()
+	e.end = l.clock.Now()
+	l.mu.Unlock()
+}
+
+// prune removes entries of transmissions that ended more than the echo
+// window ago. Must be called with l.mu held.
+func (l *EchoLog) prune() {
+	cutoff := l.clock.Now().Add(-l.window)
+
+	entries := l.entries[:0]
+
+	for _, e := range l.entries {
+		if e.end.IsZero() || e.end.After(cutoff) {
+			entries = append(entries, e)
+		}
+	}
+
+	l.entries = entries
+}
@@ -0,0 +1,77 @@
+package gpio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEchoLog(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+	l := NewEchoLog(time.Second, DefaultProtocols)
+	l.clock = fakeClock
+
+	start := fakeClock.Now()
+
+	e := l.begin(123, DefaultProtocols[0], BitLength)
+
+	fakeClock.Advance(100 * time.Millisecond)
+
+	echo := ReceiveResult{Code: 123, Protocol: 1, BitLength: BitLength}
+
+	// In-flight transmissions.
+	assert.True(t, l.IsEcho(echo, fakeClock.Now()))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 456, Protocol: 1, BitLength: BitLength}, fakeClock.Now()))
+	assert.False(t, l.IsEcho(echo, start.Add(-time.Millisecond)))
+
+	fakeClock.Advance(100 * time.Millisecond)
+	l.end(e)
+
+	// Within the echo window after the transmission ended.
+	assert.True(t, l.IsEcho(echo, fakeClock.Now().Add(time.Second)))
+	assert.False(t, l.IsEcho(echo, fakeClock.Now().Add(time.Second+time.Millisecond)))
+
+	fakeClock.Advance(2 * time.Second)
+
+	l.begin(456, DefaultProtocols[0], BitLength)
+
+	// Expired entries are pruned.
+	assert.Len(t, l.entries, 1)
+}
+
+func TestEchoLogProtocolAndBitLength(t *testing.T) {
+	l := NewEchoLog(time.Second, DefaultProtocols)
+
+	l.begin(123, DefaultProtocols[1], BitLength)
+
+	now := time.Now()
+
+	assert.True(t, l.IsEcho(ReceiveResult{Code: 123, Protocol: 2, BitLength: BitLength}, now))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 123, Protocol: 1, BitLength: BitLength}, now))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 123, Protocol: 2, BitLength: 32}, now))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 123, Protocol: 0, BitLength: BitLength}, now))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 123, Protocol: 6, BitLength: BitLength}, now))
+}
+
+func TestTransmitterEchoLog(t *testing.T) {
+	l := NewEchoLog(time.Minute, DefaultProtocols)
+
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmitterEchoLog(l))
+	defer tx.Close()
+
+	require.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{}))
+
+	rec := &Recording{Pulses: []time.Duration{time.Millisecond}}
+
+	require.NoError(t, <-tx.TransmitRecording(context.Background(), rec, TransmitOptions{}))
+
+	assert.True(t, l.IsEcho(ReceiveResult{Code: 0x1, Protocol: 1, BitLength: BitLength}, time.Now()))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 0x1, Protocol: 2, BitLength: BitLength}, time.Now()))
+	assert.False(t, l.IsEcho(ReceiveResult{Code: 0x2, Protocol: 1, BitLength: BitLength}, time.Now()))
+	assert.Len(t, l.entries, 1)
+}
@@ -0,0 +1,67 @@
+package gpio
+
+import "sync"
+
+// FanOut distributes the results of a single CodeReceiver to multiple
+// consumers, e.g. the state drift detector and the trigger handler, which
+// each get their own copy of every result.
+type FanOut struct {
+	receiver  CodeReceiver
+	outputs   []chan ReceiveResult
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewFanOut creates a new *FanOut which distributes the results of receiver
+// to n consumers. The consumers are obtained via Receiver. A slow consumer
+// blocks the delivery of results to all others.
+func NewFanOut(receiver CodeReceiver, n int) *FanOut {
+	f := &FanOut{
+		receiver: receiver,
+		outputs:  make([]chan ReceiveResult, n),
+	}
+
+	for i := range f.outputs {
+		f.outputs[i] = make(chan ReceiveResult, receiveResultChanLen)
+	}
+
+	go func() {
+		for result := range receiver.Receive() {
+			for _, output := range f.outputs {
+				output <- result
+			}
+		}
+
+		for _, output := range f.outputs {
+			close(output)
+		}
+	}()
+
+	return f
+}
+
+// Receiver returns the i-th consumer of f. Closing any of the consumers
+// closes the underlying receiver.
+func (f *FanOut) Receiver(i int) CodeReceiver {
+	return fanOutReceiver{f, f.outputs[i]}
+}
+
+// Close closes the underlying receiver. It is safe to call Close multiple
+// times.
+func (f *FanOut) Close() error {
+	f.closeOnce.Do(func() {
+		f.closeErr = f.receiver.Close()
+	})
+
+	return f.closeErr
+}
+
+type fanOutReceiver struct {
+	*FanOut
+	result chan ReceiveResult
+}
+
+// Receive implements CodeReceiver.
+func (r fanOutReceiver) Receive() <-chan ReceiveResult {
+	return r.result
+}
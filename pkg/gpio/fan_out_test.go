@@ -0,0 +1,33 @@
+package gpio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanOut(t *testing.T) {
+	r := newFakeCodeReceiver(errors.New("whoops"))
+
+	f := NewFanOut(r, 2)
+
+	go func() {
+		r.results <- ReceiveResult{Code: 1}
+		r.results <- ReceiveResult{Code: 2}
+		f.Receiver(1).Close()
+	}()
+
+	for i := 0; i < 2; i++ {
+		var codes []uint64
+
+		for result := range f.Receiver(i).Receive() {
+			codes = append(codes, result.Code)
+		}
+
+		assert.Equal(t, []uint64{1, 2}, codes)
+	}
+
+	assert.True(t, r.closed)
+	assert.EqualError(t, f.Close(), "whoops")
+}
@@ -1,6 +1,11 @@
 package gpio
 
-import "github.com/warthog618/gpiod"
+import (
+	"context"
+	"time"
+
+	"github.com/warthog618/gpiod"
+)
 
 // Closer is something that can be closed.
 type Closer interface {
@@ -28,10 +33,17 @@ type CodeTransmitter interface {
 	Closer
 	// Transmit transmits a code using given protocol and pulse length.
 	//
-	// This method returns immediately. The code is transmitted in the background.
-	// If you need to ensure that a code has been fully transmitted, wait for the
-	// returned channel to be closed.
-	Transmit(code uint64, protocol Protocol, pulseLength uint) <-chan struct{}
+	// This method never blocks. The code is transmitted in the background.
+	// The returned channel receives an error if the transmission failed or
+	// was rejected, cancelled via ctx or superseded. It is closed once the
+	// transmission is done.
+	Transmit(ctx context.Context, code uint64, protocol Protocol, pulseLength uint, opts TransmitOptions) <-chan error
+
+	// TransmitRecording transmits the pulses of a raw recording verbatim.
+	//
+	// This method never blocks. The returned channel behaves like the one
+	// returned by Transmit.
+	TransmitRecording(ctx context.Context, rec *Recording, opts TransmitOptions) <-chan error
 }
 
 // CodeReceiver defines the interface for a rf code receiver.
@@ -55,6 +67,22 @@ type ReceiveResult struct {
 
 	// Protocol is the detected protocol. The protocol is 1-indexed.
 	Protocol int
+
+	// RepeatCount is the number of identical frames that were merged into
+	// this result. It is always 1 if frame de-duplication is disabled.
+	RepeatCount int
+
+	// FirstSeen is the time the first of the merged frames was received.
+	FirstSeen time.Time
+
+	// LastSeen is the time the last of the merged frames was received.
+	LastSeen time.Time
+}
+
+// matches returns true if other has the same code, protocol and bit length as
+// r.
+func (r ReceiveResult) matches(other ReceiveResult) bool {
+	return r.Code == other.Code && r.Protocol == other.Protocol && r.BitLength == other.BitLength
 }
 
 // FakeWatcher can be used in tests as a Watcher.
@@ -94,7 +122,7 @@ type FakeOutputPin struct {
 	// Values holds the sequence of values the were set via SetValue.
 	Values []int
 
-	// Err controls the error returned by Close.
+	// Err controls the error returned by SetValue and Close.
 	Err error
 
 	// Closed indicates whether Close was called or not.
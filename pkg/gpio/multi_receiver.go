@@ -0,0 +1,60 @@
+package gpio
+
+import "sync"
+
+// MultiReceiver merges the results of multiple CodeReceivers into a single
+// channel. This allows to listen on multiple radio modules at once, e.g. for
+// different frequencies.
+type MultiReceiver struct {
+	receivers []CodeReceiver
+	result    chan ReceiveResult
+}
+
+// NewMultiReceiver creates a new *MultiReceiver which receives results from
+// all receivers. The result channel is closed once all receivers are closed.
+func NewMultiReceiver(receivers ...CodeReceiver) *MultiReceiver {
+	r := &MultiReceiver{
+		receivers: receivers,
+		result:    make(chan ReceiveResult, receiveResultChanLen),
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(receivers))
+
+	for _, receiver := range receivers {
+		go func(receiver CodeReceiver) {
+			defer wg.Done()
+
+			for result := range receiver.Receive() {
+				r.result <- result
+			}
+		}(receiver)
+	}
+
+	go func() {
+		wg.Wait()
+		close(r.result)
+	}()
+
+	return r
+}
+
+// Receive implements CodeReceiver.
+func (r *MultiReceiver) Receive() <-chan ReceiveResult {
+	return r.result
+}
+
+// Close implements Closer. It closes all receivers and returns the first
+// error encountered.
+func (r *MultiReceiver) Close() error {
+	var firstErr error
+
+	for _, receiver := range r.receivers {
+		if err := receiver.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
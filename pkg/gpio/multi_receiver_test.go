@@ -0,0 +1,72 @@
+package gpio
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCodeReceiver struct {
+	results chan ReceiveResult
+	err     error
+	closed  bool
+}
+
+func newFakeCodeReceiver(err error) *fakeCodeReceiver {
+	return &fakeCodeReceiver{results: make(chan ReceiveResult), err: err}
+}
+
+func (r *fakeCodeReceiver) Receive() <-chan ReceiveResult {
+	return r.results
+}
+
+func (r *fakeCodeReceiver) Close() error {
+	if !r.closed {
+		r.closed = true
+		close(r.results)
+	}
+
+	return r.err
+}
+
+func TestMultiReceiver(t *testing.T) {
+	r1 := newFakeCodeReceiver(nil)
+	r2 := newFakeCodeReceiver(nil)
+
+	rx := NewMultiReceiver(r1, r2)
+
+	go func() {
+		r1.results <- ReceiveResult{Code: 1}
+		r2.results <- ReceiveResult{Code: 2}
+		r1.results <- ReceiveResult{Code: 3}
+		rx.Close()
+	}()
+
+	var codes []int
+
+	for result := range rx.Receive() {
+		codes = append(codes, int(result.Code))
+	}
+
+	sort.Ints(codes)
+
+	assert.Equal(t, []int{1, 2, 3}, codes)
+	assert.True(t, r1.closed)
+	assert.True(t, r2.closed)
+}
+
+func TestMultiReceiverClose(t *testing.T) {
+	r1 := newFakeCodeReceiver(nil)
+	r2 := newFakeCodeReceiver(errors.New("whoops"))
+	r3 := newFakeCodeReceiver(errors.New("again"))
+
+	rx := NewMultiReceiver(r1, r2, r3)
+
+	assert.EqualError(t, rx.Close(), "whoops")
+	assert.True(t, r3.closed)
+
+	_, ok := <-rx.Receive()
+	assert.False(t, ok)
+}
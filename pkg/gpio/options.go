@@ -1,5 +1,11 @@
 package gpio
 
+import (
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
 // ReceiverOption is the signature of funcs that are used to configure a
 // *Receiver.
 type ReceiverOption func(*Receiver)
@@ -12,6 +18,79 @@ func ReceiverProtocols(protocols []Protocol) ReceiverOption {
 	}
 }
 
+// ReceiverTolerance configures the tolerance in percent for pulse lengths to
+// still match a protocol. Noisy receiver modules may need a higher tolerance,
+// while a lower tolerance reduces the number of false positives.
+func ReceiverTolerance(tolerance int64) ReceiverOption {
+	return func(r *Receiver) {
+		r.receiveTolerance = tolerance
+	}
+}
+
+// ReceiverSeparationLimit configures the minimum duration of a sync gap in
+// microseconds that separates two frames.
+func ReceiverSeparationLimit(limit int64) ReceiverOption {
+	return func(r *Receiver) {
+		r.separationLimit = limit
+	}
+}
+
+// ReceiverMaxChanges configures the number of level changes that can be
+// buffered for a single frame. Frames of n bits require 2*n+2 changes. Since
+// codes are decoded into an uint64, maxChanges must not exceed
+// MaxChangesLimit.
+func ReceiverMaxChanges(maxChanges uint) ReceiverOption {
+	return func(r *Receiver) {
+		r.maxChanges = maxChanges
+	}
+}
+
+// ReceiverRepeatCount configures the number of sync gaps of the same length
+// that have to be observed before a frame is decoded.
+func ReceiverRepeatCount(count uint) ReceiverOption {
+	return func(r *Receiver) {
+		r.requiredRepeatCount = count
+	}
+}
+
+// ReceiverMinPulseWidth configures the minimum width of a pulse in
+// microseconds. Shorter pulses are considered to be noise and are discarded
+// before decoding. Zero disables the glitch filter.
+func ReceiverMinPulseWidth(width int64) ReceiverOption {
+	return func(r *Receiver) {
+		r.minPulseWidth = width
+	}
+}
+
+// ReceiverDedupWindow configures the window in which consecutive identical
+// frames are merged into a single ReceiveResult. The result is emitted once
+// no identical frame was received for the duration of the window. Zero
+// disables de-duplication.
+func ReceiverDedupWindow(window time.Duration) ReceiverOption {
+	return func(r *Receiver) {
+		r.dedupWindow = window
+	}
+}
+
+// ReceiverUnmatchedFrames configures whether the receiver should report frames
+// that do not match any of its protocols. If enabled, the raw timings of these
+// frames are sent to the channel returned by (*Receiver).Unmatched. They can
+// be used to infer unknown protocols via Analyze.
+func ReceiverUnmatchedFrames(enabled bool) ReceiverOption {
+	return func(r *Receiver) {
+		r.reportUnmatched = enabled
+	}
+}
+
+// ReceiverClock configures the clock that is used to timestamp results and to
+// detect the end of the de-duplication window. If not set, the real clock is
+// used.
+func ReceiverClock(clock clockwork.Clock) ReceiverOption {
+	return func(r *Receiver) {
+		r.clock = clock
+	}
+}
+
 // TransmitterOption is the signature of funcs that are used to configure a
 // *Transmitter.
 type TransmitterOption func(*Transmitter)
@@ -24,3 +103,53 @@ func TransmissionCount(count int) TransmitterOption {
 		t.transmissionCount = count
 	}
 }
+
+// TransmitterLockOSThread configures whether the goroutine sending out the
+// codes should be locked to its own OS thread. This prevents it from being
+// moved between threads in the middle of a transmission.
+func TransmitterLockOSThread(enabled bool) TransmitterOption {
+	return func(t *Transmitter) {
+		t.lockOSThread = enabled
+	}
+}
+
+// TransmitterRealtimePriority configures the real-time scheduling priority
+// (1-99) that is requested for the OS thread sending out the codes. This
+// implies TransmitterLockOSThread. Requesting real-time scheduling is only
+// supported on linux and usually requires root privileges. If it fails, the
+// transmitter still works and the error is available via
+// (*Transmitter).RealtimeError. Zero disables real-time scheduling.
+func TransmitterRealtimePriority(priority int) TransmitterOption {
+	return func(t *Transmitter) {
+		t.realtimePriority = priority
+	}
+}
+
+// TransmitterTimingStats configures a handler which is called with the timing
+// statistics of every transmission once it is done. The handler is called
+// from the goroutine sending out the codes and should return quickly.
+func TransmitterTimingStats(handler func(TimingStats)) TransmitterOption {
+	return func(t *Transmitter) {
+		t.timingStats = handler
+	}
+}
+
+// TransmitterDutyCycle configures a limiter for the airtime of the
+// transmitter. Transmissions exceeding the limiter's budget are either
+// delayed or rejected with ErrDutyCycleExceeded, depending on the limiter's
+// mode. A nil limiter disables duty-cycle limiting.
+func TransmitterDutyCycle(limiter *DutyCycleLimiter) TransmitterOption {
+	return func(t *Transmitter) {
+		t.dutyCycle = limiter
+	}
+}
+
+// TransmitterEchoLog configures an *EchoLog in which the transmitter records
+// the codes it sends out, so that receivers can ignore them. The log can be
+// shared by multiple transmitters. Raw recordings are not recorded since
+// their codes are unknown.
+func TransmitterEchoLog(log *EchoLog) TransmitterOption {
+	return func(t *Transmitter) {
+		t.echoLog = log
+	}
+}
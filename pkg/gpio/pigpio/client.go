@@ -0,0 +1,163 @@
+// Package pigpio provides a gpio.Backend which talks to a pigpio daemon
+// (pigpiod) via its socket interface. This allows to control the pins of a
+// Raspberry PI without access to the gpio character device, e.g. from within
+// a container. Codes are sent out as hardware-timed waveforms instead of
+// busy-waiting.
+//
+// See http://abyz.me.uk/rpi/pigpio/sif.html for the socket interface.
+package pigpio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+)
+
+// DefaultAddress is the default address of the pigpio daemon.
+const DefaultAddress = "localhost:8888"
+
+// Command numbers of the pigpio socket interface.
+const (
+	cmdMODES = 0
+	cmdREAD  = 3
+	cmdWRITE = 4
+	cmdNB    = 19
+	cmdNC    = 21
+	cmdWVAG  = 28
+	cmdWVBSY = 32
+	cmdWVCRE = 49
+	cmdWVDEL = 50
+	cmdWVTX  = 51
+	cmdWVNEW = 53
+	cmdNOIB  = 99
+)
+
+// Pin modes.
+const (
+	modeInput  = 0
+	modeOutput = 1
+)
+
+const (
+	// headerLen is the length of command requests and responses.
+	headerLen = 16
+
+	// defaultPollInterval is the interval in which the daemon is polled to
+	// check whether a waveform is still being transmitted.
+	defaultPollInterval = time.Millisecond
+)
+
+// Error is returned if the pigpio daemon responds with an error code.
+type Error struct {
+	// Command is the command that failed.
+	Command uint32
+
+	// Code is the negative error code returned by the daemon.
+	Code int32
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("pigpio: command %d failed with error code %d", e.Command, e.Code)
+}
+
+// Client is a client for the socket interface of the pigpio daemon. It
+// implements gpio.Backend, pin offsets are the Broadcom gpio numbers.
+type Client struct {
+	addr string
+
+	mu   sync.Mutex
+	conn net.Conn
+
+	// waveMu serializes the creation and transmission of waveforms as the
+	// daemon only supports building one waveform at a time.
+	waveMu sync.Mutex
+
+	// pollInterval and sleep can be replaced in tests.
+	pollInterval time.Duration
+	sleep        func(time.Duration)
+}
+
+var _ gpio.Backend = (*Client)(nil)
+
+// Dial connects to the pigpio daemon listening on addr.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to pigpiod at %s: %v", addr, err)
+	}
+
+	return &Client{
+		addr:         addr,
+		conn:         conn,
+		pollInterval: defaultPollInterval,
+		sleep:        time.Sleep,
+	}, nil
+}
+
+// OutputPin implements gpio.Backend. The returned pin implements
+// gpio.PulseSender.
+func (c *Client) OutputPin(offset int) (gpio.OutputPin, error) {
+	pin := &outputPin{client: c, gpio: uint32(offset)}
+
+	if _, err := c.command(cmdMODES, pin.gpio, modeOutput, nil); err != nil {
+		return nil, err
+	}
+
+	if err := pin.SetValue(0); err != nil {
+		return nil, err
+	}
+
+	return pin, nil
+}
+
+// Watcher implements gpio.Backend.
+func (c *Client) Watcher(offset int) (gpio.Watcher, error) {
+	return newWatcher(c, uint32(offset))
+}
+
+// Close implements gpio.Closer.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// command sends cmd with parameters p1 and p2 and optional extension data to
+// the daemon and returns the result. Returns an *Error if the result is
+// negative.
+func (c *Client) command(cmd, p1, p2 uint32, ext []byte) (uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return command(c.conn, cmd, p1, p2, ext)
+}
+
+// command sends a command on conn and reads the response.
+func command(conn net.Conn, cmd, p1, p2 uint32, ext []byte) (uint32, error) {
+	buf := make([]byte, headerLen+len(ext))
+
+	binary.LittleEndian.PutUint32(buf[0:], cmd)
+	binary.LittleEndian.PutUint32(buf[4:], p1)
+	binary.LittleEndian.PutUint32(buf[8:], p2)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(ext)))
+	copy(buf[headerLen:], ext)
+
+	if _, err := conn.Write(buf); err != nil {
+		return 0, fmt.Errorf("pigpio: failed to send command %d: %v", cmd, err)
+	}
+
+	if _, err := io.ReadFull(conn, buf[:headerLen]); err != nil {
+		return 0, fmt.Errorf("pigpio: failed to read response to command %d: %v", cmd, err)
+	}
+
+	res := binary.LittleEndian.Uint32(buf[12:])
+	if int32(res) < 0 {
+		return 0, &Error{Command: cmd, Code: int32(res)}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,338 @@
+package pigpio
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/martinohmann/rfoutlet/pkg/gpio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/gpiod"
+)
+
+type request struct {
+	cmd, p1, p2 uint32
+	ext         []byte
+}
+
+// fakeDaemon is a minimal fake of the pigpio daemon's socket interface. It
+// records all requests and responds with configurable results.
+type fakeDaemon struct {
+	ln     net.Listener
+	notify chan net.Conn
+
+	mu       sync.Mutex
+	requests []request
+	results  map[uint32]int32
+}
+
+func newFakeDaemon(t *testing.T) *fakeDaemon {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	d := &fakeDaemon{
+		ln:      ln,
+		notify:  make(chan net.Conn, 1),
+		results: map[uint32]int32{cmdWVCRE: 7, cmdNOIB: 5},
+	}
+
+	go d.accept()
+
+	t.Cleanup(func() { ln.Close() })
+
+	return d
+}
+
+func (d *fakeDaemon) addr() string {
+	return d.ln.Addr().String()
+}
+
+func (d *fakeDaemon) setResult(cmd uint32, res int32) {
+	d.mu.Lock()
+	d.results[cmd] = res
+	d.mu.Unlock()
+}
+
+func (d *fakeDaemon) accept() {
+	for {
+		conn, err := d.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go d.serve(conn)
+	}
+}
+
+func (d *fakeDaemon) serve(conn net.Conn) {
+	var header [headerLen]byte
+
+	for {
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			conn.Close()
+			return
+		}
+
+		req := request{
+			cmd: binary.LittleEndian.Uint32(header[0:]),
+			p1:  binary.LittleEndian.Uint32(header[4:]),
+			p2:  binary.LittleEndian.Uint32(header[8:]),
+			ext: make([]byte, binary.LittleEndian.Uint32(header[12:])),
+		}
+
+		if _, err := io.ReadFull(conn, req.ext); err != nil {
+			conn.Close()
+			return
+		}
+
+		d.mu.Lock()
+		d.requests = append(d.requests, req)
+		res := d.results[req.cmd]
+		d.mu.Unlock()
+
+		binary.LittleEndian.PutUint32(header[12:], uint32(res))
+
+		if _, err := conn.Write(header[:]); err != nil {
+			conn.Close()
+			return
+		}
+
+		if req.cmd == cmdNOIB {
+			// The connection is used for notification reports from now on.
+			d.notify <- conn
+			return
+		}
+	}
+}
+
+func (d *fakeDaemon) commands() []uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cmds := make([]uint32, len(d.requests))
+	for i, req := range d.requests {
+		cmds[i] = req.cmd
+	}
+
+	return cmds
+}
+
+func (d *fakeDaemon) requestsFor(cmd uint32) []request {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var reqs []request
+
+	for _, req := range d.requests {
+		if req.cmd == cmd {
+			reqs = append(reqs, req)
+		}
+	}
+
+	return reqs
+}
+
+type pulse struct {
+	on, off, delay uint32
+}
+
+func decodePulses(ext []byte) []pulse {
+	pulses := make([]pulse, len(ext)/pulseLen)
+
+	for i := range pulses {
+		buf := ext[i*pulseLen:]
+		pulses[i] = pulse{
+			on:    binary.LittleEndian.Uint32(buf[0:]),
+			off:   binary.LittleEndian.Uint32(buf[4:]),
+			delay: binary.LittleEndian.Uint32(buf[8:]),
+		}
+	}
+
+	return pulses
+}
+
+func dial(t *testing.T, d *fakeDaemon) *Client {
+	c, err := Dial(d.addr())
+	require.NoError(t, err)
+
+	c.sleep = func(time.Duration) {}
+
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestDial_Error(t *testing.T) {
+	d := newFakeDaemon(t)
+	addr := d.addr()
+	d.ln.Close()
+
+	_, err := Dial(addr)
+	assert.Error(t, err)
+}
+
+func TestClient_OutputPin(t *testing.T) {
+	d := newFakeDaemon(t)
+	c := dial(t, d)
+
+	pin, err := c.OutputPin(17)
+	require.NoError(t, err)
+
+	require.NoError(t, pin.SetValue(1))
+	require.NoError(t, pin.Close())
+
+	assert.Equal(t, []uint32{cmdMODES, cmdWRITE, cmdWRITE, cmdWRITE}, d.commands())
+
+	writes := d.requestsFor(cmdWRITE)
+	assert.Equal(t, uint32(17), writes[1].p1)
+	assert.Equal(t, uint32(1), writes[1].p2)
+	assert.Equal(t, uint32(0), writes[2].p2)
+}
+
+func TestClient_Error(t *testing.T) {
+	d := newFakeDaemon(t)
+	d.setResult(cmdMODES, -3)
+
+	c := dial(t, d)
+
+	_, err := c.OutputPin(17)
+	assert.EqualError(t, err, "pigpio: command 0 failed with error code -3")
+}
+
+func TestOutputPin_SendPulses(t *testing.T) {
+	d := newFakeDaemon(t)
+	c := dial(t, d)
+
+	pin, err := c.OutputPin(17)
+	require.NoError(t, err)
+
+	err = pin.(gpio.PulseSender).SendPulses([]time.Duration{
+		350 * time.Microsecond,
+		1050 * time.Microsecond,
+		350 * time.Microsecond,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []uint32{
+		cmdMODES, cmdWRITE, cmdWVNEW, cmdWVAG, cmdWVCRE, cmdWVTX, cmdWVBSY, cmdWVDEL,
+	}, d.commands())
+
+	mask := uint32(1 << 17)
+
+	assert.Equal(t, []pulse{
+		{mask, 0, 350},
+		{0, mask, 1050},
+		{mask, 0, 350},
+		{0, mask, 0},
+	}, decodePulses(d.requestsFor(cmdWVAG)[0].ext))
+
+	assert.Equal(t, uint32(7), d.requestsFor(cmdWVTX)[0].p1)
+	assert.Equal(t, uint32(7), d.requestsFor(cmdWVDEL)[0].p1)
+}
+
+func TestOutputPin_SendPulses_Chunks(t *testing.T) {
+	d := newFakeDaemon(t)
+	c := dial(t, d)
+
+	pin, err := c.OutputPin(17)
+	require.NoError(t, err)
+
+	pulses := make([]time.Duration, 2*maxPulsesPerCommand+2)
+	for i := range pulses {
+		pulses[i] = 100 * time.Microsecond
+	}
+
+	require.NoError(t, pin.(gpio.PulseSender).SendPulses(pulses))
+
+	reqs := d.requestsFor(cmdWVAG)
+	require.Len(t, reqs, 3)
+
+	first := decodePulses(reqs[0].ext)
+	second := decodePulses(reqs[1].ext)
+	third := decodePulses(reqs[2].ext)
+
+	require.Len(t, first, maxPulsesPerCommand)
+	require.Len(t, second, maxPulsesPerCommand+1)
+	require.Len(t, third, 3)
+
+	// Subsequent chunks are delayed by the duration of the previous ones.
+	assert.Equal(t, pulse{0, 0, 100000}, second[0])
+	assert.Equal(t, pulse{0, 0, 200000}, third[0])
+}
+
+func TestClient_Watcher(t *testing.T) {
+	d := newFakeDaemon(t)
+	c := dial(t, d)
+
+	w, err := c.Watcher(27)
+	require.NoError(t, err)
+
+	conn := <-d.notify
+
+	nb := d.requestsFor(cmdNB)
+	require.Len(t, nb, 1)
+	assert.Equal(t, uint32(5), nb[0].p1)
+	assert.Equal(t, uint32(1<<27), nb[0].p2)
+
+	report := func(flags uint16, tick, level uint32) {
+		var buf [reportLen]byte
+		binary.LittleEndian.PutUint16(buf[2:], flags)
+		binary.LittleEndian.PutUint32(buf[4:], tick)
+		binary.LittleEndian.PutUint32(buf[8:], level)
+		_, err := conn.Write(buf[:])
+		require.NoError(t, err)
+	}
+
+	go func() {
+		report(0, 0xfffffff0, 1<<27)
+		report(1<<6, 0xfffffff8, 0)
+		report(0, 0x00000150, 0)
+		report(0, 0x00000200, 1<<4)
+		report(0, 0x00000300, 1<<27)
+	}()
+
+	var events []gpiod.LineEvent
+
+	for i := 0; i < 3; i++ {
+		select {
+		case evt := <-w.Watch():
+			events = append(events, evt)
+		case <-time.After(time.Second):
+			t.Fatal("timeout exceeded waiting for event")
+		}
+	}
+
+	assert.Equal(t, []gpiod.LineEvent{
+		{Offset: 27, Timestamp: 0, Type: gpiod.LineEventRisingEdge},
+		{Offset: 27, Timestamp: 0x160 * time.Microsecond, Type: gpiod.LineEventFallingEdge},
+		{Offset: 27, Timestamp: 0x310 * time.Microsecond, Type: gpiod.LineEventRisingEdge},
+	}, events)
+
+	require.NoError(t, w.Close())
+
+	_, ok := <-w.Watch()
+	assert.False(t, ok)
+
+	assert.Len(t, d.requestsFor(cmdNC), 1)
+}
+
+func TestTransmitter(t *testing.T) {
+	d := newFakeDaemon(t)
+	c := dial(t, d)
+
+	pin, err := c.OutputPin(17)
+	require.NoError(t, err)
+
+	tx := gpio.NewPinTransmitter(pin, gpio.TransmissionCount(3))
+	defer tx.Close()
+
+	require.NoError(t, <-tx.Transmit(context.Background(), 0x1, gpio.DefaultProtocols[0], 190, gpio.TransmitOptions{}))
+
+	assert.Len(t, d.requestsFor(cmdWVTX), 3)
+	assert.Len(t, d.requestsFor(cmdWVDEL), 3)
+}
@@ -0,0 +1,132 @@
+package pigpio
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	// maxPulsesPerCommand is the maximum number of pulses that are added to
+	// a waveform with a single command to stay below the daemon's limit for
+	// the size of command extensions.
+	maxPulsesPerCommand = 1000
+
+	// pulseLen is the encoded length of a single pulse.
+	pulseLen = 12
+)
+
+type outputPin struct {
+	client *Client
+	gpio   uint32
+}
+
+// SetValue implements gpio.OutputPin.
+func (p *outputPin) SetValue(value int) error {
+	_, err := p.client.command(cmdWRITE, p.gpio, uint32(value), nil)
+	return err
+}
+
+// Close implements gpio.Closer. The pin is left in low state, the connection
+// to the daemon is not closed.
+func (p *outputPin) Close() error {
+	return p.SetValue(0)
+}
+
+// SendPulses implements gpio.PulseSender. The pulses are sent out as a
+// hardware-timed waveform.
+func (p *outputPin) SendPulses(pulses []time.Duration) error {
+	c := p.client
+
+	c.waveMu.Lock()
+	defer c.waveMu.Unlock()
+
+	if _, err := c.command(cmdWVNEW, 0, 0, nil); err != nil {
+		return err
+	}
+
+	var offset time.Duration
+
+	for start := 0; start < len(pulses); start += maxPulsesPerCommand {
+		end := start + maxPulsesPerCommand
+		if end > len(pulses) {
+			end = len(pulses)
+		}
+
+		ext := p.encodePulses(pulses, start, end, offset)
+
+		if _, err := c.command(cmdWVAG, 0, 0, ext); err != nil {
+			return err
+		}
+
+		for _, pulse := range pulses[start:end] {
+			offset += pulse
+		}
+	}
+
+	waveID, err := c.command(cmdWVCRE, 0, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	defer c.command(cmdWVDEL, waveID, 0, nil)
+
+	if _, err := c.command(cmdWVTX, waveID, 0, nil); err != nil {
+		return err
+	}
+
+	c.sleep(offset)
+
+	for {
+		busy, err := c.command(cmdWVBSY, 0, 0, nil)
+		if err != nil {
+			return err
+		}
+
+		if busy == 0 {
+			return nil
+		}
+
+		c.sleep(c.pollInterval)
+	}
+}
+
+// encodePulses encodes pulses[start:end] as extension of the WVAG command.
+// Pulses are merged into the current waveform by the daemon, so if offset is
+// non-zero, a leading pulse is added that delays the pulses by offset. If end
+// is the last pulse and the last pulse is high, a trailing pulse is added
+// that brings the pin into low state.
+func (p *outputPin) encodePulses(pulses []time.Duration, start, end int, offset time.Duration) []byte {
+	mask := uint32(1) << p.gpio
+
+	ext := make([]byte, 0, (end-start+2)*pulseLen)
+
+	if offset > 0 {
+		ext = appendPulse(ext, 0, 0, offset)
+	}
+
+	for i := start; i < end; i++ {
+		if i%2 == 0 {
+			ext = appendPulse(ext, mask, 0, pulses[i])
+		} else {
+			ext = appendPulse(ext, 0, mask, pulses[i])
+		}
+	}
+
+	if end == len(pulses) && len(pulses)%2 == 1 {
+		ext = appendPulse(ext, 0, mask, 0)
+	}
+
+	return ext
+}
+
+// appendPulse appends a pulse which switches on the gpios in on and switches
+// off the gpios in off and then waits for delay.
+func appendPulse(buf []byte, on, off uint32, delay time.Duration) []byte {
+	var pulse [pulseLen]byte
+
+	binary.LittleEndian.PutUint32(pulse[0:], on)
+	binary.LittleEndian.PutUint32(pulse[4:], off)
+	binary.LittleEndian.PutUint32(pulse[8:], uint32(delay/time.Microsecond))
+
+	return append(buf, pulse[:]...)
+}
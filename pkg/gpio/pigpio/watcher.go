@@ -0,0 +1,149 @@
+package pigpio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/warthog618/gpiod"
+)
+
+// reportLen is the length of a notification report.
+const reportLen = 12
+
+type watcher struct {
+	client *Client
+	gpio   uint32
+	conn   net.Conn
+	handle uint32
+	events chan gpiod.LineEvent
+	once   sync.Once
+	done   chan struct{}
+}
+
+// newWatcher opens a notification channel to the daemon and starts watching
+// gpio for level changes.
+func newWatcher(c *Client, gpio uint32) (*watcher, error) {
+	if _, err := c.command(cmdMODES, gpio, modeInput, nil); err != nil {
+		return nil, err
+	}
+
+	level, err := c.command(cmdREAD, gpio, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to pigpiod at %s: %v", c.addr, err)
+	}
+
+	handle, err := command(conn, cmdNOIB, 0, 0, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	w := &watcher{
+		client: c,
+		gpio:   gpio,
+		conn:   conn,
+		handle: handle,
+		events: make(chan gpiod.LineEvent),
+		done:   make(chan struct{}),
+	}
+
+	if _, err := c.command(cmdNB, handle, 1<<gpio, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go w.watch(level != 0)
+
+	return w, nil
+}
+
+// watch reads notification reports until the notification channel is closed
+// and emits an event for each level change of the watched gpio. The 32-bit
+// microsecond ticks of the daemon wrap around after about 72 minutes, so the
+// event timestamps are accumulated from the differences between ticks.
+func (w *watcher) watch(high bool) {
+	defer close(w.events)
+
+	var (
+		buf       [reportLen]byte
+		lastTick  uint32
+		elapsed   time.Duration
+		seenFirst bool
+	)
+
+	mask := uint32(1) << w.gpio
+
+	for {
+		if _, err := io.ReadFull(w.conn, buf[:]); err != nil {
+			return
+		}
+
+		flags := binary.LittleEndian.Uint16(buf[2:])
+		tick := binary.LittleEndian.Uint32(buf[4:])
+		level := binary.LittleEndian.Uint32(buf[8:])
+
+		// Non-zero flags indicate watchdog, keep-alive or event reports
+		// which do not carry level changes.
+		if flags != 0 {
+			continue
+		}
+
+		if seenFirst {
+			elapsed += time.Duration(tick-lastTick) * time.Microsecond
+		}
+
+		lastTick, seenFirst = tick, true
+
+		isHigh := level&mask != 0
+		if isHigh == high {
+			continue
+		}
+
+		high = isHigh
+
+		evt := gpiod.LineEvent{
+			Offset:    int(w.gpio),
+			Timestamp: elapsed,
+			Type:      gpiod.LineEventFallingEdge,
+		}
+
+		if high {
+			evt.Type = gpiod.LineEventRisingEdge
+		}
+
+		select {
+		case w.events <- evt:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Watch implements gpio.Watcher.
+func (w *watcher) Watch() <-chan gpiod.LineEvent {
+	return w.events
+}
+
+// Close implements gpio.Closer.
+func (w *watcher) Close() (err error) {
+	w.once.Do(func() {
+		close(w.done)
+
+		_, err = w.client.command(cmdNC, w.handle, 0, nil)
+
+		if cerr := w.conn.Close(); err == nil {
+			err = cerr
+		}
+	})
+
+	return err
+}
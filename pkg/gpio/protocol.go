@@ -1,16 +1,24 @@
 package gpio
 
+import (
+	"errors"
+	"time"
+)
+
 // HighLow defines the number of high pulses followed by a number of low pulses
 // to send.
 type HighLow struct {
-	High, Low uint
+	High uint `json:"high"`
+	Low  uint `json:"low"`
 }
 
 // Protocol defines the HighLow sequences to send to emit ones (One) and zeros
 // (Zero) and the sync sequence (Sync) which signals the end of a code
 // transmission.
 type Protocol struct {
-	Sync, Zero, One HighLow
+	Sync HighLow `json:"sync"`
+	Zero HighLow `json:"zero"`
+	One  HighLow `json:"one"`
 }
 
 // DefaultProtocols defines known remote control protocols. These are exported
@@ -24,3 +32,50 @@ var DefaultProtocols = []Protocol{
 	{HighLow{1, 6}, HighLow{1, 3}, HighLow{3, 1}},
 	{HighLow{6, 14}, HighLow{1, 2}, HighLow{2, 1}},
 }
+
+// Validate returns an error if p cannot be received or transmitted, e.g.
+// because the sync low pulse is zero or zeros and ones are indistinguishable.
+func (p Protocol) Validate() error {
+	if p.Sync.Low == 0 {
+		return errors.New("sync.low must be greater than 0")
+	}
+
+	if p.Zero.High == 0 || p.Zero.Low == 0 {
+		return errors.New("zero.high and zero.low must be greater than 0")
+	}
+
+	if p.One.High == 0 || p.One.Low == 0 {
+		return errors.New("one.high and one.low must be greater than 0")
+	}
+
+	if p.Zero == p.One {
+		return errors.New("zero and one must differ")
+	}
+
+	return nil
+}
+
+// pulses returns the durations of the alternating high and low pulses that
+// need to be sent to transmit code with bitLength bits using pulseLength.
+func (p Protocol) pulses(code uint64, bitLength int, pulseLength uint) []time.Duration {
+	pulses := make([]time.Duration, 0, 2*bitLength+2)
+
+	appendHighLow := func(hl HighLow) {
+		pulses = append(pulses,
+			time.Microsecond*time.Duration(pulseLength*hl.High),
+			time.Microsecond*time.Duration(pulseLength*hl.Low),
+		)
+	}
+
+	for j := bitLength - 1; j >= 0; j-- {
+		if code&(1<<uint64(j)) > 0 {
+			appendHighLow(p.One)
+		} else {
+			appendHighLow(p.Zero)
+		}
+	}
+
+	appendHighLow(p.Sync)
+
+	return pulses
+}
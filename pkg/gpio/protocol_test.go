@@ -0,0 +1,46 @@
+package gpio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProtocolValidate(t *testing.T) {
+	for _, p := range DefaultProtocols {
+		assert.NoError(t, p.Validate())
+	}
+
+	tests := []struct {
+		name     string
+		protocol Protocol
+		expected string
+	}{
+		{
+			name:     "zero sync low",
+			protocol: Protocol{HighLow{1, 0}, HighLow{1, 3}, HighLow{3, 1}},
+			expected: "sync.low must be greater than 0",
+		},
+		{
+			name:     "zero high",
+			protocol: Protocol{HighLow{1, 31}, HighLow{0, 3}, HighLow{3, 1}},
+			expected: "zero.high and zero.low must be greater than 0",
+		},
+		{
+			name:     "one low",
+			protocol: Protocol{HighLow{1, 31}, HighLow{1, 3}, HighLow{3, 0}},
+			expected: "one.high and one.low must be greater than 0",
+		},
+		{
+			name:     "zero equals one",
+			protocol: Protocol{HighLow{1, 31}, HighLow{1, 3}, HighLow{1, 3}},
+			expected: "zero and one must differ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualError(t, test.protocol.Validate(), test.expected)
+		})
+	}
+}
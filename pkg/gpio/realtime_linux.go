@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package gpio
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// schedFIFO is the SCHED_FIFO real-time scheduling policy.
+const schedFIFO = 1
+
+type schedParam struct {
+	priority int32
+}
+
+// setRealtimePriority switches the calling thread to the SCHED_FIFO
+// scheduling policy with given priority. This usually requires root
+// privileges or the CAP_SYS_NICE capability.
+func setRealtimePriority(priority int) error {
+	param := schedParam{priority: int32(priority)}
+
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER, 0, schedFIFO, uintptr(unsafe.Pointer(&param)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package gpio
+
+import "errors"
+
+// setRealtimePriority is only supported on linux.
+func setRealtimePriority(priority int) error {
+	return errors.New("real-time scheduling is only supported on linux")
+}
@@ -3,13 +3,36 @@ package gpio
 import (
 	"time"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/warthog618/gpiod"
 )
 
 const (
-	receiveTolerance int64 = 60
-	separationLimit  int64 = 4600
-	maxChanges       uint  = 67
+	// DefaultReceiveTolerance defines the default tolerance in percent for
+	// pulse lengths to still match a protocol.
+	DefaultReceiveTolerance int64 = 60
+
+	// DefaultSeparationLimit defines the default minimum duration of a sync
+	// gap in microseconds that separates two frames.
+	DefaultSeparationLimit int64 = 4600
+
+	// DefaultMaxChanges defines the default number of level changes that can
+	// be buffered for a single frame. This is sufficient for frames of up to
+	// 32 bits.
+	DefaultMaxChanges uint = 67
+
+	// MaxChangesLimit is the upper bound for the number of level changes of
+	// a single frame. Codes are decoded into an uint64, so frames of more
+	// than 64 bits cannot be received.
+	MaxChangesLimit uint = 2*64 + 2
+
+	// DefaultRepeatCount defines the default number of sync gaps of the same
+	// length that have to be observed before a frame is decoded.
+	DefaultRepeatCount uint = 2
+
+	// minChanges is the minimum number of level changes required to hold a
+	// sync gap and at least a single bit.
+	minChanges uint = 3
 
 	receiveResultChanLen = 32
 )
@@ -19,11 +42,22 @@ type Receiver struct {
 	lastEvent   int64
 	changeCount uint
 	repeatCount uint
-	timings     [maxChanges]int64
+	timings     []int64
+
+	receiveTolerance    int64
+	separationLimit     int64
+	maxChanges          uint
+	requiredRepeatCount uint
+	minPulseWidth       int64
+	dedupWindow         time.Duration
+	reportUnmatched     bool
 
 	watcher   Watcher
 	protocols []Protocol
+	clock     clockwork.Clock
+	frames    chan ReceiveResult
 	result    chan ReceiveResult
+	unmatched chan Frame
 }
 
 // NewReceiver creates a *Receiver which listens on the chip's pin at offset
@@ -42,25 +76,69 @@ func NewReceiver(chip *gpiod.Chip, offset int, options ...ReceiverOption) (*Rece
 func NewWatcherReceiver(watcher Watcher, options ...ReceiverOption) *Receiver {
 	r := &Receiver{
 		watcher:   watcher,
+		frames:    make(chan ReceiveResult, receiveResultChanLen),
 		result:    make(chan ReceiveResult, receiveResultChanLen),
+		unmatched: make(chan Frame, receiveResultChanLen),
 		protocols: DefaultProtocols,
+		clock:     clockwork.NewRealClock(),
+
+		receiveTolerance:    DefaultReceiveTolerance,
+		separationLimit:     DefaultSeparationLimit,
+		maxChanges:          DefaultMaxChanges,
+		requiredRepeatCount: DefaultRepeatCount,
 	}
 
 	for _, option := range options {
 		option(r)
 	}
 
+	if r.maxChanges < minChanges {
+		r.maxChanges = minChanges
+	}
+
+	if r.requiredRepeatCount == 0 {
+		r.requiredRepeatCount = 1
+	}
+
+	r.timings = make([]int64, r.maxChanges)
+
 	go r.watch()
+	go r.dedup()
 
 	return r
 }
 
 func (r *Receiver) watch() {
-	defer close(r.result)
+	defer close(r.frames)
+	defer close(r.unmatched)
+
+	var (
+		lastEventType gpiod.LineEventType
+		pending       *gpiod.LineEvent
+	)
+
+	for next := range r.watcher.Watch() {
+		next := next
+		evt := next
+
+		if r.minPulseWidth > 0 {
+			// Hold back each event until the next one arrives. If both are
+			// closer together than the min pulse width, they form a glitch
+			// and are dropped altogether.
+			if pending == nil {
+				pending = &next
+				continue
+			}
+
+			width := int64(next.Timestamp-pending.Timestamp) / int64(time.Microsecond)
+			if width < r.minPulseWidth {
+				pending = nil
+				continue
+			}
 
-	var lastEventType gpiod.LineEventType
+			evt, pending = *pending, &next
+		}
 
-	for evt := range r.watcher.Watch() {
 		if lastEventType != evt.Type {
 			r.handleEvent(evt)
 			lastEventType = evt.Type
@@ -68,11 +146,92 @@ func (r *Receiver) watch() {
 	}
 }
 
+// dedup merges consecutive identical frames that are received within the
+// dedup window into a single result. A result is emitted once no further
+// identical frame was received for the duration of the dedup window. If the
+// dedup window is zero, every frame is emitted as a separate result.
+func (r *Receiver) dedup() {
+	defer close(r.result)
+
+	var (
+		pending        *ReceiveResult
+		pulseLengthSum int64
+		flushCh        <-chan time.Time
+	)
+
+	for {
+		select {
+		case frame, ok := <-r.frames:
+			if !ok {
+				if pending != nil {
+					r.emit(*pending)
+				}
+				return
+			}
+
+			if pending != nil && pending.matches(frame) && frame.LastSeen.Sub(pending.LastSeen) <= r.dedupWindow {
+				pending.RepeatCount++
+				pending.LastSeen = frame.LastSeen
+				pulseLengthSum += frame.PulseLength
+				pending.PulseLength = pulseLengthSum / int64(pending.RepeatCount)
+			} else {
+				if pending != nil {
+					r.emit(*pending)
+				}
+
+				frame.RepeatCount = 1
+				pending = &frame
+				pulseLengthSum = frame.PulseLength
+			}
+
+			if r.dedupWindow <= 0 {
+				r.emit(*pending)
+				pending = nil
+				continue
+			}
+
+			flushCh = r.clock.After(r.dedupWindow)
+		case <-flushCh:
+			r.emit(*pending)
+			pending, flushCh = nil, nil
+		}
+	}
+}
+
+// emit sends result to the result channel. Results are dropped if nobody
+// consumes them.
+func (r *Receiver) emit(result ReceiveResult) {
+	select {
+	case r.result <- result:
+	default:
+	}
+}
+
 // Receive blocks until there is a result on the receive channel
 func (r *Receiver) Receive() <-chan ReceiveResult {
 	return r.result
 }
 
+// Unmatched returns a channel of frames that did not match any of the
+// receiver's protocols. Frames are only reported if the receiver was
+// configured using the ReceiverUnmatchedFrames option.
+func (r *Receiver) Unmatched() <-chan Frame {
+	return r.unmatched
+}
+
+// emitUnmatched sends a copy of the current frame timings to the unmatched
+// channel. Frames are dropped if nobody consumes them.
+func (r *Receiver) emitUnmatched() {
+	frame := Frame{Timings: make([]int64, r.changeCount)}
+
+	copy(frame.Timings, r.timings[:r.changeCount])
+
+	select {
+	case r.unmatched <- frame:
+	default:
+	}
+}
+
 // Close stops the watcher and receiver goroutines and perform cleanup.
 func (r *Receiver) Close() error {
 	return r.watcher.Close()
@@ -82,17 +241,24 @@ func (r *Receiver) handleEvent(evt gpiod.LineEvent) {
 	event := int64(evt.Timestamp) / int64(time.Microsecond)
 	duration := event - r.lastEvent
 
-	if duration > separationLimit {
+	if duration > r.separationLimit {
 		if diff(duration, r.timings[0]) < 200 {
 			r.repeatCount++
 
-			if r.repeatCount == 2 {
+			if r.repeatCount == r.requiredRepeatCount {
+				var matched bool
+
 				for i := 0; i < len(r.protocols); i++ {
 					if r.receiveProtocol(i) {
+						matched = true
 						break
 					}
 				}
 
+				if !matched && r.reportUnmatched && r.changeCount > 7 {
+					r.emitUnmatched()
+				}
+
 				r.repeatCount = 0
 			}
 		}
@@ -100,7 +266,7 @@ func (r *Receiver) handleEvent(evt gpiod.LineEvent) {
 		r.changeCount = 0
 	}
 
-	if r.changeCount >= maxChanges {
+	if r.changeCount >= r.maxChanges {
 		r.changeCount = 0
 		r.repeatCount = 0
 	}
@@ -114,8 +280,13 @@ func (r *Receiver) handleEvent(evt gpiod.LineEvent) {
 func (r *Receiver) receiveProtocol(protocol int) bool {
 	p := r.protocols[protocol]
 
+	// Invalid protocols would cause a division by zero.
+	if p.Sync.Low == 0 {
+		return false
+	}
+
 	delay := r.timings[0] / int64(p.Sync.Low)
-	delayTolerance := delay * receiveTolerance / 100
+	delayTolerance := delay * r.receiveTolerance / 100
 
 	var code uint64
 	var i uint = 1
@@ -135,15 +306,21 @@ func (r *Receiver) receiveProtocol(protocol int) bool {
 	}
 
 	if r.changeCount > 7 {
+		// Frames are timestamped here instead of in dedup as processing of
+		// the frames channel may lag behind.
+		now := r.clock.Now()
+
 		result := ReceiveResult{
 			Code:        code,
 			BitLength:   (r.changeCount - 1) / 2,
 			PulseLength: delay,
 			Protocol:    protocol + 1,
+			FirstSeen:   now,
+			LastSeen:    now,
 		}
 
 		select {
-		case r.result <- result:
+		case r.frames <- result:
 		default:
 		}
 	}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/warthog618/gpiod"
 )
 
@@ -104,7 +105,7 @@ func TestReceiverReceive(t *testing.T) {
 	}()
 
 	for _, tm := range transmissions {
-		<-tx.Transmit(tm.code, DefaultProtocols[tm.protocol-1], tm.pulseLength)
+		<-tx.Transmit(context.Background(), tm.code, DefaultProtocols[tm.protocol-1], tm.pulseLength, TransmitOptions{})
 	}
 
 	<-ctx.Done()
@@ -114,6 +115,31 @@ func TestReceiverReceive(t *testing.T) {
 	}
 }
 
+func TestReceiverInvalidProtocol(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+	pipe := newPinWatcherPipe(fakeClock)
+
+	tx := NewPinTransmitter(pipe, TransmissionCount(10))
+	tx.delay = fakeClock.Advance
+	defer tx.Close()
+
+	invalid := Protocol{Sync: HighLow{1, 0}}
+
+	rx := NewWatcherReceiver(pipe, ReceiverProtocols([]Protocol{invalid, DefaultProtocols[0]}))
+	defer rx.Close()
+
+	<-tx.Transmit(context.Background(), 5510451, DefaultProtocols[0], 184, TransmitOptions{})
+
+	select {
+	case result := <-rx.Receive():
+		assert.Equal(t, uint64(5510451), result.Code)
+		assert.Equal(t, 2, result.Protocol)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for result")
+	}
+}
+
 func TestReceiverClose(t *testing.T) {
 	w := NewFakeWatcher()
 
@@ -122,3 +148,256 @@ func TestReceiverClose(t *testing.T) {
 
 	assert.True(t, w.Closed)
 }
+
+func TestReceiverOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		bitLength int
+		options   []ReceiverOption
+		expected  bool
+	}{
+		{
+			name:      "default options",
+			bitLength: 24,
+			expected:  true,
+		},
+		{
+			name:      "frame exceeds default max changes",
+			bitLength: 40,
+			expected:  false,
+		},
+		{
+			name:      "increased max changes",
+			bitLength: 40,
+			options:   []ReceiverOption{ReceiverMaxChanges(82)},
+			expected:  true,
+		},
+		{
+			name:      "sync gap below separation limit",
+			bitLength: 24,
+			options:   []ReceiverOption{ReceiverSeparationLimit(10000)},
+			expected:  false,
+		},
+		{
+			name:      "repeat count exceeds transmitted frames",
+			bitLength: 24,
+			options:   []ReceiverOption{ReceiverRepeatCount(5)},
+			expected:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+			pipe := newPinWatcherPipe(fakeClock)
+
+			tx := NewPinTransmitter(pipe)
+			tx.delay = fakeClock.Advance
+			defer tx.Close()
+
+			rx := NewWatcherReceiver(pipe, test.options...)
+			defer rx.Close()
+
+			code := uint64(1)<<uint(test.bitLength-1) | 5510451
+			frame := DefaultProtocols[0].pulses(code, test.bitLength, 300)
+
+			// Five frames yield four complete sync gaps.
+			var pulses []time.Duration
+			for i := 0; i < 5; i++ {
+				pulses = append(pulses, frame...)
+			}
+
+			<-tx.TransmitRecording(context.Background(), &Recording{Pulses: pulses}, TransmitOptions{})
+
+			select {
+			case result := <-rx.Receive():
+				if !test.expected {
+					t.Fatalf("expected no result, got %#v", result)
+				}
+
+				assert.Equal(t, code, result.Code)
+				assert.Equal(t, uint(test.bitLength), result.BitLength)
+			case <-time.After(100 * time.Millisecond):
+				if test.expected {
+					t.Fatal("timeout exceeded waiting for result")
+				}
+			}
+		})
+	}
+}
+
+func TestReceiverTolerance(t *testing.T) {
+	tests := []struct {
+		tolerance int64
+		expected  bool
+	}{
+		{DefaultReceiveTolerance, true},
+		{10, false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("tolerance %d", test.tolerance), func(t *testing.T) {
+			fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+			pipe := newPinWatcherPipe(fakeClock)
+
+			tx := NewPinTransmitter(pipe)
+			tx.delay = fakeClock.Advance
+			defer tx.Close()
+
+			rx := NewWatcherReceiver(pipe, ReceiverTolerance(test.tolerance))
+			defer rx.Close()
+
+			frame := DefaultProtocols[0].pulses(5510451, 24, 300)
+
+			// Stretch all data pulses by 20% while leaving the sync gap intact.
+			for i := range frame[:len(frame)-2] {
+				frame[i] = frame[i] * 12 / 10
+			}
+
+			var pulses []time.Duration
+			for i := 0; i < 5; i++ {
+				pulses = append(pulses, frame...)
+			}
+
+			<-tx.TransmitRecording(context.Background(), &Recording{Pulses: pulses}, TransmitOptions{})
+
+			select {
+			case result := <-rx.Receive():
+				if !test.expected {
+					t.Fatalf("expected no result, got %#v", result)
+				}
+
+				assert.Equal(t, uint64(5510451), result.Code)
+			case <-time.After(100 * time.Millisecond):
+				if test.expected {
+					t.Fatal("timeout exceeded waiting for result")
+				}
+			}
+		})
+	}
+}
+
+func TestReceiverMinPulseWidth(t *testing.T) {
+	tests := []struct {
+		minPulseWidth int64
+		expected      bool
+	}{
+		{0, false},
+		{50, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("min pulse width %d", test.minPulseWidth), func(t *testing.T) {
+			w := NewFakeWatcher()
+
+			rx := NewWatcherReceiver(w, ReceiverMinPulseWidth(test.minPulseWidth))
+
+			frame := DefaultProtocols[0].pulses(5510451, 24, 300)
+
+			var pulses []time.Duration
+			for i := 0; i < 5; i++ {
+				pulses = append(pulses, frame...)
+			}
+
+			go func() {
+				defer rx.Close()
+
+				var ts time.Duration
+
+				for i, pulse := range pulses {
+					value := 1 - i%2
+
+					w.Events <- lineEvent(value, ts)
+
+					// Inject a 20µs glitch into the middle of every 7th pulse.
+					if i%7 == 3 {
+						w.Events <- lineEvent(1-value, ts+pulse/2)
+						w.Events <- lineEvent(value, ts+pulse/2+20*time.Microsecond)
+					}
+
+					ts += pulse
+				}
+
+				w.Events <- lineEvent(1, ts)
+				w.Events <- lineEvent(0, ts+300*time.Microsecond)
+			}()
+
+			var results []ReceiveResult
+			for result := range rx.Receive() {
+				results = append(results, result)
+			}
+
+			if !test.expected {
+				assert.Empty(t, results)
+				return
+			}
+
+			require.NotEmpty(t, results)
+			assert.Equal(t, uint64(5510451), results[0].Code)
+		})
+	}
+}
+
+func TestReceiverDedupWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		dedupWindow time.Duration
+	}{
+		{"disabled", 0},
+		{"enabled", 100 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+			pipe := newPinWatcherPipe(fakeClock)
+
+			tx := NewPinTransmitter(pipe, TransmissionCount(10))
+			tx.delay = fakeClock.Advance
+			defer tx.Close()
+
+			rx := NewWatcherReceiver(pipe, ReceiverClock(fakeClock), ReceiverDedupWindow(test.dedupWindow))
+
+			start := fakeClock.Now()
+
+			<-tx.Transmit(context.Background(), 5510451, DefaultProtocols[0], 184, TransmitOptions{})
+
+			end := fakeClock.Now()
+
+			// Give the receiver some time to process all frames before the
+			// dedup window is closed.
+			time.Sleep(50 * time.Millisecond)
+			fakeClock.Advance(test.dedupWindow)
+			rx.Close()
+
+			var results []ReceiveResult
+			for result := range rx.Receive() {
+				results = append(results, result)
+			}
+
+			if test.dedupWindow == 0 {
+				require.True(t, len(results) > 1)
+
+				for _, result := range results {
+					assert.Equal(t, 1, result.RepeatCount)
+					assert.Equal(t, result.FirstSeen, result.LastSeen)
+				}
+
+				return
+			}
+
+			require.Len(t, results, 1)
+
+			result := results[0]
+
+			assert.Equal(t, uint64(5510451), result.Code)
+			assert.True(t, result.RepeatCount > 1)
+			assert.True(t, result.FirstSeen.After(start))
+			assert.True(t, result.LastSeen.After(result.FirstSeen))
+			assert.False(t, result.LastSeen.After(end))
+		})
+	}
+}
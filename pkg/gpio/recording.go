@@ -0,0 +1,129 @@
+package gpio
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/warthog618/gpiod"
+)
+
+// Recording contains raw pulse timings captured from a Watcher. It can be
+// used to control devices whose remote controls use encodings that are not
+// supported by any of the known protocols.
+type Recording struct {
+	// Pulses contains the durations of alternating high and low pulses,
+	// starting with a high pulse.
+	Pulses []time.Duration
+}
+
+// recordingJSON is the json representation of a Recording. Pulse durations
+// are stored in microseconds.
+type recordingJSON struct {
+	Pulses []int64 `json:"pulses"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *Recording) MarshalJSON() ([]byte, error) {
+	rj := recordingJSON{
+		Pulses: make([]int64, len(r.Pulses)),
+	}
+
+	for i, pulse := range r.Pulses {
+		rj.Pulses[i] = int64(pulse / time.Microsecond)
+	}
+
+	return json.Marshal(rj)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Recording) UnmarshalJSON(buf []byte) error {
+	var rj recordingJSON
+
+	if err := json.Unmarshal(buf, &rj); err != nil {
+		return err
+	}
+
+	r.Pulses = make([]time.Duration, len(rj.Pulses))
+
+	for i, pulse := range rj.Pulses {
+		r.Pulses[i] = time.Duration(pulse) * time.Microsecond
+	}
+
+	return nil
+}
+
+// ReadRecording reads a json encoded *Recording from r.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+
+	if err := json.NewDecoder(r).Decode(rec); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// WriteRecording writes rec json encoded to w.
+func WriteRecording(w io.Writer, rec *Recording) error {
+	return json.NewEncoder(w).Encode(rec)
+}
+
+// LoadRecording loads a *Recording from file.
+func LoadRecording(file string) (*Recording, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadRecording(f)
+}
+
+// Record captures the raw pulses observed by watcher. The recording starts
+// with the first rising edge and stops when stopCh is closed, the watcher is
+// closed or no edge was observed for longer than maxGap after the recording
+// started. A maxGap of zero disables the gap detection.
+func Record(watcher Watcher, maxGap time.Duration, stopCh <-chan struct{}) *Recording {
+	var (
+		rec       = &Recording{Pulses: make([]time.Duration, 0)}
+		lastEvent gpiod.LineEvent
+		started   bool
+		gapCh     <-chan time.Time
+	)
+
+	events := watcher.Watch()
+
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return rec
+			}
+
+			if !started {
+				if evt.Type != gpiod.LineEventRisingEdge {
+					continue
+				}
+
+				started = true
+			} else if evt.Type != lastEvent.Type {
+				rec.Pulses = append(rec.Pulses, evt.Timestamp-lastEvent.Timestamp)
+			} else {
+				// Ignore duplicate events of the same type.
+				continue
+			}
+
+			lastEvent = evt
+
+			if maxGap > 0 {
+				gapCh = time.After(maxGap)
+			}
+		case <-gapCh:
+			return rec
+		case <-stopCh:
+			return rec
+		}
+	}
+}
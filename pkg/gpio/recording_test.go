@@ -0,0 +1,77 @@
+package gpio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/gpiod"
+)
+
+func TestRecord(t *testing.T) {
+	w := NewFakeWatcher()
+
+	go func() {
+		defer w.Close()
+		events := []gpiod.LineEvent{
+			// Leading falling edge is ignored.
+			{Type: gpiod.LineEventFallingEdge, Timestamp: 100 * time.Microsecond},
+			{Type: gpiod.LineEventRisingEdge, Timestamp: 1000 * time.Microsecond},
+			{Type: gpiod.LineEventFallingEdge, Timestamp: 1350 * time.Microsecond},
+			// Duplicate event types are ignored.
+			{Type: gpiod.LineEventFallingEdge, Timestamp: 1400 * time.Microsecond},
+			{Type: gpiod.LineEventRisingEdge, Timestamp: 2400 * time.Microsecond},
+			{Type: gpiod.LineEventFallingEdge, Timestamp: 3450 * time.Microsecond},
+		}
+
+		for _, evt := range events {
+			w.Events <- evt
+		}
+	}()
+
+	rec := Record(w, 0, nil)
+
+	expected := []time.Duration{
+		350 * time.Microsecond,
+		1050 * time.Microsecond,
+		1050 * time.Microsecond,
+	}
+
+	assert.Equal(t, expected, rec.Pulses)
+}
+
+func TestRecord_MaxGap(t *testing.T) {
+	w := NewFakeWatcher()
+	defer w.Close()
+
+	go func() {
+		w.Events <- gpiod.LineEvent{Type: gpiod.LineEventRisingEdge, Timestamp: 0}
+		w.Events <- gpiod.LineEvent{Type: gpiod.LineEventFallingEdge, Timestamp: 500 * time.Microsecond}
+	}()
+
+	rec := Record(w, 20*time.Millisecond, nil)
+
+	assert.Equal(t, []time.Duration{500 * time.Microsecond}, rec.Pulses)
+}
+
+func TestRecordingReadWrite(t *testing.T) {
+	rec := &Recording{
+		Pulses: []time.Duration{350 * time.Microsecond, 1050 * time.Microsecond},
+	}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, WriteRecording(&buf, rec))
+	assert.Equal(t, "{\"pulses\":[350,1050]}\n", buf.String())
+
+	result, err := ReadRecording(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, rec, result)
+}
+
+func TestLoadRecording_Nonexistent(t *testing.T) {
+	_, err := LoadRecording("testdata/idonotexist.json")
+	assert.Error(t, err)
+}
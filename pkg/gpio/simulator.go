@@ -0,0 +1,220 @@
+package gpio
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/warthog618/gpiod"
+)
+
+const (
+	mediumEventChanLen = 1024
+
+	// maxNoisePulseWidth is the maximum width of noise pulses injected into
+	// the medium.
+	maxNoisePulseWidth = 100 * time.Microsecond
+)
+
+// MediumOption is the signature of funcs that are used to configure a
+// *Medium.
+type MediumOption func(*Medium)
+
+// MediumNoiseRate configures the probability for each edge that a short
+// noise pulse is injected after it. Must be between 0 and 1.
+func MediumNoiseRate(rate float64) MediumOption {
+	return func(m *Medium) {
+		m.noiseRate = rate
+	}
+}
+
+// MediumDropRate configures the probability for each edge that it is not
+// delivered to a watcher. Must be between 0 and 1.
+func MediumDropRate(rate float64) MediumOption {
+	return func(m *Medium) {
+		m.dropRate = rate
+	}
+}
+
+// MediumClock configures the clock that is used to timestamp edges. This is
+// useful to make timing predictable in tests. If not set, a monotonic clock
+// is used.
+func MediumClock(clock clockwork.Clock) MediumOption {
+	return func(m *Medium) {
+		m.now = func() time.Duration {
+			return time.Duration(clock.Now().UnixNano())
+		}
+	}
+}
+
+// MediumSeed configures the seed of the random number generator that is used
+// for noise and drops.
+func MediumSeed(seed int64) MediumOption {
+	return func(m *Medium) {
+		m.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// Medium is a simulated in-process rf medium. Edges written to its output
+// pins are timestamped and delivered as gpiod.LineEvent to all of its
+// watchers. This allows to connect a Transmitter to a Receiver without any
+// hardware, e.g. for end-to-end tests and demos. Overlapping transmissions of
+// multiple output pins are not merged, their edges are interleaved instead.
+type Medium struct {
+	mu        sync.Mutex
+	watchers  map[*mediumWatcher]struct{}
+	rand      *rand.Rand
+	noiseRate float64
+	dropRate  float64
+	now       func() time.Duration
+}
+
+// NewMedium creates a new *Medium.
+func NewMedium(options ...MediumOption) *Medium {
+	m := &Medium{
+		watchers: make(map[*mediumWatcher]struct{}),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		now: func() time.Duration {
+			return time.Duration(nanotime())
+		},
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// OutputPin creates a new OutputPin that writes its edges to the medium.
+func (m *Medium) OutputPin() OutputPin {
+	return &mediumOutputPin{medium: m}
+}
+
+// Watcher creates a new Watcher that observes all edges written to the
+// medium.
+func (m *Medium) Watcher() Watcher {
+	w := &mediumWatcher{
+		medium: m,
+		events: make(chan gpiod.LineEvent, mediumEventChanLen),
+	}
+
+	m.mu.Lock()
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+
+	return w
+}
+
+// emit delivers an edge of given value to all watchers, potentially dropping
+// it or injecting noise after it.
+func (m *Medium) emit(value int) {
+	timestamp := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for w := range m.watchers {
+		if m.dropRate > 0 && m.rand.Float64() < m.dropRate {
+			continue
+		}
+
+		w.deliver(lineEvent(value, timestamp))
+
+		if m.noiseRate > 0 && m.rand.Float64() < m.noiseRate {
+			// Keep offset and width below maxNoisePulseWidth in total to
+			// avoid overlapping with the next regular edge.
+			offset := time.Duration(m.rand.Int63n(int64(maxNoisePulseWidth / 2)))
+			width := time.Duration(m.rand.Int63n(int64(maxNoisePulseWidth/2))) + 1
+
+			w.deliver(lineEvent(1-value, timestamp+offset))
+			w.deliver(lineEvent(value, timestamp+offset+width))
+		}
+	}
+}
+
+func (m *Medium) removeWatcher(w *mediumWatcher) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.watchers[w]; !ok {
+		return false
+	}
+
+	delete(m.watchers, w)
+
+	return true
+}
+
+func lineEvent(value int, timestamp time.Duration) gpiod.LineEvent {
+	evt := gpiod.LineEvent{
+		Type:      gpiod.LineEventFallingEdge,
+		Timestamp: timestamp,
+	}
+
+	if value != 0 {
+		evt.Type = gpiod.LineEventRisingEdge
+	}
+
+	return evt
+}
+
+type mediumOutputPin struct {
+	medium *Medium
+	mu     sync.Mutex
+	value  int
+}
+
+// SetValue implements OutputPin.
+func (p *mediumOutputPin) SetValue(value int) error {
+	if value != 0 {
+		value = 1
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Only actual level changes produce edges.
+	if value == p.value {
+		return nil
+	}
+
+	p.value = value
+	p.medium.emit(value)
+
+	return nil
+}
+
+// Close implements Closer.
+func (p *mediumOutputPin) Close() error {
+	return nil
+}
+
+type mediumWatcher struct {
+	medium *Medium
+	events chan gpiod.LineEvent
+}
+
+// deliver delivers evt to the watcher. Similar to the kernel's event buffer,
+// events are dropped if the watcher's buffer is full.
+func (w *mediumWatcher) deliver(evt gpiod.LineEvent) {
+	select {
+	case w.events <- evt:
+	default:
+	}
+}
+
+// Watch implements Watcher.
+func (w *mediumWatcher) Watch() <-chan gpiod.LineEvent {
+	return w.events
+}
+
+// Close implements Closer.
+func (w *mediumWatcher) Close() error {
+	if w.medium.removeWatcher(w) {
+		close(w.events)
+	}
+
+	return nil
+}
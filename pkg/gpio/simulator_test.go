@@ -0,0 +1,122 @@
+package gpio
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/gpiod"
+)
+
+func TestMedium(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	medium := NewMedium(MediumClock(fakeClock))
+
+	w1 := medium.Watcher()
+	w2 := medium.Watcher()
+	pin := medium.OutputPin()
+
+	require.NoError(t, pin.SetValue(1))
+	fakeClock.Advance(350 * time.Microsecond)
+	require.NoError(t, pin.SetValue(1))
+	require.NoError(t, pin.SetValue(0))
+
+	for _, w := range []Watcher{w1, w2} {
+		rising := <-w.Watch()
+		falling := <-w.Watch()
+
+		assert.Equal(t, gpiod.LineEventRisingEdge, rising.Type)
+		assert.Equal(t, gpiod.LineEventFallingEdge, falling.Type)
+		assert.Equal(t, 350*time.Microsecond, falling.Timestamp-rising.Timestamp)
+	}
+
+	require.NoError(t, w1.Close())
+	require.NoError(t, w1.Close())
+
+	_, ok := <-w1.Watch()
+	assert.False(t, ok)
+
+	require.NoError(t, pin.SetValue(1))
+
+	evt := <-w2.Watch()
+	assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+}
+
+func TestMedium_DropRate(t *testing.T) {
+	medium := NewMedium(MediumDropRate(1))
+
+	w := medium.Watcher()
+	pin := medium.OutputPin()
+
+	require.NoError(t, pin.SetValue(1))
+	require.NoError(t, pin.SetValue(0))
+	require.NoError(t, w.Close())
+
+	_, ok := <-w.Watch()
+	assert.False(t, ok)
+}
+
+func TestMedium_NoiseRate(t *testing.T) {
+	medium := NewMedium(MediumNoiseRate(1), MediumSeed(42))
+
+	w := medium.Watcher()
+	pin := medium.OutputPin()
+
+	require.NoError(t, pin.SetValue(1))
+	require.NoError(t, w.Close())
+
+	var events []gpiod.LineEvent
+
+	for evt := range w.Watch() {
+		events = append(events, evt)
+	}
+
+	require.Len(t, events, 3)
+	assert.Equal(t, gpiod.LineEventRisingEdge, events[0].Type)
+	assert.Equal(t, gpiod.LineEventFallingEdge, events[1].Type)
+	assert.Equal(t, gpiod.LineEventRisingEdge, events[2].Type)
+	assert.True(t, events[2].Timestamp-events[0].Timestamp < maxNoisePulseWidth)
+}
+
+func TestMedium_TransmitReceive(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+	medium := NewMedium(MediumClock(fakeClock))
+
+	rx := NewWatcherReceiver(medium.Watcher())
+	defer rx.Close()
+
+	tx := NewPinTransmitter(medium.OutputPin(), TransmissionCount(5))
+	tx.delay = fakeClock.Advance
+	defer tx.Close()
+
+	<-tx.Transmit(context.Background(), 5510451, DefaultProtocols[0], 184, TransmitOptions{})
+
+	select {
+	case result := <-rx.Receive():
+		assert.Equal(t, uint64(5510451), result.Code)
+		assert.Equal(t, 1, result.Protocol)
+		assert.Equal(t, uint(24), result.BitLength)
+	case <-time.After(time.Second):
+		t.Fatal("timeout exceeded waiting for result")
+	}
+}
+
+func TestMediumBackend(t *testing.T) {
+	backend := NewMedium().Backend()
+	defer backend.Close()
+
+	w, err := backend.Watcher(17)
+	require.NoError(t, err)
+	defer w.Close()
+
+	pin, err := backend.OutputPin(27)
+	require.NoError(t, err)
+
+	require.NoError(t, pin.SetValue(1))
+
+	evt := <-w.Watch()
+	assert.Equal(t, gpiod.LineEventRisingEdge, evt.Type)
+}
@@ -0,0 +1,82 @@
+package gpio
+
+import (
+	"math"
+	"time"
+)
+
+// TimingStats contains statistics about the deviation of the actual pulse
+// durations of a transmission from the intended ones. Large deviations are
+// usually caused by the transmit goroutine being preempted, e.g. on a busy
+// system. Outlets may ignore codes that are sent with too much jitter.
+type TimingStats struct {
+	// Key is the key the transmission was enqueued with.
+	Key string
+
+	// Pulses is the number of pulses that were sent out, including all
+	// repetitions.
+	Pulses int
+
+	// MeanJitter is the mean absolute deviation of the actual from the
+	// intended pulse durations.
+	MeanJitter time.Duration
+
+	// MaxJitter is the maximum absolute deviation of the actual from the
+	// intended pulse durations.
+	MaxJitter time.Duration
+
+	// StdDev is the standard deviation of the deviations of the actual from
+	// the intended pulse durations.
+	StdDev time.Duration
+}
+
+// timingRecorder accumulates the deviations of actual from intended pulse
+// durations without retaining the individual samples.
+type timingRecorder struct {
+	pulses int
+	sum    float64
+	sumAbs float64
+	sumSq  float64
+	max    time.Duration
+}
+
+// record records a pulse that was intended to last for intended but actually
+// lasted for actual.
+func (r *timingRecorder) record(intended, actual time.Duration) {
+	dev := actual - intended
+
+	abs := dev
+	if abs < 0 {
+		abs = -abs
+	}
+
+	if abs > r.max {
+		r.max = abs
+	}
+
+	r.pulses++
+	r.sum += float64(dev)
+	r.sumAbs += float64(abs)
+	r.sumSq += float64(dev) * float64(dev)
+}
+
+// stats computes the TimingStats of all recorded pulses.
+func (r *timingRecorder) stats(key string) TimingStats {
+	stats := TimingStats{
+		Key:       key,
+		Pulses:    r.pulses,
+		MaxJitter: r.max,
+	}
+
+	if r.pulses == 0 {
+		return stats
+	}
+
+	n := float64(r.pulses)
+	mean := r.sum / n
+
+	stats.MeanJitter = time.Duration(r.sumAbs / n)
+	stats.StdDev = time.Duration(math.Sqrt(math.Max(r.sumSq/n-mean*mean, 0)))
+
+	return stats
+}
@@ -0,0 +1,27 @@
+package gpio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimingRecorder(t *testing.T) {
+	rec := &timingRecorder{}
+
+	assert.Equal(t, TimingStats{Key: "foo"}, rec.stats("foo"))
+
+	rec.record(100*time.Microsecond, 110*time.Microsecond)
+	rec.record(100*time.Microsecond, 90*time.Microsecond)
+	rec.record(300*time.Microsecond, 330*time.Microsecond)
+	rec.record(300*time.Microsecond, 290*time.Microsecond)
+
+	stats := rec.stats("foo")
+
+	assert.Equal(t, "foo", stats.Key)
+	assert.Equal(t, 4, stats.Pulses)
+	assert.Equal(t, 15*time.Microsecond, stats.MeanJitter)
+	assert.Equal(t, 30*time.Microsecond, stats.MaxJitter)
+	assert.InDelta(t, 16583, int64(stats.StdDev), 1)
+}
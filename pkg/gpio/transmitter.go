@@ -1,7 +1,11 @@
 package gpio
 
 import (
-	"sync/atomic"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/warthog618/gpiod"
@@ -12,25 +16,147 @@ const (
 	// transmitted in a row by default.
 	DefaultTransmissionCount = 10
 
-	transmissionChanLen = 32
-	bitLength           = 24
+	// BitLength is the number of bits of the codes sent out by Transmit.
+	BitLength = 24
+
+	maxPendingTransmissions = 32
+)
+
+var (
+	// ErrQueueFull is returned if a transmission is rejected because the
+	// maximum number of transmissions is already pending.
+	ErrQueueFull = errors.New("transmit queue is full")
+
+	// ErrTransmissionSuperseded is returned if a transmission is superseded
+	// by a newer transmission with the same key.
+	ErrTransmissionSuperseded = errors.New("transmission superseded")
+
+	// ErrTransmitterClosed is returned if a transmission is rejected or
+	// cancelled because the transmitter was closed.
+	ErrTransmitterClosed = errors.New("transmitter closed")
 )
 
+// TransmitOptions configures a single transmission.
+type TransmitOptions struct {
+	// Key identifies the target of a transmission, e.g. the ID of an outlet.
+	// If a transmission with the same key is still pending, it is superseded
+	// by the new one. If a transmission with the same key is currently
+	// active, it is cancelled after its current repetition. Transmissions
+	// without key are never coalesced.
+	Key string
+
+	// TransmissionCount overrides the number of times a code is transmitted
+	// in a row. If zero, the count the transmitter was configured with is
+	// used for codes, while recordings are sent out once.
+	TransmissionCount int
+
+	// RepeatGap is the duration the pin is held low between two repetitions.
+	// If zero, repetitions are sent back-to-back.
+	RepeatGap time.Duration
+}
+
+// TransmissionInfo describes a pending or active transmission.
+type TransmissionInfo struct {
+	// Key is the key the transmission was enqueued with.
+	Key string
+
+	// Active is true if the transmission is currently being sent out.
+	Active bool
+
+	// Count is the number of times the pulses are sent out in a row.
+	Count int
+
+	// Sent is the number of repetitions that were already sent out.
+	Sent int
+
+	// EnqueuedAt is the time the transmission was enqueued.
+	EnqueuedAt time.Time
+}
+
 type transmission struct {
-	code        uint64
-	protocol    Protocol
-	pulseLength uint
-	done        chan struct{}
+	ctx        context.Context
+	key        string
+	pulses     []time.Duration
+	code       uint64
+	protocol   Protocol
+	hasCode    bool
+	airtime    time.Duration
+	count      int
+	gap        time.Duration
+	limiter    *DutyCycleLimiter
+	reserved   time.Duration
+	sent       int
+	cancelErr  error
+	cancelled  chan struct{}
+	enqueuedAt time.Time
+	errs       chan error
+	done       chan struct{}
+	doneOnce   sync.Once
+}
+
+// finish marks the transmission as done and releases duty-cycle budget that
+// was reserved but not used. A non-nil err is delivered to the receiver of the
+// transmission's error channel before it is closed. The done channel is
+// closed as well, so that internal watchers do not need to consume errs.
+func (t *transmission) finish(err error) {
+	t.doneOnce.Do(func() {
+		if t.limiter != nil && t.reserved > 0 {
+			t.limiter.release(t.reserved)
+		}
+
+		if err != nil {
+			t.errs <- err
+		}
+
+		close(t.errs)
+		close(t.done)
+	})
+}
+
+// cancel stops the transmission after its current repetition. err is
+// reported to the receiver of the transmission's error channel. The cancelled
+// channel is closed, so that waits between repetitions are interrupted. Must
+// be called with the transmitter's mutex held.
+func (t *transmission) cancel(err error) {
+	if t.cancelErr == nil {
+		close(t.cancelled)
+	}
+
+	t.cancelErr = err
+}
+
+func (t *transmission) info(active bool) TransmissionInfo {
+	return TransmissionInfo{
+		Key:        t.key,
+		Active:     active,
+		Count:      t.count,
+		Sent:       t.sent,
+		EnqueuedAt: t.enqueuedAt,
+	}
 }
 
 // Transmitter can serialize and transmit rf codes.
 type Transmitter struct {
 	pin               OutputPin
-	transmission      chan transmission
-	closed            int32
 	transmissionCount int
-	// delay can be replaced in tests to make timing predictable.
+	lockOSThread      bool
+	realtimePriority  int
+	realtimeErr       error
+	timingStats       func(TimingStats)
+	dutyCycle         *DutyCycleLimiter
+	echoLog           *EchoLog
+	// delay, sleep and now can be replaced in tests to make timing
+	// predictable.
 	delay func(time.Duration)
+	sleep func(time.Duration, <-chan struct{})
+	now   func() int64
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []*transmission
+	active  *transmission
+	closed  bool
+	stopped chan struct{}
 }
 
 // NewTransmitter creates a Transmitter which attaches to the chip's pin at
@@ -48,11 +174,15 @@ func NewTransmitter(chip *gpiod.Chip, offset int, options ...TransmitterOption)
 func NewPinTransmitter(pin OutputPin, options ...TransmitterOption) *Transmitter {
 	t := &Transmitter{
 		pin:               pin,
-		transmission:      make(chan transmission, transmissionChanLen),
 		transmissionCount: DefaultTransmissionCount,
 		delay:             delay,
+		sleep:             sleep,
+		now:               nanotime,
+		stopped:           make(chan struct{}),
 	}
 
+	t.cond = sync.NewCond(&t.mu)
+
 	for _, option := range options {
 		option(t)
 	}
@@ -61,70 +191,400 @@ func NewPinTransmitter(pin OutputPin, options ...TransmitterOption) *Transmitter
 		t.transmissionCount = 1
 	}
 
-	go t.watch()
+	started := make(chan struct{})
+
+	go t.watch(started)
+
+	<-started
 
 	return t
 }
 
 // Transmit transmits a code using given protocol and pulse length.
 //
-// This method returns immediately. The code is transmitted in the background.
-// If you need to ensure that a code has been fully transmitted, wait for the
-// returned channel to be closed.
-func (t *Transmitter) Transmit(code uint64, protocol Protocol, pulseLength uint) <-chan struct{} {
-	done := make(chan struct{})
+// This method never blocks. The code is transmitted in the background. The
+// returned channel receives an error if the transmission failed, was rejected,
+// cancelled via ctx or superseded by a newer transmission with the same key.
+// It is closed once the transmission is done, so if you need to ensure that a
+// code has been fully transmitted, wait for the channel to be closed without
+// delivering an error.
+func (t *Transmitter) Transmit(ctx context.Context, code uint64, protocol Protocol, pulseLength uint, opts TransmitOptions) <-chan error {
+	trans := newTransmission(ctx, protocol.pulses(code, BitLength, pulseLength), t.transmissionCount, opts)
+	trans.code, trans.protocol, trans.hasCode = code, protocol, true
+
+	return t.enqueue(trans, opts)
+}
+
+// TransmitRecording transmits the pulses of rec verbatim. In contrast to
+// Transmit, the recording is only sent out once unless
+// opts.TransmissionCount is set, since recordings usually already contain the
+// repetitions sent out by the original remote control.
+//
+// This method never blocks. The recording is transmitted in the background.
+// The returned channel behaves like the one returned by Transmit.
+func (t *Transmitter) TransmitRecording(ctx context.Context, rec *Recording, opts TransmitOptions) <-chan error {
+	return t.enqueue(newTransmission(ctx, rec.Pulses, 1, opts), opts)
+}
+
+// RealtimeError returns the error that occurred while requesting real-time
+// scheduling priority for the transmit thread, if any. See
+// TransmitterRealtimePriority.
+func (t *Transmitter) RealtimeError() error {
+	return t.realtimeErr
+}
 
-	if atomic.LoadInt32(&t.closed) == 1 {
-		close(done)
-		return done
+// Transmissions returns the currently active transmission, if any, followed
+// by all pending transmissions in the order they will be sent out.
+func (t *Transmitter) Transmissions() []TransmissionInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	infos := make([]TransmissionInfo, 0, len(t.pending)+1)
+
+	if t.active != nil {
+		infos = append(infos, t.active.info(true))
+	}
+
+	for _, trans := range t.pending {
+		infos = append(infos, trans.info(false))
+	}
+
+	return infos
+}
+
+// newTransmission creates a transmission of pulses which are sent out count
+// times in a row, unless opts.TransmissionCount overrides it.
+func newTransmission(ctx context.Context, pulses []time.Duration, count int, opts TransmitOptions) *transmission {
+	if opts.TransmissionCount > 0 {
+		count = opts.TransmissionCount
+	}
+
+	return &transmission{
+		ctx:        ctx,
+		key:        opts.Key,
+		pulses:     pulses,
+		airtime:    airtime(pulses),
+		count:      count,
+		gap:        opts.RepeatGap,
+		enqueuedAt: time.Now(),
+		errs:       make(chan error, 1),
+		done:       make(chan struct{}),
+		cancelled:  make(chan struct{}),
+	}
+}
+
+// enqueue enqueues trans. The transmission is rejected with ErrQueueFull if
+// the maximum number of transmissions is already pending.
+func (t *Transmitter) enqueue(trans *transmission, opts TransmitOptions) <-chan error {
+	ctx := trans.ctx
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		trans.finish(ErrTransmitterClosed)
+		return trans.errs
+	}
+
+	if err := ctx.Err(); err != nil {
+		trans.finish(err)
+		return trans.errs
+	}
+
+	if opts.Key != "" {
+		t.supersede(opts.Key)
 	}
 
-	t.transmission <- transmission{
-		code:        code,
-		protocol:    protocol,
-		pulseLength: pulseLength,
-		done:        done,
+	if len(t.pending) >= maxPendingTransmissions {
+		trans.finish(ErrQueueFull)
+		return trans.errs
 	}
 
-	return done
+	if t.dutyCycle != nil {
+		reserved, err := t.dutyCycle.admit(trans.airtime, trans.count)
+		if err != nil {
+			trans.finish(err)
+			return trans.errs
+		}
+
+		trans.limiter, trans.reserved = t.dutyCycle, reserved
+	}
+
+	t.pending = append(t.pending, trans)
+	t.cond.Broadcast()
+
+	if ctx.Done() != nil {
+		go t.watchContext(trans)
+	}
+
+	return trans.errs
+}
+
+// supersede removes pending transmissions with key from the queue and
+// cancels the active transmission if it has the same key. Must be called
+// with t.mu held.
+func (t *Transmitter) supersede(key string) {
+	pending := t.pending[:0]
+
+	for _, trans := range t.pending {
+		if trans.key == key {
+			trans.finish(ErrTransmissionSuperseded)
+			continue
+		}
+
+		pending = append(pending, trans)
+	}
+
+	t.pending = pending
+
+	if t.active != nil && t.active.key == key {
+		t.active.cancel(ErrTransmissionSuperseded)
+	}
+}
+
+// watchContext cancels trans as soon as its context is done.
+func (t *Transmitter) watchContext(trans *transmission) {
+	select {
+	case <-trans.ctx.Done():
+		t.cancel(trans, trans.ctx.Err())
+	case <-trans.done:
+	}
 }
 
-// transmit performs the acutal transmission of the remote control code.
-func (t *Transmitter) transmit(trans transmission) {
-	defer close(trans.done)
+// cancel removes trans from the queue if it is still pending or stops it
+// after its current repetition if it is active. err is reported to the
+// receiver of the transmission's error channel.
+func (t *Transmitter) cancel(trans *transmission, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	for i := 0; i < t.transmissionCount; i++ {
-		for j := bitLength - 1; j >= 0; j-- {
-			if trans.code&(1<<uint64(j)) > 0 {
-				t.send(trans.protocol.One, trans.pulseLength)
-			} else {
-				t.send(trans.protocol.Zero, trans.pulseLength)
+	if t.active == trans {
+		trans.cancel(err)
+		return
+	}
+
+	for i, p := range t.pending {
+		if p == trans {
+			t.pending = append(t.pending[:i], t.pending[i+1:]...)
+			trans.finish(err)
+			return
+		}
+	}
+}
+
+// next blocks until a transmission is pending and returns it after marking
+// it active. Returns nil if the transmitter was closed.
+func (t *Transmitter) next() *transmission {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for len(t.pending) == 0 && !t.closed {
+		t.cond.Wait()
+	}
+
+	if t.closed {
+		return nil
+	}
+
+	trans := t.pending[0]
+	t.pending = t.pending[1:]
+	t.active = trans
+
+	return trans
+}
+
+// transmit performs the acutal transmission of the pulses. The transmission
+// stops early if it gets cancelled or superseded or if the gpio pin cannot be
+// set.
+func (t *Transmitter) transmit(trans *transmission) {
+	var (
+		err error
+		rec *timingRecorder
+	)
+
+	if t.timingStats != nil {
+		rec = &timingRecorder{}
+	}
+
+	if t.echoLog != nil && trans.hasCode {
+		entry := t.echoLog.begin(trans.code, trans.protocol, BitLength)
+		defer t.echoLog.end(entry)
+	}
+
+	defer func() {
+		t.mu.Lock()
+		t.active = nil
+		t.mu.Unlock()
+
+		if rec != nil && rec.pulses > 0 {
+			t.timingStats(rec.stats(trans.key))
+		}
+
+		trans.finish(err)
+	}()
+
+	for trans.sent < trans.count {
+		if t.dutyCycle != nil {
+			t.dutyCycle.wait(trans.airtime, func() bool { return t.cancelled(trans) != nil })
+		}
+
+		if err = t.cancelled(trans); err != nil {
+			return
+		}
+
+		if err = t.send(trans.pulses, rec); err != nil {
+			return
+		}
+
+		if t.dutyCycle != nil {
+			reserved := trans.reserved
+			if reserved > trans.airtime {
+				reserved = trans.airtime
 			}
+
+			t.dutyCycle.consume(trans.airtime, reserved)
+			trans.reserved -= reserved
+		}
+
+		t.mu.Lock()
+		trans.sent++
+		t.mu.Unlock()
+
+		// The pin is left low between repetitions. The gap is cut short if
+		// the transmission gets cancelled.
+		if trans.gap > 0 && trans.sent < trans.count {
+			t.sleep(trans.gap, trans.cancelled)
 		}
-		t.send(trans.protocol.Sync, trans.pulseLength)
 	}
 }
 
-// Close stops started goroutines and closes the gpio pin.
+// cancelled returns the reason why trans was cancelled or nil if it was not
+// cancelled.
+func (t *Transmitter) cancelled(trans *transmission) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return trans.cancelErr
+}
+
+// Close cancels all pending transmissions, waits for the active transmission
+// to stop after its current repetition and closes the gpio pin. Cancelled
+// transmissions receive ErrTransmitterClosed. Subsequent calls to Close only
+// wait for the active transmission to stop.
 func (t *Transmitter) Close() error {
-	atomic.StoreInt32(&t.closed, 0)
-	close(t.transmission)
+	t.mu.Lock()
+
+	if t.closed {
+		t.mu.Unlock()
+		<-t.stopped
+		return nil
+	}
+
+	t.closed = true
+
+	for _, trans := range t.pending {
+		trans.finish(ErrTransmitterClosed)
+	}
+
+	t.pending = nil
+
+	if t.active != nil {
+		t.active.cancel(ErrTransmitterClosed)
+	}
+
+	t.cond.Broadcast()
+	t.mu.Unlock()
+
+	<-t.stopped
+
 	return t.pin.Close()
 }
 
-// watch listens on a channel and processes incoming transmissions.
-func (t *Transmitter) watch() {
-	for trans := range t.transmission {
+// sleep pauses the current goroutine for d or until cancel is closed,
+// whichever happens first.
+func sleep(d time.Duration, cancel <-chan struct{}) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-cancel:
+	}
+}
+
+// watch processes pending transmissions until the transmitter is closed.
+// started is closed once the goroutine is set up for sending.
+func (t *Transmitter) watch(started chan<- struct{}) {
+	defer close(t.stopped)
+
+	if t.lockOSThread || t.realtimePriority > 0 {
+		// The thread is never unlocked. This causes it to be terminated
+		// once the goroutine exits instead of being returned to the pool
+		// of threads with real-time priority.
+		runtime.LockOSThread()
+	}
+
+	if t.realtimePriority > 0 {
+		t.realtimeErr = setRealtimePriority(t.realtimePriority)
+	}
+
+	close(started)
+
+	for {
+		trans := t.next()
+		if trans == nil {
+			return
+		}
+
 		t.transmit(trans)
 	}
 }
 
-// send sends a sequence of high and low pulses on the gpio pin.
-func (t *Transmitter) send(pulses HighLow, pulseLength uint) {
-	t.pin.SetValue(1)
-	t.delay(time.Microsecond * time.Duration(pulseLength*pulses.High))
-	t.pin.SetValue(0)
-	t.delay(time.Microsecond * time.Duration(pulseLength*pulses.Low))
+// send sends a sequence of alternating high and low pulses on the gpio pin,
+// starting with a high pulse. The pin is left in low state, also if setting
+// a value fails midway. If rec is non-nil, the actual pulse durations are
+// recorded. If the pin is a PulseSender, sending is delegated to it and no
+// timings are recorded.
+func (t *Transmitter) send(pulses []time.Duration, rec *timingRecorder) error {
+	if sender, ok := t.pin.(PulseSender); ok {
+		return sender.SendPulses(pulses)
+	}
+
+	last := t.now()
+
+	for i, pulse := range pulses {
+		if err := t.pin.SetValue(1 - i%2); err != nil {
+			t.pin.SetValue(0)
+			return fmt.Errorf("failed to set pin value: %v", err)
+		}
+
+		t.delay(pulse)
+
+		if rec != nil {
+			now := t.now()
+			rec.record(pulse, time.Duration(now-last))
+			last = now
+		}
+	}
+
+	if len(pulses)%2 == 1 {
+		if err := t.pin.SetValue(0); err != nil {
+			return fmt.Errorf("failed to set pin value: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// airtime returns the total duration of pulses.
+func airtime(pulses []time.Duration) time.Duration {
+	var sum time.Duration
+
+	for _, pulse := range pulses {
+		sum += pulse
+	}
+
+	return sum
 }
 
 // NewDiscardingTransmitter creates a *Transmitter that does not send anything.
@@ -1,9 +1,14 @@
 package gpio
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/jonboulle/clockwork"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTransmitterTransmit(t *testing.T) {
@@ -12,7 +17,7 @@ func TestTransmitterTransmit(t *testing.T) {
 	tx := NewPinTransmitter(pin, TransmissionCount(1))
 	defer tx.Close()
 
-	<-tx.Transmit(0x1, DefaultProtocols[0], 190)
+	require.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{}))
 
 	assert.Equal(
 		t,
@@ -21,6 +26,21 @@ func TestTransmitterTransmit(t *testing.T) {
 	)
 }
 
+func TestTransmitterTransmitRecording(t *testing.T) {
+	pin := NewFakeOutputPin()
+	tx := NewPinTransmitter(pin, TransmissionCount(3))
+	defer tx.Close()
+
+	rec := &Recording{
+		Pulses: []time.Duration{350 * time.Microsecond, 1050 * time.Microsecond, 350 * time.Microsecond},
+	}
+
+	require.NoError(t, <-tx.TransmitRecording(context.Background(), rec, TransmitOptions{}))
+
+	// Recordings are only transmitted once and the pin is left in low state.
+	assert.Equal(t, []int{1, 0, 1, 0}, pin.Values)
+}
+
 func TestTransmitterClose(t *testing.T) {
 	pin := NewFakeOutputPin()
 
@@ -29,3 +49,311 @@ func TestTransmitterClose(t *testing.T) {
 
 	assert.True(t, pin.Closed)
 }
+
+// blockingDelay returns a delay func which blocks until release is closed.
+func blockingDelay(release <-chan struct{}) func(time.Duration) {
+	return func(time.Duration) { <-release }
+}
+
+func waitActive(t *testing.T, tx *Transmitter) {
+	require.Eventually(t, func() bool {
+		infos := tx.Transmissions()
+		return len(infos) > 0 && infos[0].Active
+	}, time.Second, time.Millisecond)
+}
+
+func TestTransmitterSupersedePending(t *testing.T) {
+	release := make(chan struct{})
+
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmissionCount(1))
+	tx.delay = blockingDelay(release)
+	defer tx.Close()
+
+	first := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{Key: "foo"})
+	waitActive(t, tx)
+
+	second := tx.Transmit(context.Background(), 0x2, DefaultProtocols[0], 190, TransmitOptions{Key: "bar"})
+	third := tx.Transmit(context.Background(), 0x3, DefaultProtocols[0], 190, TransmitOptions{Key: "bar"})
+
+	select {
+	case err := <-second:
+		assert.Equal(t, ErrTransmissionSuperseded, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected superseded transmission to be done")
+	}
+
+	infos := tx.Transmissions()
+	require.Len(t, infos, 2)
+	assert.Equal(t, "foo", infos[0].Key)
+	assert.True(t, infos[0].Active)
+	assert.Equal(t, "bar", infos[1].Key)
+	assert.False(t, infos[1].Active)
+	assert.Equal(t, 1, infos[1].Count)
+
+	close(release)
+
+	assert.NoError(t, <-first)
+	assert.NoError(t, <-third)
+
+	assert.Empty(t, tx.Transmissions())
+}
+
+func TestTransmitterSupersedeActive(t *testing.T) {
+	release := make(chan struct{})
+	pin := NewFakeOutputPin()
+
+	tx := NewPinTransmitter(pin, TransmissionCount(10))
+	tx.delay = blockingDelay(release)
+	defer tx.Close()
+
+	first := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{Key: "foo"})
+	waitActive(t, tx)
+
+	second := tx.Transmit(context.Background(), 0x2, DefaultProtocols[0], 190, TransmitOptions{Key: "foo"})
+
+	close(release)
+
+	assert.Equal(t, ErrTransmissionSuperseded, <-first)
+	assert.NoError(t, <-second)
+
+	// The first transmission is stopped after its current repetition, the
+	// second one is sent out completely.
+	assert.Len(t, pin.Values, 11*50)
+}
+
+func TestTransmitterContextCancel(t *testing.T) {
+	release := make(chan struct{})
+	pin := NewFakeOutputPin()
+
+	tx := NewPinTransmitter(pin, TransmissionCount(1))
+	tx.delay = blockingDelay(release)
+	defer tx.Close()
+
+	first := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+	waitActive(t, tx)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	second := tx.Transmit(ctx, 0x2, DefaultProtocols[0], 190, TransmitOptions{})
+	require.Len(t, tx.Transmissions(), 2)
+
+	cancel()
+
+	select {
+	case err := <-second:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected cancelled transmission to be done")
+	}
+
+	close(release)
+	assert.NoError(t, <-first)
+
+	assert.Len(t, pin.Values, 50)
+
+	assert.Equal(t, context.Canceled, <-tx.Transmit(ctx, 0x3, DefaultProtocols[0], 190, TransmitOptions{}))
+
+	assert.Len(t, pin.Values, 50)
+}
+
+func TestTransmitterClosePending(t *testing.T) {
+	release := make(chan struct{})
+	pin := NewFakeOutputPin()
+
+	tx := NewPinTransmitter(pin, TransmissionCount(10))
+	tx.delay = blockingDelay(release)
+
+	first := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+	waitActive(t, tx)
+
+	second := tx.Transmit(context.Background(), 0x2, DefaultProtocols[0], 190, TransmitOptions{})
+
+	go func() {
+		// Only release the active transmission once Close cancelled it.
+		require.Eventually(t, func() bool {
+			tx.mu.Lock()
+			defer tx.mu.Unlock()
+			return tx.closed
+		}, time.Second, time.Millisecond)
+		close(release)
+	}()
+
+	require.NoError(t, tx.Close())
+	assert.True(t, pin.Closed)
+
+	assert.Equal(t, ErrTransmitterClosed, <-first)
+	assert.Equal(t, ErrTransmitterClosed, <-second)
+
+	// The active transmission is stopped after its first repetition.
+	assert.Len(t, pin.Values, 50)
+
+	assert.Equal(t, ErrTransmitterClosed, <-tx.Transmit(context.Background(), 0x3, DefaultProtocols[0], 190, TransmitOptions{}))
+	assert.NoError(t, tx.Close())
+}
+
+func TestTransmitterQueueFull(t *testing.T) {
+	release := make(chan struct{})
+
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmissionCount(1))
+	tx.delay = blockingDelay(release)
+	defer tx.Close()
+
+	tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+	waitActive(t, tx)
+
+	for i := 0; i < maxPendingTransmissions; i++ {
+		tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+	}
+
+	assert.Equal(t, ErrQueueFull, <-tx.Transmit(context.Background(), 0x2, DefaultProtocols[0], 190, TransmitOptions{}))
+
+	close(release)
+}
+
+func TestTransmitterSetValueError(t *testing.T) {
+	pin := NewFakeOutputPin()
+	pin.Err = errors.New("whoops")
+
+	tx := NewPinTransmitter(pin)
+	defer tx.Close()
+
+	err := <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+
+	assert.EqualError(t, err, "failed to set pin value: whoops")
+	assert.Equal(t, []int{1, 0}, pin.Values)
+}
+
+func TestTransmitterSetValueErrorCancellableContext(t *testing.T) {
+	release := make(chan struct{})
+	pin := NewFakeOutputPin()
+
+	tx := NewPinTransmitter(pin, TransmissionCount(1))
+	tx.delay = func(time.Duration) {
+		<-release
+		pin.Err = errors.New("whoops")
+	}
+	defer tx.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := tx.Transmit(ctx, 0x1, DefaultProtocols[0], 190, TransmitOptions{})
+	waitActive(t, tx)
+
+	// Give the context watcher the chance to wait for the transmission to
+	// finish before the caller does. It must not consume the error.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	assert.EqualError(t, <-errs, "failed to set pin value: whoops")
+}
+
+func TestTransmitterTimingStats(t *testing.T) {
+	fakeClock := clockwork.NewFakeClockAt(time.Now())
+
+	var stats []TimingStats
+
+	tx := NewPinTransmitter(
+		NewFakeOutputPin(),
+		TransmissionCount(2),
+		TransmitterTimingStats(func(s TimingStats) { stats = append(stats, s) }),
+	)
+	tx.now = func() int64 { return fakeClock.Now().UnixNano() }
+	tx.delay = func(d time.Duration) { fakeClock.Advance(d + 5*time.Microsecond) }
+	defer tx.Close()
+
+	require.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{Key: "foo"}))
+
+	require.Len(t, stats, 1)
+	assert.Equal(t, TimingStats{
+		Key:        "foo",
+		Pulses:     100,
+		MeanJitter: 5 * time.Microsecond,
+		MaxJitter:  5 * time.Microsecond,
+	}, stats[0])
+}
+
+func TestTransmitterLockOSThread(t *testing.T) {
+	tx := NewPinTransmitter(NewFakeOutputPin(), TransmitterLockOSThread(true))
+	defer tx.Close()
+
+	assert.NoError(t, tx.RealtimeError())
+	assert.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, TransmitOptions{}))
+}
+
+func TestTransmitterTransmitOptions(t *testing.T) {
+	pin := NewFakeOutputPin()
+
+	var gaps []time.Duration
+
+	tx := NewPinTransmitter(pin, TransmissionCount(10))
+	tx.sleep = func(d time.Duration, _ <-chan struct{}) { gaps = append(gaps, d) }
+	defer tx.Close()
+
+	opts := TransmitOptions{TransmissionCount: 3, RepeatGap: 20 * time.Millisecond}
+
+	require.NoError(t, <-tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, opts))
+
+	assert.Len(t, pin.Values, 3*50)
+	assert.Equal(t, []time.Duration{20 * time.Millisecond, 20 * time.Millisecond}, gaps)
+
+	rec := &Recording{
+		Pulses: []time.Duration{350 * time.Microsecond, 1050 * time.Microsecond, 350 * time.Microsecond},
+	}
+
+	require.NoError(t, <-tx.TransmitRecording(context.Background(), rec, TransmitOptions{TransmissionCount: 2}))
+
+	assert.Len(t, pin.Values, 3*50+2*4)
+	assert.Len(t, gaps, 2)
+}
+
+func TestTransmitterCloseDuringRepeatGap(t *testing.T) {
+	pin := NewFakeOutputPin()
+
+	tx := NewPinTransmitter(pin)
+
+	opts := TransmitOptions{TransmissionCount: 3, RepeatGap: time.Hour}
+
+	errs := tx.Transmit(context.Background(), 0x1, DefaultProtocols[0], 190, opts)
+	waitActive(t, tx)
+
+	done := make(chan error)
+
+	go func() { done <- tx.Close() }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected Close not to wait for the repeat gap")
+	}
+
+	assert.Equal(t, ErrTransmitterClosed, <-errs)
+	assert.Len(t, pin.Values, 50)
+}
+
+type fakePulseSender struct {
+	*FakeOutputPin
+	pulses [][]time.Duration
+}
+
+func (s *fakePulseSender) SendPulses(pulses []time.Duration) error {
+	s.pulses = append(s.pulses, pulses)
+	return nil
+}
+
+func TestTransmitterPulseSender(t *testing.T) {
+	pin := &fakePulseSender{FakeOutputPin: NewFakeOutputPin()}
+
+	tx := NewPinTransmitter(pin, TransmissionCount(2))
+
+	rec := &Recording{
+		Pulses: []time.Duration{350 * time.Microsecond, 1050 * time.Microsecond, 350 * time.Microsecond},
+	}
+
+	require.NoError(t, <-tx.TransmitRecording(context.Background(), rec, TransmitOptions{TransmissionCount: 2}))
+	require.NoError(t, tx.Close())
+
+	assert.Equal(t, [][]time.Duration{rec.Pulses, rec.Pulses}, pin.pulses)
+	assert.Empty(t, pin.Values)
+}
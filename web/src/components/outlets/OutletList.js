@@ -5,9 +5,14 @@ import List from '@material-ui/core/List';
 import ListItem from '@material-ui/core/ListItem';
 import ListItemSecondaryAction from '@material-ui/core/ListItemSecondaryAction';
 import ListItemText from '@material-ui/core/ListItemText';
+import Button from '@material-ui/core/Button';
 import IconButton from '@material-ui/core/IconButton';
+import Slider from '@material-ui/core/Slider';
 import Switch from '@material-ui/core/Switch';
+import ArrowDownwardIcon from '@material-ui/icons/ArrowDownward';
+import ArrowUpwardIcon from '@material-ui/icons/ArrowUpward';
 import SettingsIcon from '@material-ui/icons/Settings';
+import StopIcon from '@material-ui/icons/Stop';
 import { NoItemsListItem } from '../List';
 import { useTranslation } from 'react-i18next';
 import { useHistory } from 'react-router';
@@ -18,6 +23,12 @@ const useStyles = makeStyles(theme => ({
   container: {
     padding: 0,
   },
+  slider: {
+    width: 100,
+    marginLeft: theme.spacing(1),
+    marginRight: theme.spacing(1),
+    verticalAlign: 'middle',
+  },
 }));
 
 export default function OutletList({ outlets }) {
@@ -48,28 +59,57 @@ OutletList.propTypes = {
 };
 
 
-const OutletListItem = ({ id, displayName, state, schedule }) => {
+const OutletListItem = ({ id, displayName, state, schedule, actions, stateless, cover }) => {
   const history = useHistory();
   const { t } = useTranslation();
 
   const handleToggle = () => dispatcher.dispatchOutletMessage(id, 'toggle');
 
+  const handleAction = action => dispatcher.dispatchActionMessage(id, action);
+
+  const handleCover = (action, position) => dispatcher.dispatchCoverMessage(id, action, position);
+
   const hasEnabledIntervals = () => schedule.some(interval => interval.enabled);
 
   return (
     <ListItem>
       <ListItemText
-        primary={displayName}
+        primary={cover ? `${displayName} (${cover.position}%)` : displayName}
         secondary={formatSchedule(schedule, t)}
         onClick={() => history.push(`/schedule/${id}`)}
       />
       <ListItemSecondaryAction>
-        <Switch
-          color="primary"
-          onChange={handleToggle}
-          checked={state === 1}
-          disabled={hasEnabledIntervals()}
-        />
+        {(actions || []).map(action =>
+          <Button key={action.name} onClick={() => handleAction(action.name)}>
+            {action.displayName}
+          </Button>
+        )}
+        {cover && (
+          <React.Fragment>
+            <IconButton onClick={() => handleCover('open')} disabled={hasEnabledIntervals()}>
+              <ArrowUpwardIcon />
+            </IconButton>
+            <CoverPositionSlider
+              position={cover.position}
+              onChange={position => handleCover('position', position)}
+              disabled={hasEnabledIntervals()}
+            />
+            <IconButton onClick={() => handleCover('stop')}>
+              <StopIcon />
+            </IconButton>
+            <IconButton onClick={() => handleCover('close')} disabled={hasEnabledIntervals()}>
+              <ArrowDownwardIcon />
+            </IconButton>
+          </React.Fragment>
+        )}
+        {!stateless && (
+          <Switch
+            color="primary"
+            onChange={handleToggle}
+            checked={state === 1}
+            disabled={hasEnabledIntervals()}
+          />
+        )}
         <IconButton onClick={() => history.push(`/schedule/${id}`)}>
           <SettingsIcon />
         </IconButton>
@@ -83,4 +123,39 @@ OutletListItem.propTypes = {
   displayName: PropTypes.string.isRequired,
   state: PropTypes.number.isRequired,
   schedule: PropTypes.array.isRequired,
+  actions: PropTypes.array,
+  stateless: PropTypes.bool,
+  cover: PropTypes.object,
+};
+
+// CoverPositionSlider moves a cover to the selected position once the slider
+// is released. While dragging, the selected value is shown instead of the
+// cover's current position.
+const CoverPositionSlider = ({ position, onChange, disabled }) => {
+  const classes = useStyles();
+  const { t } = useTranslation();
+  const [value, setValue] = React.useState(null);
+
+  const handleChangeCommitted = (_, newValue) => {
+    setValue(null);
+    onChange(newValue);
+  };
+
+  return (
+    <Slider
+      className={classes.slider}
+      value={value === null ? position : value}
+      onChange={(_, newValue) => setValue(newValue)}
+      onChangeCommitted={handleChangeCommitted}
+      disabled={disabled}
+      valueLabelDisplay="auto"
+      aria-label={t('cover-position')}
+    />
+  );
+};
+
+CoverPositionSlider.propTypes = {
+  position: PropTypes.number.isRequired,
+  onChange: PropTypes.func.isRequired,
+  disabled: PropTypes.bool,
 };
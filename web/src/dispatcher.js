@@ -22,6 +22,14 @@ class Dispatcher {
     this.dispatchMessage('outlet', { outletID, action });
   }
 
+  dispatchActionMessage(outletID, action) {
+    this.dispatchMessage('action', { outletID, action });
+  }
+
+  dispatchCoverMessage(outletID, action, position = 0) {
+    this.dispatchMessage('cover', { outletID, action, position });
+  }
+
   dispatchIntervalMessage(outletID, action, interval) {
     const data = { outletID, action, interval: intervalToApi(interval) };
 